@@ -0,0 +1,416 @@
+package actions
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the file format of an archive.
+type ArchiveFormat int
+
+const (
+	TarArchive ArchiveFormat = iota
+	ZipArchive
+)
+
+// ParseArchiveFormat returns the named archive format.
+func ParseArchiveFormat(name string) (ArchiveFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "tar":
+		return TarArchive, nil
+	case "zip":
+		return ZipArchive, nil
+	}
+	return 0, fmt.Errorf("unsupported archive format %q", name)
+}
+
+func (f ArchiveFormat) String() string {
+	if f == ZipArchive {
+		return "zip"
+	}
+	return "tar"
+}
+
+// Ext returns the file extension of the format.
+func (f ArchiveFormat) Ext() string { return "." + f.String() }
+
+//-----------------------------------------------------------------------------
+// Archiver
+
+// Archiver streams files into an archive, under a single top directory, and
+// records their checksums in a manifest as they go. Nothing is staged on disk.
+type Archiver struct {
+	format   ArchiveFormat
+	dir      string // The top directory.
+	tw       *tar.Writer
+	zw       *zip.Writer
+	manifest *Manifest
+}
+
+// NewArchiver returns an archiver writing to w. Files are stored under dir.
+func NewArchiver(w io.Writer, f ArchiveFormat, dir string, a HashAlgorithm) *Archiver {
+	ar := &Archiver{format: f, dir: dir, manifest: NewManifest(a)}
+	if f == ZipArchive {
+		ar.zw = zip.NewWriter(w)
+	} else {
+		ar.tw = tar.NewWriter(w)
+	}
+	return ar
+}
+
+// Manifest returns the manifest of the files added so far.
+func (ar *Archiver) Manifest() *Manifest { return ar.manifest }
+
+// AddFile adds the file at path as name, and records its checksum.
+func (ar *Archiver) AddFile(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h := hashAlgorithms[ar.manifest.Algorithm].new()
+	if err := ar.add(name, fi.Size(), fi.ModTime(), io.TeeReader(f, h)); err != nil {
+		return fmt.Errorf("error archiving %q; %s", path, err)
+	}
+	ar.manifest.Set(&ManifestEntry{
+		File:    name,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UTC(),
+		Sum:     hex.EncodeToString(h.Sum(nil)),
+	})
+	return nil
+}
+
+// AddData adds data as name. Its checksum is recorded unless skipManifest.
+func (ar *Archiver) AddData(name string, data []byte, skipManifest bool) error {
+	now := time.Now()
+	h := hashAlgorithms[ar.manifest.Algorithm].new()
+	h.Write(data)
+	if err := ar.add(name, int64(len(data)), now, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("error archiving %q; %s", name, err)
+	}
+	if !skipManifest {
+		ar.manifest.Set(&ManifestEntry{
+			File:    name,
+			Size:    int64(len(data)),
+			ModTime: now.UTC(),
+			Sum:     hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+	return nil
+}
+
+// AddManifest adds the manifest of the files added so far.
+func (ar *Archiver) AddManifest(f ManifestFormat) error {
+	var b strings.Builder
+	if err := ar.manifest.Write(&b, f); err != nil {
+		return err
+	}
+	return ar.AddData(ManifestName(ar.manifest.Algorithm, f), []byte(b.String()), true)
+}
+
+func (ar *Archiver) add(name string, size int64, mtime time.Time, r io.Reader) error {
+	name = path.Join(ar.dir, name)
+	var w io.Writer
+	switch ar.format {
+	case ZipArchive:
+		// Wave files hardly compress, so they are stored.
+		fh := &zip.FileHeader{Name: name, Method: zip.Store}
+		fh.Modified = mtime
+		fh.SetMode(0644)
+		var err error
+		if w, err = ar.zw.CreateHeader(fh); err != nil {
+			return err
+		}
+	default:
+		if err := ar.tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+		w = ar.tw
+	}
+	n, err := io.Copy(w, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("size changed from %d to %d bytes while archiving", size, n)
+	}
+	return err
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (ar *Archiver) Close() error {
+	if ar.zw != nil {
+		return ar.zw.Close()
+	}
+	return ar.tw.Close()
+}
+
+//-----------------------------------------------------------------------------
+// Volumes
+
+// VolumeWriter writes a stream to volume files of at most size bytes each,
+// named name.001, name.002, and so on. Joined together (e.g. with cat), the
+// volumes are the original stream. If size is 0, the single file name is
+// written instead.
+type VolumeWriter struct {
+	name    string
+	size    int64
+	f       *os.File
+	written int64 // To the current volume.
+	files   []string
+}
+
+// NewVolumeWriter returns a volume writer. No file is created until written.
+func NewVolumeWriter(name string, size int64) *VolumeWriter {
+	return &VolumeWriter{name: name, size: size}
+}
+
+// Files returns the volume files written so far.
+func (v *VolumeWriter) Files() []string { return v.files }
+
+// Write implements the io.Writer interface.
+func (v *VolumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if v.f == nil || (v.size > 0 && v.written == v.size) {
+			if err := v.next(); err != nil {
+				return total, err
+			}
+		}
+		chunk := p
+		if v.size > 0 && int64(len(chunk)) > v.size-v.written {
+			chunk = chunk[:v.size-v.written]
+		}
+		n, err := v.f.Write(chunk)
+		total += n
+		v.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// next closes the current volume, and creates the next one.
+func (v *VolumeWriter) next() error {
+	if err := v.closeVolume(); err != nil {
+		return err
+	}
+	name := v.name
+	if v.size > 0 {
+		name = fmt.Sprintf("%s.%03d", v.name, len(v.files)+1)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	v.f, v.written = f, 0
+	v.files = append(v.files, name)
+	return nil
+}
+
+func (v *VolumeWriter) closeVolume() error {
+	if v.f == nil {
+		return nil
+	}
+	err := v.f.Close()
+	v.f = nil
+	return err
+}
+
+// Close closes the last volume.
+func (v *VolumeWriter) Close() error { return v.closeVolume() }
+
+// Volumes reads the volumes written by a VolumeWriter as a single stream.
+type Volumes struct {
+	files []*os.File
+	sizes []int64
+	size  int64
+}
+
+// OpenVolumes opens the file name, or else its volumes name.001, name.002, and
+// so on. name may also be the first volume itself.
+func OpenVolumes(name string) (*Volumes, error) {
+	name = strings.TrimSuffix(name, ".001")
+	names := []string{}
+	if _, err := os.Stat(name); err == nil {
+		names = append(names, name)
+	} else {
+		for i := 1; ; i++ {
+			vol := fmt.Sprintf("%s.%03d", name, i)
+			if _, err := os.Stat(vol); err != nil {
+				break
+			}
+			names = append(names, vol)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no such archive %q", name)
+	}
+
+	v := &Volumes{}
+	for _, n := range names {
+		f, err := os.Open(n)
+		if err != nil {
+			v.Close()
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			v.Close()
+			return nil, err
+		}
+		v.files, v.sizes = append(v.files, f), append(v.sizes, fi.Size())
+		v.size += fi.Size()
+	}
+	return v, nil
+}
+
+// Size returns the total size of the volumes.
+func (v *Volumes) Size() int64 { return v.size }
+
+// ReadAt implements the io.ReaderAt interface.
+func (v *Volumes) ReadAt(p []byte, off int64) (int, error) {
+	total := 0
+	for i, f := range v.files {
+		if len(p) == 0 {
+			break
+		}
+		if off >= v.sizes[i] {
+			off -= v.sizes[i]
+			continue
+		}
+		chunk := p
+		if int64(len(chunk)) > v.sizes[i]-off {
+			chunk = chunk[:v.sizes[i]-off]
+		}
+		n, err := f.ReadAt(chunk, off)
+		total += n
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		if n < len(chunk) {
+			return total, io.ErrUnexpectedEOF
+		}
+		p, off = p[n:], 0
+	}
+	if len(p) > 0 {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// Close closes all volumes.
+func (v *Volumes) Close() error {
+	var err error
+	for _, f := range v.files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// Extraction
+
+// ExtractArchive extracts the archive read from r into dir. It returns the
+// extracted files, relative to dir. Entries that would be written outside
+// dir, and entries other than files and directories, are errors.
+func ExtractArchive(r io.ReaderAt, size int64, f ArchiveFormat, dir string) ([]string, error) {
+	files := []string{}
+	extract := func(name string, mode os.FileMode, mtime time.Time, rc io.Reader) error {
+		file, err := archivePath(dir, name)
+		if err != nil {
+			return err
+		}
+		if mode.IsDir() {
+			return os.MkdirAll(file, 0755)
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%q is not a file", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, rc)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return os.Chtimes(file, mtime, mtime)
+	}
+
+	if f == ZipArchive {
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			rc, err := zf.Open()
+			if err != nil {
+				return files, err
+			}
+			err = extract(zf.Name, zf.Mode(), zf.Modified, rc)
+			rc.Close()
+			if err != nil {
+				return files, fmt.Errorf("error extracting %q; %s", zf.Name, err)
+			}
+		}
+		return files, nil
+	}
+
+	tr := tar.NewReader(io.NewSectionReader(r, 0, size))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		if err := extract(h.Name, h.FileInfo().Mode(), h.ModTime, tr); err != nil {
+			return files, fmt.Errorf("error extracting %q; %s", h.Name, err)
+		}
+	}
+}
+
+// archivePath returns the path of an archive entry within dir.
+func archivePath(dir, name string) (string, error) {
+	n := strings.Replace(name, `\`, "/", -1)
+	clean := path.Clean(n)
+	if path.IsAbs(n) || strings.Contains(n, ":") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe path %q", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,217 @@
+package actions
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArchiveFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		ok     bool
+		format ArchiveFormat
+	}{
+		{"tar", true, TarArchive},
+		{".zip", true, ZipArchive},
+		{"ZIP", true, ZipArchive},
+		{"rar", false, 0},
+	} {
+		f, err := ParseArchiveFormat(tt.name)
+		if err == nil && !tt.ok {
+			t.Errorf("ParseArchiveFormat(%q) expected error", tt.name)
+			continue
+		}
+		if err != nil && tt.ok {
+			t.Errorf("ParseArchiveFormat(%q) unexpected error %s", tt.name, err)
+			continue
+		}
+		if got, want := f, tt.format; got != want {
+			t.Errorf("ParseArchiveFormat(%q) = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	data := map[string][]byte{
+		"01-01 Kick.wav":  bytes.Repeat([]byte("kick"), 300),
+		"01-02 Snare.wav": bytes.Repeat([]byte("snare"), 200),
+	}
+	for name, d := range data {
+		if err := ioutil.WriteFile(filepath.Join(src, name), d, 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		format ArchiveFormat
+		size   int64
+		vols   bool
+	}{
+		{TarArchive, 0, false},
+		{TarArchive, 1000, true},
+		{ZipArchive, 0, false},
+		{ZipArchive, 1000, true},
+	} {
+		desc := fmt.Sprintf("%s-%d", tt.format, tt.size)
+		file := filepath.Join(dir, desc, "show"+tt.format.Ext())
+		if err := os.Mkdir(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		vw := NewVolumeWriter(file, tt.size)
+		ar := NewArchiver(vw, tt.format, "show", MD5)
+		for _, name := range []string{"01-01 Kick.wav", "01-02 Snare.wav"} {
+			if err := ar.AddFile(name, filepath.Join(src, name)); err != nil {
+				t.Fatalf("%s: AddFile() unexpected error %s", tt.format, err)
+			}
+		}
+		if err := ar.AddData("README.txt", []byte("show\n"), false); err != nil {
+			t.Fatalf("%s: AddData() unexpected error %s", tt.format, err)
+		}
+		if err := ar.AddManifest(SumFormat); err != nil {
+			t.Fatalf("%s: AddManifest() unexpected error %s", tt.format, err)
+		}
+		if err := ar.Close(); err != nil {
+			t.Fatalf("%s: Close() unexpected error %s", tt.format, err)
+		}
+		if err := vw.Close(); err != nil {
+			t.Fatalf("%s: Close() unexpected error %s", tt.format, err)
+		}
+		if got, want := len(ar.Manifest().Entries), 3; got != want {
+			t.Errorf("%s: manifest has %d entries, want %d", tt.format, got, want)
+		}
+		files := vw.Files()
+		if got, want := len(files) > 1, tt.vols; got != want {
+			t.Errorf("%s: wrote volumes %v", desc, files)
+		}
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				t.Fatalf("unexpected error; %s", err)
+			}
+			if tt.size > 0 && fi.Size() > tt.size {
+				t.Errorf("%s: volume %s is %d bytes, want at most %d", tt.format, f, fi.Size(), tt.size)
+			}
+		}
+
+		vs, err := OpenVolumes(files[0])
+		if err != nil {
+			t.Fatalf("%s: OpenVolumes() unexpected error %s", tt.format, err)
+		}
+		out := filepath.Join(dir, desc, "out")
+		got, err := ExtractArchive(vs, vs.Size(), tt.format, out)
+		vs.Close()
+		if err != nil {
+			t.Fatalf("%s: ExtractArchive() unexpected error %s", tt.format, err)
+		}
+		want := []string{"show/01-01 Kick.wav", "show/01-02 Snare.wav", "show/README.txt", "show/" + ManifestName(MD5, SumFormat)}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ExtractArchive() = %q, want %q", tt.format, got, want)
+		}
+		for name, d := range data {
+			b, err := ioutil.ReadFile(filepath.Join(out, "show", name))
+			if err != nil {
+				t.Errorf("%s: %s", tt.format, err)
+				continue
+			}
+			if !bytes.Equal(b, d) {
+				t.Errorf("%s: %s differs after extraction", tt.format, name)
+			}
+		}
+
+		// The extracted files match the manifest.
+		mf, err := os.Open(filepath.Join(out, "show", ManifestName(MD5, SumFormat)))
+		if err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		m, err := ReadManifest(mf, SumFormat)
+		mf.Close()
+		if err != nil {
+			t.Fatalf("%s: ReadManifest() unexpected error %s", tt.format, err)
+		}
+		v, err := VerifyManifest(filepath.Join(out, "show"), m, ManifestName(MD5, SumFormat))
+		if err != nil {
+			t.Fatalf("%s: VerifyManifest() unexpected error %s", tt.format, err)
+		}
+		if !v.Passed() {
+			t.Errorf("%s: VerifyManifest() = %+v", tt.format, v)
+		}
+
+		// Extracting again does not overwrite files.
+		vs, err = OpenVolumes(file)
+		if err != nil {
+			t.Fatalf("%s: OpenVolumes() unexpected error %s", tt.format, err)
+		}
+		if _, err := ExtractArchive(vs, vs.Size(), tt.format, out); err == nil {
+			t.Errorf("%s: ExtractArchive() expected error", tt.format)
+		}
+		vs.Close()
+	}
+
+	if _, err := OpenVolumes(filepath.Join(dir, "missing.tar")); err == nil {
+		t.Errorf("OpenVolumes() expected error")
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name     string
+		typeflag byte
+		ok       bool
+	}{
+		{"show/a.wav", tar.TypeReg, true},
+		{"./show/b.wav", tar.TypeReg, true},
+		{"../a.wav", tar.TypeReg, false},
+		{"show/../../a.wav", tar.TypeReg, false},
+		{"/tmp/a.wav", tar.TypeReg, false},
+		{`C:\a.wav`, tar.TypeReg, false},
+		{"show/link", tar.TypeSymlink, false},
+	} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if tt.typeflag == tar.TypeReg {
+			tw.WriteHeader(&tar.Header{Name: tt.name, Mode: 0644, Size: 1, Typeflag: tt.typeflag})
+			tw.Write([]byte("x"))
+		} else {
+			tw.WriteHeader(&tar.Header{Name: tt.name, Mode: 0777, Typeflag: tt.typeflag, Linkname: "/etc/passwd"})
+		}
+		tw.Close()
+		_, err := ExtractArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), TarArchive, dir)
+		if err == nil && !tt.ok {
+			t.Errorf("ExtractArchive(%q) expected error", tt.name)
+		}
+		if err != nil && tt.ok {
+			t.Errorf("ExtractArchive(%q) unexpected error %s", tt.name, err)
+		}
+	}
+	fis, err := ioutil.ReadDir(filepath.Dir(dir))
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), "a.wav") {
+			t.Errorf("ExtractArchive() wrote %s outside of %s", fi.Name(), dir)
+		}
+	}
+}
@@ -0,0 +1,756 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleFormat is the format of the samples of a wave file.
+type SampleFormat struct {
+	Bits  int  // Bits per sample.
+	Float bool // IEEE float, rather than integer, samples.
+}
+
+// The sample formats a wave file can be converted to.
+var (
+	Int16   = SampleFormat{16, false}
+	Int24   = SampleFormat{24, false}
+	Int32   = SampleFormat{32, false}
+	Float32 = SampleFormat{32, true}
+)
+
+// ParseSampleFormat returns the named sample format, i.e. "16", "24" or "32"
+// for integer samples, or "32f" for float samples.
+func ParseSampleFormat(name string) (SampleFormat, error) {
+	switch strings.ToLower(name) {
+	case "16":
+		return Int16, nil
+	case "24":
+		return Int24, nil
+	case "32":
+		return Int32, nil
+	case "32f", "float":
+		return Float32, nil
+	}
+	return SampleFormat{}, fmt.Errorf("unsupported sample format %q", name)
+}
+
+// String implements the fmt.Stringer interface.
+func (f SampleFormat) String() string {
+	if f.Float {
+		return fmt.Sprintf("%df", f.Bits)
+	}
+	return strconv.Itoa(f.Bits)
+}
+
+// IsZero returns true for the zero value, i.e. no format.
+func (f SampleFormat) IsZero() bool { return f.Bits == 0 }
+
+// writable returns true if samples can be written in the format.
+func (f SampleFormat) writable() bool {
+	switch f {
+	case Int16, Int24, Int32, Float32, SampleFormat{64, true}:
+		return true
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// Converter
+
+const convertBlockFrames = 4096
+
+// bextTimeReferenceAt is the offset of the time reference, a count of samples
+// since midnight, within the bext chunk of a Broadcast Wave file.
+const bextTimeReferenceAt = 338
+
+// convertChunks are the chunks preceding the data copied to converted files,
+// i.e. the Broadcast Wave and metadata chunks.
+var convertChunks = map[string]bool{"bext": true, "iXML": true, "axml": true, "LIST": true}
+
+// Converter converts the sample format, rate and encoding of wave and FLAC
+// files. Reducing the resolution of the samples, e.g. from 32-bit float to
+// 24-bit, adds TPDF dither. Resampling uses a windowed sinc filter. The zero
+// value converts nothing, and only rewrites the headers as a wave file.
+type Converter struct {
+	Format     SampleFormat  // The zero value keeps the format of each file.
+	SampleRate int           // In Hz; 0 keeps the rate of each file.
+	Encoding   AudioEncoding // Of the converted files.
+}
+
+// NewConverter returns a converter to the format and rate.
+func NewConverter(f SampleFormat, rate int) *Converter {
+	return &Converter{Format: f, SampleRate: rate}
+}
+
+// ConvertOp returns a FileOp converting op.Src to op.Dest with c.
+func ConvertOp(c *Converter) FileOp {
+	return func(op *Operation) error {
+		_, _, err := c.Convert(op.Src, op.Dest)
+		return err
+	}
+}
+
+// Convert converts the wave or FLAC file src to dest. The Broadcast Wave
+// (bext) and metadata chunks of a wave file are kept in a wave file. FLAC
+// files are written with the Vorbis comments, e.g. "TITLE=Kick", or else
+// those of a FLAC src. As with CopyVerified(), dest is read back and verified
+// before being put in place; see writePartial(). It returns the hex encoded
+// SHA-256 checksum of dest.
+func (c *Converter) Convert(src, dest string, comments ...string) (string, CopyResult, error) {
+	res := CopyResult{Dest: dest}
+	start := time.Now()
+	in, err := os.Open(src)
+	if err != nil {
+		return "", res, err
+	}
+	defer in.Close()
+	r, err := openSamples(in)
+	if err != nil {
+		return "", res, fmt.Errorf("error reading %q; %s", src, err)
+	}
+
+	from, to, rate := r.format, c.Format, c.SampleRate
+	if to.IsZero() {
+		to = from
+	}
+	if c.Encoding == FLACEncoding && to.Float {
+		return "", res, fmt.Errorf("FLAC holds integer samples only; choose a sample format such as 24")
+	}
+	if !to.Float && (c.Encoding == FLACEncoding || r.header == nil) {
+		// FLAC holds any depth, e.g. 20 bits, written in the next wider one.
+		for _, f := range []SampleFormat{Int16, Int24, Int32} {
+			if to.Bits <= f.Bits {
+				to = f
+				break
+			}
+		}
+	}
+	if !to.writable() {
+		return "", res, fmt.Errorf("unsupported sample format %s", to)
+	}
+	if rate == 0 {
+		rate = r.rate
+	}
+	resample := rate != r.rate
+	// Dither when reducing the resolution. The dither is seeded by the name of
+	// the file, so that converting a file twice writes the same data, while
+	// the tracks of a session, summed in the mix, don't share their dither.
+	var rngs []*rand.Rand
+	if !to.Float && (from.Float || from.Bits > to.Bits || resample) {
+		rngs = ditherRands(filepath.Base(src), r.channels)
+	}
+
+	var chunks []riffChunk
+	if c.Encoding != FLACEncoding && r.header != nil {
+		if chunks, err = convertedChunks(in, r.header, rate); err != nil {
+			return "", res, fmt.Errorf("error reading %q; %s", src, err)
+		}
+	}
+	if c.Encoding == FLACEncoding && len(comments) == 0 {
+		comments = r.comments
+	}
+
+	var sum string
+	var size int64
+	err = writePartial(dest, func(partial string) error {
+		var w sampleWriter
+		var err error
+		if c.Encoding == FLACEncoding {
+			w, err = newFLACWriter(partial, to.Bits, r.channels, rate, rngs, comments)
+		} else {
+			var ww *waveWriter
+			if ww, err = newWaveWriter(partial, to, r.channels, rate, chunks); err == nil {
+				ww.rngs, w = rngs, ww
+			}
+		}
+		if err != nil {
+			return err
+		}
+		sum, size, err = closeVerified(w, convertSamples(w, r, rate))
+		return err
+	})
+	if err != nil {
+		return "", res, fmt.Errorf("error converting %q; %s", src, err)
+	}
+	res.Bytes, res.Duration = size, time.Since(start)
+	return sum, res, nil
+}
+
+// writePartial writes dest by calling write with the name of a partial file
+// to write, and read back and verify, instead. The partial file is renamed to
+// dest if write succeeds, and removed otherwise, so that an incomplete or
+// corrupt file is never found at dest, e.g. after a full disk or a crash.
+func writePartial(dest string, write func(partial string) error) error {
+	partial := dest + partialSuffix
+	err := write(partial)
+	if err == nil {
+		err = os.Rename(partial, dest)
+	}
+	if err != nil {
+		os.Remove(partial)
+	}
+	return err
+}
+
+// closeVerified completes w, or aborts it if writing the samples failed with
+// err, and reads it back to verify it. It returns the hex encoded SHA-256
+// checksum and the size of the file.
+func closeVerified(w sampleWriter, err error) (string, int64, error) {
+	if err != nil {
+		w.abort()
+		return "", 0, err
+	}
+	if err := w.close(); err != nil {
+		return "", 0, err
+	}
+	return w.verify()
+}
+
+// sampleReader reads the samples of an audio file, in blocks of frames by
+// channel, as values within [-1, 1).
+type sampleReader struct {
+	format   SampleFormat
+	channels int
+	rate     int
+	header   *waveHeader // Of a wave file.
+	comments []string    // Of a FLAC file.
+	// read returns the next block, valid until the next call, or io.EOF.
+	read func() ([][]float64, error)
+}
+
+// openSamples returns a reader of the samples of the wave or FLAC file f.
+func openSamples(f *os.File) (*sampleReader, error) {
+	b := make([]byte, 4)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	if string(b) == flacMagic {
+		return openFLACSamples(f)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	decode, err := sampleDecoder(h)
+	if err != nil {
+		return nil, err
+	}
+	frames := h.dataFrames(fi.Size())
+	data := io.NewSectionReader(f, h.dataOffset, frames*int64(h.blockAlign))
+
+	channels, width := int(h.channels), int(h.bitsPerSample+7)/8
+	buf := make([]byte, convertBlockFrames*int(h.blockAlign))
+	samples := make([][]float64, channels)
+	for c := range samples {
+		samples[c] = make([]float64, convertBlockFrames)
+	}
+	block := make([][]float64, channels)
+	return &sampleReader{
+		format:   SampleFormat{int(h.bitsPerSample), h.sampleFormat() == waveFormatFloat},
+		channels: channels,
+		rate:     int(h.sampleRate),
+		header:   h,
+		read: func() ([][]float64, error) {
+			n, err := io.ReadFull(data, buf)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			frames := n / int(h.blockAlign)
+			if frames == 0 {
+				return nil, io.EOF
+			}
+			for f := 0; f < frames; f++ {
+				b := buf[f*int(h.blockAlign):]
+				for c := 0; c < channels; c++ {
+					samples[c][f] = decode(b[c*width:])
+				}
+			}
+			for c := range block {
+				block[c] = samples[c][:frames]
+			}
+			return block, nil
+		},
+	}, nil
+}
+
+// sampleWriter writes the samples of a converted file.
+type sampleWriter interface {
+	// write writes frames of samples, given by channel.
+	write(samples [][]float64) error
+	// close completes and closes the file.
+	close() error
+	// abort closes the file, incomplete.
+	abort()
+	// verify reads back the closed file, and verifies it. It returns the
+	// hex encoded SHA-256 checksum and the size of the file.
+	verify() (string, int64, error)
+}
+
+// convertSamples converts the samples read from r, and writes them to w.
+func convertSamples(w sampleWriter, r *sampleReader, rate int) error {
+	var rs []*resampler
+	if rate != r.rate {
+		f := newResampleFilter(r.rate, rate)
+		for c := 0; c < r.channels; c++ {
+			rs = append(rs, f.resampler())
+		}
+	}
+	outs := make([][]float64, r.channels)
+	for {
+		samples, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for c := range outs {
+			outs[c] = samples[c]
+			if rs != nil {
+				outs[c] = rs[c].push(outs[c])
+			}
+		}
+		if err := w.write(outs); err != nil {
+			return err
+		}
+	}
+	if rs == nil {
+		return nil
+	}
+	for c := range outs {
+		outs[c] = rs[c].flush()
+	}
+	return w.write(outs)
+}
+
+// convertedChunks returns the chunks of a wave file to copy to the converted
+// file, as written. The time reference of the bext chunk is adjusted to the
+// sample rate.
+func convertedChunks(r io.ReaderAt, h *waveHeader, rate int) ([]riffChunk, error) {
+	chunks := []riffChunk{}
+	for _, c := range h.chunks {
+		if !convertChunks[c.id] {
+			continue
+		}
+		data := make([]byte, c.size)
+		if _, err := r.ReadAt(data, c.offset); err != nil {
+			return nil, fmt.Errorf("error reading %s chunk; %s", c.id, err)
+		}
+		if c.id == "bext" && len(data) >= bextTimeReferenceAt+8 {
+			ref := binary.LittleEndian.Uint64(data[bextTimeReferenceAt:])
+			ref = ref * uint64(rate) / uint64(h.sampleRate)
+			binary.LittleEndian.PutUint64(data[bextTimeReferenceAt:], ref)
+		}
+		chunks = append(chunks, riffChunk{id: c.id, data: data})
+	}
+	return chunks, nil
+}
+
+// sampleDecoder returns a function decoding a single sample of the wave file
+// to a value within [-1, 1).
+func sampleDecoder(h *waveHeader) (func([]byte) float64, error) {
+	switch h.sampleFormat() {
+	case waveFormatPCM:
+		switch h.bitsPerSample {
+		case 8: // Unsigned.
+			return func(b []byte) float64 { return float64(int(b[0])-128) / (1 << 7) }, nil
+		case 16:
+			return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }, nil
+		case 24:
+			return func(b []byte) float64 {
+				return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+			}, nil
+		case 32:
+			return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+		}
+	case waveFormatFloat:
+		switch h.bitsPerSample {
+		case 32:
+			return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+		case 64:
+			return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported sample format %d with %d bits per sample", h.sampleFormat(), h.bitsPerSample)
+}
+
+//-----------------------------------------------------------------------------
+// Wave writer
+
+// waveWriter writes a RIFF wave file, or an RF64 one if it grows beyond the
+// 4 GiB of RIFF. The sizes are written on close. The goaudio wav codec only
+// reads wave files, hence this writer.
+type waveWriter struct {
+	f          *os.File
+	w          *bufio.Writer
+	format     SampleFormat
+	channels   int
+	rngs       []*rand.Rand // Dither integer samples, by channel, if set.
+	buf        []byte
+	factAt     int64 // Offset of the fact chunk frame count, or 0 if none.
+	dataSizeAt int64
+	dataOffset int64
+	dataSize   int64
+	dataSum    []byte // SHA-256 checksum of the data, once closed.
+	hash       hash.Hash
+	size       int64 // Of the file, once closed.
+}
+
+func newWaveWriter(file string, f SampleFormat, channels, rate int, chunks []riffChunk) (*waveWriter, error) {
+	blockAlign := channels * f.Bits / 8
+	b := &bytes.Buffer{}
+	le := func(v interface{}) { binary.Write(b, binary.LittleEndian, v) }
+	b.WriteString("RIFF")
+	le(uint32(0))
+	b.WriteString("WAVE")
+	// Reserve room for a ds64 chunk, should the file grow too large for RIFF.
+	b.WriteString("JUNK")
+	le(uint32(ds64Size))
+	b.Write(make([]byte, ds64Size))
+	b.WriteString("fmt ")
+	if f.Float {
+		le(uint32(18))
+		le(uint16(waveFormatFloat))
+	} else {
+		le(uint32(16))
+		le(uint16(waveFormatPCM))
+	}
+	le(uint16(channels))
+	le(uint32(rate))
+	le(uint32(rate * blockAlign))
+	le(uint16(blockAlign))
+	le(uint16(f.Bits))
+	if f.Float {
+		le(uint16(0)) // Extension size.
+	}
+	for _, c := range chunks {
+		b.WriteString(c.id)
+		le(uint32(len(c.data)))
+		b.Write(c.data)
+		if len(c.data)%2 == 1 {
+			b.WriteByte(0)
+		}
+	}
+	w := &waveWriter{format: f, channels: channels, hash: sha256.New()}
+	if f.Float {
+		// Non-PCM formats require the fact chunk.
+		b.WriteString("fact")
+		le(uint32(4))
+		w.factAt = int64(b.Len())
+		le(uint32(0))
+	}
+	b.WriteString("data")
+	w.dataSizeAt = int64(b.Len())
+	le(uint32(0))
+	w.dataOffset = int64(b.Len())
+
+	var err error
+	if w.f, err = os.Create(file); err != nil {
+		return nil, err
+	}
+	w.w = bufio.NewWriterSize(w.f, copyBlockSize)
+	if _, err := w.w.Write(b.Bytes()); err != nil {
+		w.f.Close()
+		os.Remove(file)
+		return nil, err
+	}
+	return w, nil
+}
+
+// write writes frames of samples, given by channel.
+func (w *waveWriter) write(samples [][]float64) error {
+	width := w.format.Bits / 8
+	frames := len(samples[0])
+	n := frames * w.channels * width
+	if cap(w.buf) < n {
+		w.buf = make([]byte, n)
+	}
+	buf := w.buf[:n]
+	for f := 0; f < frames; f++ {
+		for c := 0; c < w.channels; c++ {
+			w.put(buf[(f*w.channels+c)*width:], samples[c][f], c)
+		}
+	}
+	w.hash.Write(buf)
+	w.dataSize += int64(n)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// put encodes a single sample of the channel.
+func (w *waveWriter) put(b []byte, v float64, channel int) {
+	switch {
+	case w.format.Float && w.format.Bits == 64:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		return
+	case w.format.Float:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+		return
+	}
+	var rng *rand.Rand
+	if w.rngs != nil {
+		rng = w.rngs[channel]
+	}
+	q := uint32(quantize(v, w.format.Bits, rng))
+	for i := 0; i < w.format.Bits/8; i++ {
+		b[i] = byte(q >> (8 * uint(i)))
+	}
+}
+
+// ditherRands returns an independent source of dither for each channel of
+// the file of the name.
+func ditherRands(name string, channels int) []*rand.Rand {
+	rngs := make([]*rand.Rand, channels)
+	for c := range rngs {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s\x00%d", name, c)
+		rngs[c] = rand.New(rand.NewSource(int64(h.Sum64())))
+	}
+	return rngs
+}
+
+// quantize returns v, within [-1, 1), as an integer sample of the bits. The
+// sample is dithered by rng, if set.
+func quantize(v float64, bits int, rng *rand.Rand) int64 {
+	scale := float64(int64(1) << uint(bits-1))
+	x := v * scale
+	if rng != nil {
+		x += rng.Float64() - rng.Float64() // TPDF, of ±1 LSB.
+	}
+	return int64(math.Max(-scale, math.Min(scale-1, math.Floor(x+0.5))))
+}
+
+// close pads the data chunk, writes the sizes, and closes the file.
+func (w *waveWriter) close() error {
+	w.dataSum = w.hash.Sum(nil)
+	if w.dataSize%2 == 1 {
+		w.w.WriteByte(0)
+	}
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	w.size = w.dataOffset + w.dataSize + w.dataSize%2
+	frames := w.dataSize / int64(w.channels*w.format.Bits/8)
+	// The sizes, by offset.
+	sizes := map[int64][]interface{}{}
+	if w.size-chunkHeaderSize <= maxRIFFSize {
+		sizes[4] = []interface{}{uint32(w.size - chunkHeaderSize)}
+		sizes[w.dataSizeAt] = []interface{}{uint32(w.dataSize)}
+		if w.factAt > 0 {
+			sizes[w.factAt] = []interface{}{uint32(frames)}
+		}
+	} else {
+		// As RF64, the sizes are held by a ds64 chunk, written over the
+		// reserved JUNK chunk.
+		sizes[0] = []interface{}{[]byte("RF64"), uint32(rf64Size)}
+		sizes[riffHeaderSize] = []interface{}{[]byte("ds64"), uint32(ds64Size),
+			uint64(w.size - chunkHeaderSize), uint64(w.dataSize), uint64(frames), uint32(0)}
+		sizes[w.dataSizeAt] = []interface{}{uint32(rf64Size)}
+		if w.factAt > 0 {
+			sizes[w.factAt] = []interface{}{uint32(rf64Size)}
+		}
+	}
+	for at, vs := range sizes {
+		b := &bytes.Buffer{}
+		for _, v := range vs {
+			binary.Write(b, binary.LittleEndian, v)
+		}
+		if _, err := w.f.WriteAt(b.Bytes(), at); err != nil {
+			w.f.Close()
+			return err
+		}
+	}
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// abort closes the file, incomplete.
+func (w *waveWriter) abort() { w.f.Close() }
+
+// verify reads back the closed file, and verifies its headers and data. It
+// returns the hex encoded SHA-256 checksum and the size of the file.
+func (w *waveWriter) verify() (string, int64, error) {
+	f, err := os.Open(w.f.Name())
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return "", 0, err
+	}
+	if h.dataOffset != w.dataOffset || h.dataSize != w.dataSize || h.riffSize+chunkHeaderSize != w.size {
+		return "", 0, fmt.Errorf("headers differ after writing")
+	}
+	fileSum, dataSum := sha256.New(), sha256.New()
+	if _, err := io.CopyN(fileSum, f, w.dataOffset); err != nil {
+		return "", 0, err
+	}
+	if _, err := io.CopyN(io.MultiWriter(fileSum, dataSum), f, w.dataSize); err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(fileSum, f); err != nil {
+		return "", 0, err
+	}
+	if !bytes.Equal(dataSum.Sum(nil), w.dataSum) {
+		return "", 0, fmt.Errorf("checksum mismatch of the written data")
+	}
+	return hex.EncodeToString(fileSum.Sum(nil)), w.size, nil
+}
+
+//-----------------------------------------------------------------------------
+// Resampling
+
+const (
+	resampleZeros  = 32   // Zero crossings of the sinc, on each side.
+	resampleCutoff = 0.95 // Of the lower of the Nyquist frequencies.
+	resampleBeta   = 9.0  // Of the Kaiser window, for about 90 dB of stop band attenuation.
+)
+
+// resampleFilter is a polyphase windowed sinc filter, resampling by the
+// ratio up/down.
+type resampleFilter struct {
+	up, down int64
+	half     int64       // Half the filter length, in input samples.
+	phases   [][]float64 // The filter of each output phase.
+}
+
+func newResampleFilter(from, to int) *resampleFilter {
+	g := gcd(from, to)
+	f := &resampleFilter{up: int64(to / g), down: int64(from / g)}
+	fc := resampleCutoff * math.Min(1, float64(f.up)/float64(f.down))
+	f.half = int64(math.Ceil(resampleZeros / fc))
+	f.phases = make([][]float64, f.up)
+	for p := range f.phases {
+		taps := make([]float64, 2*f.half)
+		sum := 0.0
+		for j := range taps {
+			// Distance of the input sample from the output sample.
+			d := float64(int64(j)-f.half+1) - float64(p)/float64(f.up)
+			taps[j] = fc * sinc(fc*d) * kaiser(d/float64(f.half), resampleBeta)
+			sum += taps[j]
+		}
+		for j := range taps {
+			taps[j] /= sum // Unity gain.
+		}
+		f.phases[p] = taps
+	}
+	return f
+}
+
+// resampler returns a resampler of a single channel.
+func (f *resampleFilter) resampler() *resampler {
+	// The input is preceded by silence.
+	return &resampler{resampleFilter: f, buf: make([]float64, f.half-1), base: -(f.half - 1)}
+}
+
+// resampler resamples a single channel, as a stream.
+type resampler struct {
+	*resampleFilter
+	buf  []float64 // Input samples, from index base.
+	base int64
+	in   int64 // Input samples pushed.
+	n    int64 // The next output sample.
+	out  []float64
+}
+
+// push adds input samples, and returns the output samples now available. The
+// output is only valid until the next call.
+func (r *resampler) push(in []float64) []float64 {
+	r.buf = append(r.buf, in...)
+	r.in += int64(len(in))
+	return r.process(math.MaxInt64)
+}
+
+// flush returns the remaining output samples, once all input was pushed.
+func (r *resampler) flush() []float64 {
+	// The input is followed by silence.
+	r.buf = append(r.buf, make([]float64, r.half)...)
+	return r.process((r.in*r.up + r.down - 1) / r.down)
+}
+
+// process returns the output samples up to total, as far as the input allows.
+func (r *resampler) process(total int64) []float64 {
+	r.out = r.out[:0]
+	end := r.base + int64(len(r.buf)) // Past the last input sample.
+	for ; r.n < total; r.n++ {
+		pos := r.n * r.down
+		i, p := pos/r.up, pos%r.up
+		if i+r.half >= end {
+			break
+		}
+		x := r.buf[i-r.half+1-r.base:]
+		v := 0.0
+		for j, t := range r.phases[p] {
+			v += x[j] * t
+		}
+		r.out = append(r.out, v)
+	}
+
+	// Drop the input no longer needed.
+	drop := r.n*r.down/r.up - r.half + 1 - r.base
+	if drop > int64(len(r.buf)) {
+		drop = int64(len(r.buf))
+	}
+	if drop > 0 {
+		r.buf = r.buf[:copy(r.buf, r.buf[drop:])]
+		r.base += drop
+	}
+	return r.out
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser returns the Kaiser window at x, within [-1, 1].
+func kaiser(x, beta float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return bessel0(beta*math.Sqrt(1-x*x)) / bessel0(beta)
+}
+
+// bessel0 returns the zeroth order modified Bessel function of the first kind.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		t := x / (2 * float64(k))
+		term *= t * t
+		sum += term
+	}
+	return sum
+}
@@ -0,0 +1,276 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kward/tracks/actions/wavetest"
+)
+
+// writeTestWave writes a wave file of interleaved samples in the format,
+// preceded by a bext chunk with the time reference.
+func writeTestWave(t *testing.T, file string, f SampleFormat, rate, channels int, samples []float64, timeRef uint64) {
+	w := wavetest.Wave{
+		Bits:        f.Bits,
+		Float:       f.Float,
+		Rate:        rate,
+		Channels:    channels,
+		Samples:     samples,
+		Description: "A test",
+		TimeRef:     timeRef,
+	}
+	if err := ioutil.WriteFile(file, w.Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+}
+
+// readTestWave returns the header and the samples of a wave file.
+func readTestWave(t *testing.T, file string) (*waveHeader, []float64, []byte) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	h, err := readWaveHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("readWaveHeader() unexpected error %s", err)
+	}
+	decode, err := sampleDecoder(h)
+	if err != nil {
+		t.Fatalf("sampleDecoder() unexpected error %s", err)
+	}
+	width := int64(h.bitsPerSample / 8)
+	samples := []float64{}
+	for o := h.dataOffset; o < h.dataOffset+h.dataSize; o += width {
+		samples = append(samples, decode(data[o:]))
+	}
+	var bext []byte
+	for _, c := range h.chunks {
+		if c.id == "bext" {
+			bext = data[c.offset : c.offset+c.size]
+		}
+	}
+	return h, samples, bext
+}
+
+func TestParseSampleFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		ok     bool
+		format SampleFormat
+	}{
+		{"16", true, Int16},
+		{"24", true, Int24},
+		{"32", true, Int32},
+		{"32f", true, Float32},
+		{"float", true, Float32},
+		{"8", false, SampleFormat{}},
+	} {
+		f, err := ParseSampleFormat(tt.name)
+		if err == nil && !tt.ok {
+			t.Errorf("ParseSampleFormat(%q) expected error", tt.name)
+			continue
+		}
+		if err != nil && tt.ok {
+			t.Errorf("ParseSampleFormat(%q) unexpected error %s", tt.name, err)
+			continue
+		}
+		if got, want := f, tt.format; got != want {
+			t.Errorf("ParseSampleFormat(%q) = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A stereo 1 kHz sine, at -6 dBFS on the left, and silent on the right.
+	sine := func(rate, frames int) []float64 {
+		s := make([]float64, 2*frames)
+		for i := 0; i < frames; i++ {
+			s[2*i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i)/float64(rate))
+		}
+		return s
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		format    SampleFormat
+		rate      int
+		conv      *Converter
+		outFormat SampleFormat
+		outRate   int
+		tolerance float64 // Of the output samples, 0 if exact.
+	}{
+		{"float to 24-bit", Float32, 48000, NewConverter(Int24, 0), Int24, 48000, 1.5 / (1 << 23)},
+		{"16 to 24-bit", Int16, 48000, NewConverter(Int24, 0), Int24, 48000, 0},
+		{"96 to 48 kHz", Float32, 96000, NewConverter(Int24, 48000), Int24, 48000, 1e-4},
+		{"48 to 44.1 kHz", Int24, 48000, NewConverter(SampleFormat{}, 44100), Int24, 44100, 1e-4},
+		{"44.1 to 48 kHz", Float32, 44100, NewConverter(Float32, 48000), Float32, 48000, 1e-4},
+	} {
+		frames := tt.rate / 10
+		src, dest := filepath.Join(dir, "src.wav"), filepath.Join(dir, "dest.wav")
+		writeTestWave(t, src, tt.format, tt.rate, 2, sine(tt.rate, frames), uint64(tt.rate)*3600)
+		sum, res, err := tt.conv.Convert(src, dest)
+		if err != nil {
+			t.Errorf("%s: Convert() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if got, err := FileChecksum(dest); err != nil || got != sum {
+			t.Errorf("%s: Convert() = %s, want %s", tt.desc, sum, got)
+		}
+		fi, err := os.Stat(dest)
+		if err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		if got, want := res.Bytes, fi.Size(); got != want {
+			t.Errorf("%s: Convert() wrote %d bytes, want %d", tt.desc, got, want)
+		}
+
+		h, samples, bext := readTestWave(t, dest)
+		if got, want := (SampleFormat{int(h.bitsPerSample), h.format == waveFormatFloat}), tt.outFormat; got != want {
+			t.Errorf("%s: format = %s, want %s", tt.desc, got, want)
+		}
+		if got, want := int(h.sampleRate), tt.outRate; got != want {
+			t.Errorf("%s: sample rate = %d, want %d", tt.desc, got, want)
+		}
+		if got, want := len(samples), 2*tt.outRate/10; got != want {
+			t.Errorf("%s: %d samples, want %d", tt.desc, got, want)
+		}
+		if bext == nil {
+			t.Errorf("%s: missing bext chunk", tt.desc)
+		} else if got, want := binary.LittleEndian.Uint64(bext[bextTimeReferenceAt:]), uint64(tt.outRate)*3600; got != want {
+			t.Errorf("%s: bext time reference = %d, want %d", tt.desc, got, want)
+		}
+
+		// Compare with the sine at the output rate, away from the edges.
+		want := sine(tt.outRate, tt.outRate/10)
+		worst := 0.0
+		for i := 200; i < len(samples)-200; i++ {
+			worst = math.Max(worst, math.Abs(samples[i]-want[i]))
+		}
+		if tt.tolerance == 0 {
+			_, in, _ := readTestWave(t, src)
+			for i := range samples {
+				if samples[i] != in[i] {
+					t.Errorf("%s: sample %d = %g, want %g", tt.desc, i, samples[i], in[i])
+					break
+				}
+			}
+		} else if worst > tt.tolerance {
+			t.Errorf("%s: samples differ by up to %g, want at most %g", tt.desc, worst, tt.tolerance)
+		}
+		os.Remove(dest)
+	}
+}
+
+func TestConvertDither(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A level of 0.3 LSB is lost when truncated to 16 bits, but survives on
+	// average with dither.
+	const frames, level = 48000, 0.3
+	samples := make([]float64, frames)
+	for i := range samples {
+		samples[i] = level / (1 << 15)
+	}
+	src, dest := filepath.Join(dir, "src.wav"), filepath.Join(dir, "dest.wav")
+	writeTestWave(t, src, Float32, 48000, 1, samples, 0)
+	if _, _, err := NewConverter(Int16, 0).Convert(src, dest); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	_, got, _ := readTestWave(t, dest)
+	sum, max := 0.0, 0.0
+	for _, v := range got {
+		sum += v * (1 << 15)
+		max = math.Max(max, math.Abs(v*(1<<15)))
+	}
+	if mean := sum / float64(len(got)); math.Abs(mean-level) > 0.05 {
+		t.Errorf("Convert() mean = %g LSB, want %g", mean, level)
+	}
+	if max > 1 {
+		t.Errorf("Convert() dither of %g LSB, want at most 1", max)
+	}
+
+	// Converting again writes the same data.
+	sum1, err := FileChecksum(dest)
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if _, _, err := NewConverter(Int16, 0).Convert(src, dest); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	if sum2, _ := FileChecksum(dest); sum1 != sum2 {
+		t.Errorf("Convert() is not repeatable")
+	}
+
+	// The tracks of a session, and the channels of a file, don't share their
+	// dither, which would add up coherently in the mix.
+	correlation := func(a, b []float64) float64 {
+		var ma, mb float64
+		for i := range a {
+			ma, mb = ma+a[i]/float64(len(a)), mb+b[i]/float64(len(b))
+		}
+		var ab, aa, bb float64
+		for i := range a {
+			x, y := a[i]-ma, b[i]-mb
+			ab, aa, bb = ab+x*y, aa+x*x, bb+y*y
+		}
+		return ab / math.Sqrt(aa*bb)
+	}
+	other, otherDest := filepath.Join(dir, "other.wav"), filepath.Join(dir, "other dest.wav")
+	writeTestWave(t, other, Float32, 48000, 1, samples, 0)
+	if _, _, err := NewConverter(Int16, 0).Convert(other, otherDest); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	_, otherGot, _ := readTestWave(t, otherDest)
+	if c := correlation(got, otherGot); math.Abs(c) > 0.05 {
+		t.Errorf("Convert() dither of two files correlates by %g", c)
+	}
+	stereo := make([]float64, 2*frames)
+	for i := range stereo {
+		stereo[i] = level / (1 << 15)
+	}
+	writeTestWave(t, src, Float32, 48000, 2, stereo, 0)
+	if _, _, err := NewConverter(Int16, 0).Convert(src, dest); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	_, got, _ = readTestWave(t, dest)
+	left, right := []float64{}, []float64{}
+	for i := 0; i < len(got); i += 2 {
+		left, right = append(left, got[i]), append(right, got[i+1])
+	}
+	if c := correlation(left, right); math.Abs(c) > 0.05 {
+		t.Errorf("Convert() dither of two channels correlates by %g", c)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src, dest := filepath.Join(dir, "src.wav"), filepath.Join(dir, "dest.wav")
+	if err := ioutil.WriteFile(src, []byte("not a wave file"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if _, _, err := NewConverter(Int24, 0).Convert(src, dest); err == nil {
+		t.Errorf("Convert() expected error")
+	}
+	if _, err := os.Stat(dest + partialSuffix); err == nil {
+		t.Errorf("Convert() left a partial file")
+	}
+}
@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kward/tracks/venue"
+)
+
+// Copier copies files to a destination and any backup directories at once,
+// verifying every copy, as the copy command does. The copies may be converted,
+// and are recorded in a copy state, so that an interrupted copy can be resumed.
+// Its Op is a FileOp for an Executor.
+type Copier struct {
+	BackupDirs []string     // Also copied to, under the name of the destination.
+	State      *CopyState   // Records the verified copies, if set; those recorded are skipped.
+	Convert    *Converter   // Converts the copies, if set.
+	Venue      *venue.Venue // Tags FLAC encoded conversions with its show, if set.
+	// Copied, if set, is called with each verified copy. It may be called
+	// concurrently, by an Executor running several jobs.
+	Copied func(op *Operation, r CopyResult)
+}
+
+// Dests returns the destinations of op: op.Dest, then the same file in each
+// of the backup directories.
+func (c *Copier) Dests(op *Operation) []string {
+	dests := []string{op.Dest}
+	for _, dir := range c.BackupDirs {
+		dests = append(dests, filepath.Join(dir, filepath.Base(op.Dest)))
+	}
+	return dests
+}
+
+// Copies returns the copy to each destination of op, in the order of Dests().
+// Until op is performed, they share its status.
+func (c *Copier) Copies(op *Operation) []*Operation {
+	if op.Copies != nil {
+		return op.Copies
+	}
+	copies := []*Operation{}
+	for _, dest := range c.Dests(op) {
+		cp := *op
+		cp.Dest = dest
+		copies = append(copies, &cp)
+	}
+	return copies
+}
+
+// Op copies op.Src to each of its destinations not recorded in the state,
+// setting the status of each in op.Copies. If all of them are recorded,
+// op.Status is set to OperationSkipped. The verified copies are kept, and
+// recorded, even if another of them fails.
+func (c *Copier) Op(op *Operation) error {
+	op.Copies = c.Copies(op)
+	copies, dests := []*Operation{}, []string{}
+	for _, cp := range op.Copies {
+		if c.State.Done(cp.Dest) {
+			cp.Status = OperationSkipped
+			continue
+		}
+		copies, dests = append(copies, cp), append(dests, cp.Dest)
+	}
+	if len(copies) == 0 {
+		op.Status = OperationSkipped
+		return nil
+	}
+	var comments []string
+	if c.Convert != nil && c.Convert.Encoding == FLACEncoding {
+		comments = TrackComments(c.Venue, &PlanEntry{
+			Session: op.Session,
+			Track:   op.Track,
+			Name:    op.Name,
+			Channel: op.Channel,
+		})
+	}
+	_, results, err := c.Copy(op.Src, dests, comments...)
+	for i, r := range results {
+		if r.Err != nil {
+			copies[i].Status, copies[i].Error = OperationFailed, r.Err.Error()
+			continue
+		}
+		copies[i].Status = OperationDone
+		if c.Copied != nil {
+			c.Copied(copies[i], r)
+		}
+	}
+	return err
+}
+
+// Copy copies, or converts, src to each of dests, and records the verified
+// copies in the state. A conversion is written to the first destination, and
+// copied from there to the others. FLAC encoded conversions are tagged with
+// the comments. It returns the checksum of the data, and the result of each
+// destination, as CopyVerified() does.
+func (c *Copier) Copy(src string, dests []string, comments ...string) (string, []CopyResult, error) {
+	sum, results, err := c.copy(src, dests, comments...)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if rerr := c.State.Record(r.Dest, sum, r.Bytes); rerr != nil {
+			return sum, results, fmt.Errorf("error saving copy state; %s", rerr)
+		}
+	}
+	return sum, results, err
+}
+
+func (c *Copier) copy(src string, dests []string, comments ...string) (string, []CopyResult, error) {
+	if c.Convert == nil {
+		sum, results, err := CopyVerified(src, dests...)
+		if results == nil {
+			results = failedCopies(dests, err)
+		}
+		return sum, results, err
+	}
+	sum, r, err := c.Convert.Convert(src, dests[0], comments...)
+	if err != nil {
+		return "", failedCopies(dests, err), err
+	}
+	if len(dests) == 1 {
+		return sum, []CopyResult{r}, nil
+	}
+	_, rs, err := CopyVerified(dests[0], dests[1:]...)
+	if rs == nil {
+		rs = failedCopies(dests[1:], err)
+	}
+	return sum, append([]CopyResult{r}, rs...), err
+}
+
+// failedCopies returns the results of copies to dests that all failed.
+func failedCopies(dests []string, err error) []CopyResult {
+	results := []CopyResult{}
+	for _, dest := range dests {
+		results = append(results, CopyResult{Dest: dest, Err: err})
+	}
+	return results
+}
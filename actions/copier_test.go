@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopierOp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copier_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"src", "dest", "backup"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	for _, f := range []string{"Track 01-1.wav", "Track 02-1.wav"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "src", f), []byte(f), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	plan := &Plan{
+		SrcDir:  filepath.Join(dir, "src"),
+		DestDir: filepath.Join(dir, "dest"),
+		Entries: []*PlanEntry{
+			{Src: "Track 01-1.wav", Dest: "01-01 Kick.wav"},
+			{Src: "Track 02-1.wav", Dest: "01-02 Snare.wav"},
+		},
+	}
+	state, err := LoadCopyState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("LoadCopyState() unexpected error %s", err)
+	}
+	copied := 0
+	c := &Copier{
+		BackupDirs: []string{filepath.Join(dir, "backup")},
+		State:      state,
+		Copied:     func(*Operation, CopyResult) { copied++ },
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		status OperationStatus
+	}{
+		{"copy", OperationDone},
+		{"resume", OperationSkipped},
+	} {
+		ops, err := NewExecutor(c.Op).Execute(plan)
+		if err != nil {
+			t.Fatalf("%s: Execute() unexpected error %s", tt.desc, err)
+		}
+		for _, op := range ops {
+			if op.Status != tt.status {
+				t.Errorf("%s: %q status = %s, want %s", tt.desc, op.Dest, op.Status, tt.status)
+			}
+			for _, dest := range c.Dests(op) {
+				if !state.Done(dest) {
+					t.Errorf("%s: %q not recorded", tt.desc, dest)
+				}
+			}
+		}
+	}
+
+	if got, want := copied, 4; got != want {
+		t.Errorf("Copied() called %d times, want %d", got, want)
+	}
+
+	// A missing backup directory fails the copy, but the verified copy to the
+	// destination is kept, and recorded.
+	c.BackupDirs = []string{filepath.Join(dir, "missing")}
+	plan.Entries = []*PlanEntry{{Src: "Track 01-1.wav", Dest: "01-01 Bass drum.wav"}}
+	ops, err := NewExecutor(c.Op).Execute(plan)
+	if err == nil {
+		t.Fatalf("Execute() to a missing backup expected error")
+	}
+	if got, want := ops[0].Status, OperationFailed; got != want {
+		t.Errorf("Execute() status = %s, want %s", got, want)
+	}
+	for i, want := range []OperationStatus{OperationDone, OperationFailed} {
+		if got := ops[0].Copies[i].Status; got != want {
+			t.Errorf("Execute() %q status = %s, want %s", ops[0].Copies[i].Dest, got, want)
+		}
+	}
+	if !state.Done(ops[0].Dest) {
+		t.Errorf("Execute() didn't record the verified copy")
+	}
+}
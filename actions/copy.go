@@ -0,0 +1,236 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	copyBlockSize = 1 << 20
+	partialSuffix = ".partial"
+)
+
+// CopyResult describes the copy of a file to one destination.
+type CopyResult struct {
+	Dest     string
+	Bytes    int64
+	Duration time.Duration // Time spent writing and verifying.
+	Err      error         // Of the copy to Dest, if it failed.
+}
+
+// copyDest is a destination file being written.
+type copyDest struct {
+	path string
+	f    *os.File
+	res  CopyResult
+	err  error
+}
+
+// CopyVerified copies src to each of dests concurrently, from a single read of
+// src. Each copy is written to a partial file, read back and verified against
+// the SHA-256 checksum of src, before being renamed into place. A failed copy,
+// e.g. to a full disk, doesn't stop the others. It returns the hex encoded
+// checksum of src, and the result of each copy, in the order of dests. The
+// error is that of the first failed copy, whose result holds it as well.
+func CopyVerified(src string, dests ...string) (string, []CopyResult, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", nil, err
+	}
+	defer in.Close()
+
+	ds := make([]*copyDest, len(dests))
+	for i, path := range dests {
+		ds[i] = &copyDest{path: path, res: CopyResult{Dest: path}}
+		ds[i].f, ds[i].err = os.Create(path + partialSuffix)
+	}
+
+	h := sha256.New()
+	buf := make([]byte, copyBlockSize)
+	var wg sync.WaitGroup
+	for {
+		n, rerr := in.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			for _, d := range ds {
+				if d.err != nil {
+					continue
+				}
+				wg.Add(1)
+				go func(d *copyDest) {
+					defer wg.Done()
+					start := time.Now()
+					if _, d.err = d.f.Write(buf[:n]); d.err == nil {
+						d.res.Bytes += int64(n)
+					}
+					d.res.Duration += time.Since(start)
+				}(d)
+			}
+			wg.Wait() // The buffer is reused by the next read.
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			removePartials(ds)
+			err := fmt.Errorf("error reading %q; %s", src, rerr)
+			results := make([]CopyResult, len(ds))
+			for i, d := range ds {
+				results[i] = CopyResult{Dest: d.path, Err: err}
+			}
+			return "", results, err
+		}
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	for _, d := range ds {
+		wg.Add(1)
+		go func(d *copyDest) {
+			defer wg.Done()
+			start := time.Now()
+			d.err = d.finish(sum)
+			d.res.Duration += time.Since(start)
+		}(d)
+	}
+	wg.Wait()
+
+	results := make([]CopyResult, len(ds))
+	err = nil
+	for i, d := range ds {
+		if d.err != nil {
+			removePartials(ds[i : i+1])
+			d.res.Bytes, d.res.Err = 0, fmt.Errorf("error copying to %q; %s", d.path, d.err)
+			if err == nil {
+				err = d.res.Err
+			}
+		}
+		results[i] = d.res
+	}
+	return sum, results, err
+}
+
+// finish closes the partial file, verifies it, and renames it into place.
+func (d *copyDest) finish(sum string) error {
+	if d.err != nil {
+		if d.f != nil {
+			d.f.Close()
+		}
+		return d.err
+	}
+	if err := d.f.Sync(); err != nil {
+		d.f.Close()
+		return err
+	}
+	if err := d.f.Close(); err != nil {
+		return err
+	}
+	got, err := FileChecksum(d.f.Name())
+	if err != nil {
+		return err
+	}
+	if got != sum {
+		return fmt.Errorf("checksum mismatch; got %s, want %s", got, sum)
+	}
+	return os.Rename(d.f.Name(), d.path)
+}
+
+// removePartials removes the partial files left by a failed copy.
+func removePartials(ds []*copyDest) {
+	for _, d := range ds {
+		if d.f != nil {
+			d.f.Close()
+			os.Remove(d.f.Name())
+		}
+	}
+}
+
+// FileChecksum returns the hex encoded SHA-256 checksum of a file.
+func FileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CopyState records the verified copies, so that an interrupted copy can be
+// resumed. A nil CopyState records nothing. It is safe for concurrent use.
+type CopyState struct {
+	mu     sync.Mutex // Guards Copied, and the file.
+	file   string
+	Copied map[string]CopyStateEntry `json:"copied"` // Keyed by destination path.
+}
+
+// CopyStateEntry describes a verified copy.
+type CopyStateEntry struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// LoadCopyState reads the copy state from file. A missing file returns an
+// empty state.
+func LoadCopyState(file string) (*CopyState, error) {
+	s := &CopyState{file: file, Copied: map[string]CopyStateEntry{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error parsing copy state %q; %s", file, err)
+	}
+	if s.Copied == nil {
+		s.Copied = map[string]CopyStateEntry{}
+	}
+	return s, nil
+}
+
+// Done returns true if dest was copied, and is still the same size.
+func (s *CopyState) Done(dest string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	e, ok := s.Copied[dest]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	fi, err := os.Stat(dest)
+	return err == nil && fi.Size() == e.Size
+}
+
+// Record a verified copy, and save the state.
+func (s *CopyState) Record(dest, sum string, size int64) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Copied[dest] = CopyStateEntry{SHA256: sum, Size: size}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so an interruption can't corrupt the state.
+	tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
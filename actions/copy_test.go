@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// More than one block, so that the buffer is reused.
+	data := bytes.Repeat([]byte("0123456789abcdef"), copyBlockSize/8+3)
+	src := filepath.Join(dir, "src.wav")
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		dests []string
+		ok    bool
+	}{
+		{"one", []string{"a/one.wav"}, true},
+		{"two", []string{"a/two.wav", "b/two.wav"}, true},
+		{"missing dir", []string{"a/three.wav", "c/three.wav"}, false},
+	} {
+		dests := []string{}
+		for _, d := range tt.dests {
+			dests = append(dests, filepath.Join(dir, d))
+		}
+		sum, results, err := CopyVerified(src, dests...)
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: CopyVerified() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if len(results) != len(dests) {
+			t.Fatalf("%s: CopyVerified() returned %d results, want %d", tt.desc, len(results), len(dests))
+		}
+		if !tt.ok {
+			for _, d := range dests {
+				if _, err := os.Stat(d + partialSuffix); err == nil {
+					t.Errorf("%s: partial file %q left behind", tt.desc, d+partialSuffix)
+				}
+			}
+			// The copy to the other destination is kept.
+			if results[0].Err != nil || results[1].Err == nil {
+				t.Errorf("%s: CopyVerified() errors = %v, %v; want only the second", tt.desc, results[0].Err, results[1].Err)
+			}
+			if got, err := ioutil.ReadFile(dests[0]); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s: %q differs from source; %v", tt.desc, dests[0], err)
+			}
+			continue
+		}
+		want, _ := FileChecksum(src)
+		if sum != want {
+			t.Errorf("%s: CopyVerified() checksum = %s, want %s", tt.desc, sum, want)
+		}
+		for i, d := range dests {
+			got, err := ioutil.ReadFile(d)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s: %q differs from source; %v", tt.desc, d, err)
+			}
+			if got, want := results[i].Bytes, int64(len(data)); got != want {
+				t.Errorf("%s: %q bytes = %d, want %d", tt.desc, d, got, want)
+			}
+		}
+	}
+}
+
+func TestCopyState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "dest.wav")
+	if err := ioutil.WriteFile(dest, []byte("data"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	var nilState *CopyState
+	if nilState.Done(dest) {
+		t.Errorf("nil Done() = true, want false")
+	}
+	if err := nilState.Record(dest, "sum", 4); err != nil {
+		t.Errorf("nil Record() unexpected error %s", err)
+	}
+
+	file := filepath.Join(dir, "state.json")
+	s, err := LoadCopyState(file)
+	if err != nil {
+		t.Fatalf("LoadCopyState() unexpected error %s", err)
+	}
+	if s.Done(dest) {
+		t.Errorf("Done() = true before Record()")
+	}
+	if err := s.Record(dest, "sum", 4); err != nil {
+		t.Fatalf("Record() unexpected error %s", err)
+	}
+
+	// Reload the state, as when resuming.
+	if s, err = LoadCopyState(file); err != nil {
+		t.Fatalf("LoadCopyState() unexpected error %s", err)
+	}
+	if !s.Done(dest) {
+		t.Errorf("Done() = false after Record()")
+	}
+	if err := ioutil.WriteFile(dest, []byte("truncated?"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if s.Done(dest) {
+		t.Errorf("Done() = true after the size changed")
+	}
+}
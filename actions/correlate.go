@@ -0,0 +1,236 @@
+package actions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// DefaultMaxLag is the greatest time offset looked for between tracks, about
+	// three meters of sound.
+	DefaultMaxLag = 10 * time.Millisecond
+	// MinCorrelation is the least correlation, in either polarity, of tracks
+	// recorded from the same source.
+	MinCorrelation = 0.2
+
+	correlationSeconds = 10    // Of signal analyzed.
+	correlationFloor   = 0.001 // The RMS level of signal, -60 dBFS.
+)
+
+// Correlation describes the polarity of, and time offset between, two tracks
+// of the same source.
+type Correlation struct {
+	A           string  `json:"a" yaml:"a"`
+	B           string  `json:"b" yaml:"b"`
+	Coefficient float64 `json:"coefficient" yaml:"coefficient"` // Within [-1, 1], at the offset.
+	Offset      int     `json:"offset" yaml:"offset"`           // Frames B is late of A.
+	Delay       Seconds `json:"delay" yaml:"delay"`             // Of B, as a time.
+	Analyzed    Seconds `json:"analyzed" yaml:"analyzed"`       // Of signal in both tracks.
+	Conclusive  bool    `json:"conclusive" yaml:"conclusive"`
+	Inverted    bool    `json:"inverted" yaml:"inverted"`
+}
+
+// String implements the fmt.Stringer interface.
+func (c *Correlation) String() string {
+	switch {
+	case !c.Conclusive:
+		return fmt.Sprintf("inconclusive, correlation %.2f", c.Coefficient)
+	case c.Inverted:
+		return fmt.Sprintf("inverted polarity, offset %s (%d frames), correlation %.2f", c.Delay, c.Offset, c.Coefficient)
+	}
+	return fmt.Sprintf("same polarity, offset %s (%d frames), correlation %.2f", c.Delay, c.Offset, c.Coefficient)
+}
+
+// Correlate returns the cross-correlation of the wave files a and b, at the
+// offset of up to maxLag where it is strongest. The files are read a second at
+// a time, and the first seconds in which both have signal are analyzed.
+func Correlate(a, b string, maxLag time.Duration) (*Correlation, error) {
+	ra, err := waveReader(a)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q; %s", a, err)
+	}
+	defer ra.Close()
+	rb, err := waveReader(b)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q; %s", b, err)
+	}
+	defer rb.Close()
+	rate := ra.SampleRate()
+	if rb.SampleRate() != rate {
+		return nil, fmt.Errorf("sample rates differ, %d and %d Hz", rate, rb.SampleRate())
+	}
+	if ra.ChannelCount() < 1 || rb.ChannelCount() < 1 {
+		return nil, fmt.Errorf("invalid channel count")
+	}
+	lag := int(maxLag.Seconds() * float64(rate))
+	if lag < 0 {
+		return nil, fmt.Errorf("invalid maximum lag %s", maxLag)
+	}
+
+	// Each block is mixed to mono.
+	blkA, blkB := make([]float32, rate*ra.ChannelCount()), make([]float32, rate*rb.ChannelCount())
+	monoA, monoB := make([]float64, rate), make([]float64, rate)
+	mono := func(r *waveFile, blk []float32, mono []float64) ([]float64, error) {
+		n, err := r.ReadBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+		channels := r.ChannelCount()
+		frames := n / channels
+		for f := 0; f < frames; f++ {
+			sum := 0.0
+			for _, v := range blk[f*channels : (f+1)*channels] {
+				sum += float64(v)
+			}
+			mono[f] = sum / float64(channels)
+		}
+		return mono[:frames], nil
+	}
+	energy := func(x []float64) float64 {
+		e := 0.0
+		for _, v := range x {
+			e += v * v
+		}
+		return e
+	}
+
+	// sums holds the sum of the products of the samples, by lag.
+	sums := make([]float64, 2*lag+1)
+	energyA, energyB := 0.0, 0.0
+	analyzed := 0
+	for analyzed < correlationSeconds*rate {
+		xa, err := mono(ra, blkA, monoA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %q; %s", a, err)
+		}
+		xb, err := mono(rb, blkB, monoB)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %q; %s", b, err)
+		}
+		n := len(xa)
+		if len(xb) < n {
+			n = len(xb)
+		}
+		if n == 0 {
+			break
+		}
+		xa, xb = xa[:n], xb[:n]
+		ea, eb := energy(xa), energy(xb)
+		floor := correlationFloor * correlationFloor * float64(n)
+		if ea < floor || eb < floor {
+			continue
+		}
+		for k := -lag; k <= lag; k++ {
+			sum := 0.0
+			t := 0
+			if k < 0 {
+				t = -k
+			}
+			for ; t < n && t+k < n; t++ {
+				sum += xa[t] * xb[t+k]
+			}
+			sums[k+lag] += sum
+		}
+		energyA += ea
+		energyB += eb
+		analyzed += n
+	}
+
+	c := &Correlation{A: a, B: b, Analyzed: Seconds(time.Duration(analyzed) * time.Second / time.Duration(rate))}
+	if analyzed == 0 {
+		return c, nil
+	}
+	best := 0
+	for i, s := range sums {
+		if math.Abs(s) > math.Abs(sums[best]) {
+			best = i
+		}
+	}
+	c.Coefficient = sums[best] / math.Sqrt(energyA*energyB)
+	c.Offset = best - lag
+	c.Delay = Seconds(time.Duration(c.Offset) * time.Second / time.Duration(rate))
+	c.Conclusive = math.Abs(c.Coefficient) >= MinCorrelation
+	c.Inverted = c.Conclusive && c.Coefficient < 0
+	return c, nil
+}
+
+//-----------------------------------------------------------------------------
+// Related tracks
+
+// micPositions are the words which place one of the microphones of a source.
+var micPositions = map[string]bool{
+	"in": true, "out": true, "inside": true, "outside": true,
+	"top": true, "bottom": true, "btm": true, "bot": true, "t": true, "b": true,
+	"up": true, "down": true, "dn": true, "front": true, "back": true,
+	"oben": true, "unten": true, // German.
+	"di": true, "amp": true, "sub": true,
+}
+
+// stereoSides are the words which name a side of a stereo source.
+var stereoSides = map[string]bool{"l": true, "r": true, "left": true, "right": true}
+
+// trackSource splits the name of a track into the source recorded, e.g.
+// "snare" or "tom 1", and a description of the microphone, e.g. "top sm57".
+// The source is the first word of the name, with a single digit number after
+// it, while a longer number is taken to be a microphone model, e.g. "Kick 91".
+// The sides of stereo sources are ignored. Only the first of a list of names,
+// e.g. "Kick 91, iKick", is looked at.
+func trackSource(name string) (string, string) {
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	words := []string{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_' || r == '/'
+	}) {
+		if !stereoSides[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return "", ""
+	}
+	source, rest := words[0], words[1:]
+	if len(rest) > 0 && len(rest[0]) == 1 && unicode.IsDigit(rune(rest[0][0])) {
+		source, rest = source+" "+rest[0], rest[1:]
+	}
+	return source, strings.Join(rest, " ")
+}
+
+// RelatedTracks returns the pairs of indexes of the names of tracks recorded
+// from the same source with different microphones, e.g. "Kick In" and
+// "Kick Out", or "Snare T SM57" and "Snare B SM57". The first track of each
+// source is paired with each of the others. Names which neither place a
+// microphone nor name its model, e.g. "Vox Lead", are not related.
+func RelatedTracks(names []string) [][2]int {
+	pairs := [][2]int{}
+	first := map[string]int{} // Index of the first track, by source.
+	seen := map[string]bool{} // Sources and microphones.
+	for i, name := range names {
+		source, mic := trackSource(name)
+		if source == "" || !describesMic(mic) || seen[source+"\x00"+mic] {
+			continue
+		}
+		seen[source+"\x00"+mic] = true
+		if j, ok := first[source]; ok {
+			pairs = append(pairs, [2]int{j, i})
+			continue
+		}
+		first[source] = i
+	}
+	return pairs
+}
+
+// describesMic returns true if the description of a microphone places it, e.g.
+// "in" or "top", or names its model, e.g. "91" or "sm57".
+func describesMic(mic string) bool {
+	for _, w := range strings.Fields(mic) {
+		if micPositions[w] || strings.IndexFunc(w, unicode.IsDigit) >= 0 {
+			return true
+		}
+	}
+	return false
+}
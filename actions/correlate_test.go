@@ -0,0 +1,122 @@
+package actions
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCorrelate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "correlate_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two seconds of noise, after a second of silence.
+	const rate = 8000
+	rng := rand.New(rand.NewSource(1))
+	noise := func() []float64 {
+		s := make([]float64, 3*rate)
+		for i := rate; i < len(s); i++ {
+			s[i] = 0.5 * (rng.Float64()*2 - 1)
+		}
+		return s
+	}
+	src := noise()
+	// delayed returns the source, late by the frames and scaled by gain.
+	delayed := func(frames int, gain float64) []float64 {
+		s := make([]float64, len(src))
+		for i := range s {
+			if j := i - frames; j >= 0 && j < len(src) {
+				s[i] = gain * src[j]
+			}
+		}
+		return s
+	}
+	file := func(name string, samples []float64) string {
+		f := filepath.Join(dir, name)
+		writeTestWave(t, f, Int16, rate, 1, samples, 0)
+		return f
+	}
+	a := file("a.wav", src)
+
+	for _, tt := range []struct {
+		desc     string
+		b        []float64
+		offset   int
+		inverted bool
+		ok       bool // Conclusive.
+	}{
+		{"same", src, 0, false, true},
+		{"inverted and late", delayed(20, -0.5), 20, true, true},
+		{"early", delayed(-7, 0.8), -7, false, true},
+		{"unrelated", noise(), 0, false, false},
+		{"silent", make([]float64, len(src)), 0, false, false},
+	} {
+		c, err := Correlate(a, file("b.wav", tt.b), DefaultMaxLag)
+		if err != nil {
+			t.Errorf("%s: Correlate() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if c.Conclusive != tt.ok {
+			t.Errorf("%s: Correlate() conclusive = %t, want %t; %s", tt.desc, c.Conclusive, tt.ok, c)
+		}
+		if !tt.ok {
+			continue
+		}
+		if c.Offset != tt.offset || c.Inverted != tt.inverted {
+			t.Errorf("%s: Correlate() = offset %d, inverted %t; want %d, %t", tt.desc, c.Offset, c.Inverted, tt.offset, tt.inverted)
+		}
+		if got, want := c.Analyzed, Seconds(2*time.Second); got != want {
+			t.Errorf("%s: Correlate() analyzed %s, want %s", tt.desc, got, want)
+		}
+	}
+
+	if _, err := Correlate(a, file("c.wav", src[:rate]), -time.Millisecond); err == nil {
+		t.Errorf("Correlate() of a negative lag expected error")
+	}
+	c := filepath.Join(dir, "c.wav")
+	writeTestWave(t, c, Int16, 2*rate, 1, src, 0)
+	if _, err := Correlate(a, c, DefaultMaxLag); err == nil {
+		t.Errorf("Correlate() of different sample rates expected error")
+	}
+}
+
+func TestRelatedTracks(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		names []string
+		pairs [][2]int
+	}{
+		{"in and out",
+			[]string{"Kick In", "Kick Out", "Snare"},
+			[][2]int{{0, 1}}},
+		{"microphone models",
+			[]string{"Kick 91, iKick", "Kick 52", "Snare T SM57", "Snare B SM57", "Hi Hat"},
+			[][2]int{{0, 1}, {2, 3}}},
+		{"german",
+			[]string{"Snare Oben Beta, Snare Para", "Snare unten SM57"},
+			[][2]int{{0, 1}}},
+		{"three microphones",
+			[]string{"Kick In", "Kick Out", "Kick Sub"},
+			[][2]int{{0, 1}, {0, 2}}},
+		{"numbered sources",
+			[]string{"Tom 1 Top", "Tom 1 Btm", "Tom 2 Top", "Tom 1", "Tom 2", "MC 1", "MC 2"},
+			[][2]int{{0, 1}}},
+		{"stereo sides",
+			[]string{"OHs-L", "OHs-R", "Piano -L", "Piano -R", "Pad In-L", "Pad In-R"},
+			[][2]int{}},
+		{"unrelated",
+			[]string{"Vox Lead", "Vox Backing", "Bass", "Synth Bass"},
+			[][2]int{}},
+	} {
+		if got, want := RelatedTracks(tt.names), tt.pairs; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: RelatedTracks() = %v, want %v", tt.desc, got, want)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package actions
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 var (
@@ -32,3 +38,33 @@ func DiscoverFiles(dir string, filters ...Filter) ([]string, error) {
 
 	return files, nil
 }
+
+// ReadFileList reads track names from a file listing, one file per line, as
+// produced by e.g. `ls`, `find`, `dir /b` or PowerShell's `Get-ChildItem -Name`.
+// Directories are stripped from the names, and blank lines are ignored. UTF-16
+// listings with a byte order mark are decoded.
+func ReadFileList(r io.Reader, filters ...Filter) ([]string, error) {
+	r = transform.NewReader(r, unicode.BOMOverride(unicode.UTF8.NewDecoder()))
+	files := []string{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if i := strings.LastIndexAny(line, `/\`); i >= 0 {
+			line = line[i+1:]
+		}
+		files = append(files, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		files = filter(files)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found in listing")
+	}
+	return files, nil
+}
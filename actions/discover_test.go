@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	k8os "github.com/kward/golib/os"
@@ -49,6 +50,37 @@ func TestDiscoverFiles(t *testing.T) {
 	}
 }
 
+func TestReadFileList(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		data  string
+		files []string
+		ok    bool
+	}{
+		{"ls", "Track 01-1.wav\nTrack 02-1.wav\nTrack 03-1.mp3\n",
+			[]string{"Track 01-1.wav", "Track 02-1.wav"}, true},
+		{"find", "./rec/Track 01-1.wav\n\n./rec/Track 01-2.wav\n",
+			[]string{"Track 01-1.wav", "Track 01-2.wav"}, true},
+		{"dir /s /b", "\xef\xbb\xbfD:\\Rec\\Track 01-1.wav\r\nD:\\Rec\\Track 02-1.wav\r\n",
+			[]string{"Track 01-1.wav", "Track 02-1.wav"}, true},
+		{"utf-16", "\xff\xfeT\x00r\x00a\x00c\x00k\x00 \x000\x001\x00-\x001\x00.\x00w\x00a\x00v\x00\r\x00\n\x00",
+			[]string{"Track 01-1.wav"}, true},
+		{"empty", "Track 01-1.mp3\n", nil, false},
+	} {
+		files, err := ReadFileList(strings.NewReader(tt.data), FilterWaves)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ReadFileList() unexpected error %v", tt.desc, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if got, want := files, tt.files; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ReadFileList() = %q, want %q", tt.desc, got, want)
+		}
+	}
+}
+
 // mockReadDir returns a list of files found in some mock directory. The default
 // file type produced by Waves Tracks is Wave (RF64), which has a `.wav` file
 // extension. The other types are present to test filtering.
@@ -0,0 +1,112 @@
+package actions
+
+import "sync"
+
+// FileOp performs the file operation of op, e.g. moving op.Src to op.Dest. It
+// may set the op.Status to OperationSkipped, e.g. if the work was already done.
+type FileOp func(op *Operation) error
+
+// MoveOp moves op.Src to op.Dest; see Move().
+func MoveOp(op *Operation) error { return Move(op.Src, op.Dest) }
+
+// CopyOp copies op.Src to op.Dest, and verifies the copy; see CopyVerified().
+// A Copier also copies to backup directories, converts, and records the copies.
+func CopyOp(op *Operation) error {
+	_, _, err := CopyVerified(op.Src, op.Dest)
+	return err
+}
+
+// LinkOp returns a FileOp linking op.Dest to op.Src with the link mode. The
+// mode actually used is recorded in op.LinkMode.
+func LinkOp(mode LinkMode) FileOp {
+	return func(op *Operation) error {
+		used, err := mode.Link(op.Src, op.Dest)
+		op.LinkMode = used.String()
+		return err
+	}
+}
+
+// Executor performs the file operations of a plan.
+type Executor struct {
+	Op     FileOp
+	DryRun bool // Report the operations, without performing them.
+	// Jobs is the number of operations performed at once; 0 or 1 performs
+	// them one at a time. Op must be safe for concurrent use if more.
+	Jobs int
+	// Progress, if set, is called before each operation, with an empty
+	// op.Status, and again once the operation is done or has failed. Calls
+	// are never concurrent.
+	Progress func(op *Operation)
+}
+
+// NewExecutor returns an executor performing op.
+func NewExecutor(op FileOp) *Executor {
+	return &Executor{Op: op}
+}
+
+// Execute performs the operations of the plan in order, and stops at the first
+// error, once the operations already started are done. It returns the
+// operations attempted, including the failed one, in the order started.
+func (x *Executor) Execute(p *Plan) ([]*Operation, error) {
+	jobs := x.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	var (
+		mu    sync.Mutex // Guards the following, and the progress.
+		wg    sync.WaitGroup
+		next  int
+		ops   = []*Operation{}
+		first error
+	)
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if first != nil || next == len(p.Entries) {
+					mu.Unlock()
+					return
+				}
+				op := p.Entries[next].Operation(p.srcDir(), p.destDir())
+				next++
+				ops = append(ops, op)
+				x.progress(op)
+				mu.Unlock()
+
+				err := x.perform(op)
+				mu.Lock()
+				if err != nil && first == nil {
+					first = err
+				}
+				x.progress(op)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return ops, first
+}
+
+// perform the operation, and set its status.
+func (x *Executor) perform(op *Operation) error {
+	if x.DryRun {
+		op.Status = OperationDryRun
+		return nil
+	}
+	if err := x.Op(op); err != nil {
+		op.Status, op.Error = OperationFailed, err.Error()
+		return err
+	}
+	if op.Status == "" {
+		op.Status = OperationDone
+	}
+	return nil
+}
+
+func (x *Executor) progress(op *Operation) {
+	if x.Progress != nil {
+		x.Progress(op)
+	}
+}
@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecutorExecute(t *testing.T) {
+	plan := &Plan{
+		SrcDir:  "src",
+		DestDir: "dest",
+		Entries: []*PlanEntry{
+			{Src: "Track 01-1.wav", Dest: "01-01 Kick.wav"},
+			{Src: "Track 02-1.wav", Dest: "01-02 Snare.wav"},
+			{Src: "Track 03-1.wav", Dest: "01-03 Hat.wav"},
+		},
+	}
+
+	for _, tt := range []struct {
+		desc     string
+		dryRun   bool
+		fail     string // The source failing, if any.
+		statuses []OperationStatus
+		progress []string
+	}{
+		{"done", false, "",
+			[]OperationStatus{OperationDone, OperationSkipped, OperationDone},
+			[]string{" src/Track 01-1.wav", "done src/Track 01-1.wav", " src/Track 02-1.wav", "skipped src/Track 02-1.wav", " src/Track 03-1.wav", "done src/Track 03-1.wav"}},
+		{"dry run", true, "",
+			[]OperationStatus{OperationDryRun, OperationDryRun, OperationDryRun},
+			[]string{" src/Track 01-1.wav", "dry run src/Track 01-1.wav", " src/Track 02-1.wav", "dry run src/Track 02-1.wav", " src/Track 03-1.wav", "dry run src/Track 03-1.wav"}},
+		{"failed", false, "src/Track 01-1.wav",
+			[]OperationStatus{OperationFailed},
+			[]string{" src/Track 01-1.wav", "failed src/Track 01-1.wav"}},
+	} {
+		performed := []string{}
+		x := NewExecutor(func(op *Operation) error {
+			if op.Src == tt.fail {
+				return fmt.Errorf("failed")
+			}
+			if op.Src == "src/Track 02-1.wav" {
+				op.Status = OperationSkipped
+			}
+			performed = append(performed, op.Dest)
+			return nil
+		})
+		x.DryRun = tt.dryRun
+		progress := []string{}
+		x.Progress = func(op *Operation) { progress = append(progress, fmt.Sprintf("%s %s", op.Status, op.Src)) }
+
+		ops, err := x.Execute(plan)
+		if (err == nil) != (tt.fail == "") {
+			t.Errorf("%s: Execute() unexpected error %v", tt.desc, err)
+		}
+		statuses := []OperationStatus{}
+		for _, op := range ops {
+			statuses = append(statuses, op.Status)
+		}
+		if !reflect.DeepEqual(statuses, tt.statuses) {
+			t.Errorf("%s: Execute() statuses = %q, want %q", tt.desc, statuses, tt.statuses)
+		}
+		if !reflect.DeepEqual(progress, tt.progress) {
+			t.Errorf("%s: Execute() progress = %q, want %q", tt.desc, progress, tt.progress)
+		}
+		if tt.dryRun && len(performed) > 0 {
+			t.Errorf("%s: Execute() performed %q", tt.desc, performed)
+		}
+	}
+}
+
+func TestExecutorJobs(t *testing.T) {
+	plan := &Plan{SrcDir: "src", DestDir: "dest"}
+	for i := 1; i <= 10; i++ {
+		plan.Entries = append(plan.Entries, &PlanEntry{Src: fmt.Sprintf("Track %02d-1.wav", i)})
+	}
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	x := NewExecutor(func(op *Operation) error {
+		if op.Src == "src/Track 05-1.wav" {
+			return fmt.Errorf("failed")
+		}
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	x.Jobs = 3
+	ops, err := x.Execute(plan)
+	if err == nil {
+		t.Errorf("Execute() expected error")
+	}
+	if most < 2 || most > x.Jobs {
+		t.Errorf("Execute() ran %d operations at once, want 2 to %d", most, x.Jobs)
+	}
+	// The operations started before the failure are done, and no more are.
+	if got := len(ops); got < 5 || got > 5+x.Jobs-1 {
+		t.Errorf("Execute() attempted %d operations, want 5 to %d", got, 5+x.Jobs-1)
+	}
+}
@@ -0,0 +1,177 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FilenamePolicy defines how track names are converted into filenames.
+type FilenamePolicy int
+
+const (
+	// Portable filenames are valid on Windows, macOS, Linux and exFAT drives.
+	Portable FilenamePolicy = iota
+	// MacOS filenames are NFD normalized, as stored by HFS+.
+	MacOS
+	// Windows filenames are valid on Windows and exFAT drives.
+	Windows
+	// ASCII filenames are Portable, with names transliterated to ASCII.
+	ASCII
+)
+
+// DefaultMaxFilenameLength is the longest filename supported by all common
+// filesystems, in bytes (or UTF-16 code units for Windows).
+const DefaultMaxFilenameLength = 255
+
+var filenamePolicies = map[string]FilenamePolicy{
+	"portable": Portable,
+	"macos":    MacOS,
+	"windows":  Windows,
+	"ascii":    ASCII,
+}
+
+// ParseFilenamePolicy returns the named filename policy.
+func ParseFilenamePolicy(name string) (FilenamePolicy, error) {
+	p, ok := filenamePolicies[strings.ToLower(name)]
+	if !ok {
+		return Portable, fmt.Errorf("unknown filename policy %q", name)
+	}
+	return p, nil
+}
+
+// String implements the fmt.Stringer interface.
+func (p FilenamePolicy) String() string {
+	for name, fp := range filenamePolicies {
+		if fp == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("FilenamePolicy(%d)", int(p))
+}
+
+// Filename returns a valid filename for the given prefix (e.g. "01-02 "), name
+// and extension (e.g. ".wav"). The name is shortened as needed so that the
+// filename is no longer than max, or DefaultMaxFilenameLength if max is 0; the
+// prefix and extension are kept whole, and are not sanitized.
+func (p FilenamePolicy) Filename(prefix, name, ext string, max int) string {
+	if max <= 0 {
+		max = DefaultMaxFilenameLength
+	}
+	name = p.sanitize(name)
+	for name != "" && p.length(prefix+name+ext) > max {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = p.trim(name[:len(name)-size])
+	}
+	base := p.trim(prefix + name)
+	if p != MacOS && reserved(base) {
+		base += "_"
+	}
+	return base + ext
+}
+
+// MinFilenameLength returns the shortest maximum filename length that a track
+// filename with the extension fits in, with its "SS-TT" prefix but no name.
+func MinFilenameLength(ext string) int {
+	return len("01-01") + len(ext)
+}
+
+// sanitize replaces characters that are invalid under the policy.
+func (p FilenamePolicy) sanitize(name string) string {
+	switch p {
+	case MacOS:
+		name = norm.NFD.String(name)
+	case ASCII:
+		name = transliterate(name)
+	default:
+		name = norm.NFC.String(name)
+	}
+	name = strings.Map(func(r rune) rune {
+		if invalidRune(p, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return p.trim(name)
+}
+
+// trim removes trailing characters that Windows silently drops.
+func (p FilenamePolicy) trim(name string) string {
+	if p == MacOS {
+		return name
+	}
+	return strings.TrimRight(name, ". ")
+}
+
+// length returns the filename length as counted by the target filesystem.
+func (p FilenamePolicy) length(name string) int {
+	if p == Windows {
+		return len(utf16.Encode([]rune(name)))
+	}
+	return len(name)
+}
+
+// invalidRune returns true if the rune is not allowed in a filename.
+func invalidRune(p FilenamePolicy, r rune) bool {
+	switch r {
+	case '/', '\\': // Path separators.
+		return true
+	case ':': // Shown as '/' by the macOS Finder.
+		return true
+	case '*', '?', '"', '<', '>', '|':
+		return p != MacOS
+	}
+	if unicode.IsControl(r) {
+		return true
+	}
+	return p == ASCII && r > unicode.MaxASCII
+}
+
+// reserved returns true if the name is a reserved Windows device name.
+func reserved(name string) bool {
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+	return false
+}
+
+// transliterations holds ASCII replacements for characters that do not
+// decompose into an ASCII base character.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "Th", 'ı': "i",
+	'‘': "'", '’': "'", '“': "\"", '”': "\"", '–': "-", '—': "-",
+	'…': "...", '×': "x", 'µ': "u", '°': "o",
+}
+
+// transliterate returns the name with accents removed, and other characters
+// replaced by their nearest ASCII equivalent where known.
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if s, ok := transliterations[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(t, b.String())
+	if err != nil {
+		return b.String()
+	}
+	return s
+}
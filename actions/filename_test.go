@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilenamePolicyFilename(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		policy   FilenamePolicy
+		prefix   string
+		name     string
+		max      int
+		filename string
+	}{
+		{"portable clean", Portable, "01-01 ", "Kick", 0, "01-01 Kick.wav"},
+		{"portable separators", Portable, "01-01 ", `a/b\c`, 0, "01-01 a_b_c.wav"},
+		{"portable invalid", Portable, "01-01 ", `a:b*c?d"e<f>g|h`, 0, "01-01 a_b_c_d_e_f_g_h.wav"},
+		{"portable control", Portable, "01-01 ", "a\tb", 0, "01-01 a_b.wav"},
+		{"portable trailing dots", Portable, "01-01 ", "Vox...", 0, "01-01 Vox.wav"},
+		{"portable reserved", Portable, "", "CON", 0, "CON_.wav"},
+		{"portable reserved lowercase", Portable, "", "lpt1", 0, "lpt1_.wav"},
+		{"portable nfc", Portable, "01-01 ", "José", 0, "01-01 José.wav"},
+		{"portable truncated", Portable, "01-01 ", "Kick", 10, "01-01.wav"},
+		{"portable truncated name", Portable, "01-01 ", "Kick Drum", 14, "01-01 Kick.wav"},
+		{"portable prefix kept", Portable, "01-01 ", "Kick", 5, "01-01.wav"},
+		{"portable truncated rune", Portable, "01-01 ", "José", 13, "01-01 Jos.wav"},
+		{"macos nfd", MacOS, "01-01 ", "José", 0, "01-01 Jose\u0301.wav"},
+		{"macos allowed", MacOS, "01-01 ", "a*b?", 0, "01-01 a*b?.wav"},
+		{"macos colon", MacOS, "01-01 ", "a:b", 0, "01-01 a_b.wav"},
+		{"windows invalid", Windows, "01-01 ", "a|b.", 0, "01-01 a_b.wav"},
+		{"windows utf-16 length", Windows, "01-01 ", "ééé", 12, "01-01 éé.wav"},
+		{"ascii accents", ASCII, "01-01 ", "José Müller", 0, "01-01 Jose Muller.wav"},
+		{"ascii transliterated", ASCII, "01-01 ", "Straße Æther", 0, "01-01 Strasse AEther.wav"},
+		{"ascii unknown", ASCII, "01-01 ", "♪", 0, "01-01 _.wav"},
+	} {
+		if got, want := tt.policy.Filename(tt.prefix, tt.name, ".wav", tt.max), tt.filename; got != want {
+			t.Errorf("%s: Filename(%q, %q) = %q, want %q", tt.desc, tt.prefix, tt.name, got, want)
+		}
+	}
+}
+
+func TestFilenamePolicyDefaultLength(t *testing.T) {
+	name := Portable.Filename("01-01 ", strings.Repeat("x", 300), ".wav", 0)
+	if got, want := len(name), DefaultMaxFilenameLength; got != want {
+		t.Errorf("Filename() length = %d, want %d", got, want)
+	}
+}
+
+func TestParseFilenamePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy FilenamePolicy
+		ok     bool
+	}{
+		{"portable", Portable, true},
+		{"macOS", MacOS, true},
+		{"windows", Windows, true},
+		{"ascii", ASCII, true},
+		{"dos", Portable, false},
+	} {
+		p, err := ParseFilenamePolicy(tt.name)
+		if err == nil && !tt.ok {
+			t.Errorf("ParseFilenamePolicy(%q) expected error", tt.name)
+		}
+		if err != nil && tt.ok {
+			t.Errorf("ParseFilenamePolicy(%q) unexpected error; %s", tt.name, err)
+		}
+		if got, want := p, tt.policy; got != want {
+			t.Errorf("ParseFilenamePolicy(%q) = %s, want %s", tt.name, got, want)
+		}
+		if tt.ok && p.String() != strings.ToLower(tt.name) {
+			t.Errorf("%s.String() = %q, want %q", p, p.String(), strings.ToLower(tt.name))
+		}
+	}
+}
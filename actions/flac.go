@@ -0,0 +1,210 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kward/tracks/flac"
+	"github.com/kward/tracks/venue"
+)
+
+// AudioEncoding is the file encoding of converted audio.
+type AudioEncoding int
+
+const (
+	WaveEncoding AudioEncoding = iota
+	FLACEncoding
+)
+
+// ParseAudioEncoding returns the named encoding, i.e. "wav" or "flac".
+func ParseAudioEncoding(name string) (AudioEncoding, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "wav", "wave":
+		return WaveEncoding, nil
+	case "flac":
+		return FLACEncoding, nil
+	}
+	return 0, fmt.Errorf("unsupported encoding %q", name)
+}
+
+func (e AudioEncoding) String() string {
+	if e == FLACEncoding {
+		return "flac"
+	}
+	return "wav"
+}
+
+// Ext returns the file extension of the encoding.
+func (e AudioEncoding) Ext() string { return "." + e.String() }
+
+const flacMagic = "fLaC"
+
+// TrackComments returns the Vorbis comments of a FLAC encoded track: its
+// channel name, session and track numbers, and input channel, with the show
+// and console of the Venue patch v, if not nil.
+func TrackComments(v *venue.Venue, e *PlanEntry) []string {
+	comments := []string{
+		"TITLE=" + e.Name,
+		"TRACKNUMBER=" + strconv.Itoa(e.Track),
+		"SESSION=" + strconv.Itoa(e.Session),
+	}
+	channel := e.Channel
+	if dev, moniker, ok := v.Devices().InputLocation(e.Track); channel == "" && ok {
+		channel = dev + " " + moniker
+	}
+	if channel != "" {
+		comments = append(comments, "CHANNEL="+channel)
+	}
+	if v.Show() != "" {
+		comments = append(comments, "ALBUM="+v.Show())
+	}
+	if console := strings.TrimSpace(v.Console() + " " + v.Version()); console != "" {
+		comments = append(comments, "CONSOLE="+console)
+	}
+	return comments
+}
+
+// openFLACSamples returns a reader of the samples of the FLAC file f.
+func openFLACSamples(f *os.File) (*sampleReader, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	d, err := flac.NewDecoder(f)
+	if err != nil {
+		return nil, err
+	}
+	scale := float64(int64(1) << uint(d.Info.BitsPerSample-1))
+	block := make([][]float64, d.Info.Channels)
+	return &sampleReader{
+		format:   SampleFormat{Bits: d.Info.BitsPerSample},
+		channels: d.Info.Channels,
+		rate:     d.Info.SampleRate,
+		comments: d.Comments,
+		read: func() ([][]float64, error) {
+			frame, err := d.Read()
+			if err != nil {
+				return nil, err
+			}
+			for c, s := range frame {
+				if cap(block[c]) < len(s) {
+					block[c] = make([]float64, len(s))
+				}
+				block[c] = block[c][:len(s)]
+				for i, v := range s {
+					block[c][i] = float64(v) / scale
+				}
+			}
+			return block, nil
+		},
+	}, nil
+}
+
+// flacWriter writes a FLAC file.
+type flacWriter struct {
+	f        *os.File
+	e        *flac.Encoder
+	bits     int
+	rngs     []*rand.Rand // Dither the samples, by channel, if set.
+	buf      [][]int32
+	channels int
+	frames   int64
+}
+
+func newFLACWriter(file string, bits, channels, rate int, rngs []*rand.Rand, comments []string) (*flacWriter, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	info := flac.StreamInfo{SampleRate: rate, Channels: channels, BitsPerSample: bits}
+	e, err := flac.NewEncoder(f, info, comments...)
+	if err != nil {
+		f.Close()
+		os.Remove(file)
+		return nil, err
+	}
+	return &flacWriter{f: f, e: e, bits: bits, rngs: rngs, buf: make([][]int32, channels), channels: channels}, nil
+}
+
+// write writes frames of samples, given by channel.
+func (w *flacWriter) write(samples [][]float64) error {
+	frames := len(samples[0])
+	for c := range w.buf {
+		if cap(w.buf[c]) < frames {
+			w.buf[c] = make([]int32, frames)
+		}
+		w.buf[c] = w.buf[c][:frames]
+	}
+	// Quantize frame by frame, so that the dither matches a wave file.
+	for f := 0; f < frames; f++ {
+		for c := range w.buf {
+			var rng *rand.Rand
+			if w.rngs != nil {
+				rng = w.rngs[c]
+			}
+			w.buf[c][f] = int32(quantize(samples[c][f], w.bits, rng))
+		}
+	}
+	w.frames += int64(frames)
+	return w.e.Write(w.buf)
+}
+
+// close completes the stream header, and closes the file.
+func (w *flacWriter) close() error {
+	if err := w.e.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// abort closes the file, incomplete.
+func (w *flacWriter) abort() { w.f.Close() }
+
+// verify decodes the closed file, which verifies the checksum of its samples.
+// It returns the hex encoded SHA-256 checksum and the size of the file.
+func (w *flacWriter) verify() (string, int64, error) {
+	f, err := os.Open(w.f.Name())
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	cr := &countingReader{r: io.TeeReader(f, sum)}
+	d, err := flac.NewDecoder(cr)
+	if err != nil {
+		return "", 0, err
+	}
+	if d.Info.Samples != w.frames || d.Info.Channels != w.channels || d.Info.BitsPerSample != w.bits {
+		return "", 0, fmt.Errorf("headers differ after writing")
+	}
+	for {
+		if _, err := d.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", 0, err
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), cr.n, nil
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,167 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/flac"
+)
+
+func TestParseAudioEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ok   bool
+		enc  AudioEncoding
+	}{
+		{"wav", true, WaveEncoding},
+		{"WAVE", true, WaveEncoding},
+		{".flac", true, FLACEncoding},
+		{"mp3", false, 0},
+	} {
+		enc, err := ParseAudioEncoding(tt.name)
+		if (err == nil) != tt.ok || enc != tt.enc {
+			t.Errorf("ParseAudioEncoding(%q) = %s, %v; want %s", tt.name, enc, err, tt.enc)
+		}
+	}
+	if got, want := FLACEncoding.Ext(), ".flac"; got != want {
+		t.Errorf("Ext() = %q, want %q", got, want)
+	}
+}
+
+func TestTrackComments(t *testing.T) {
+	e := &PlanEntry{Session: 2, Track: 1, Name: "Kick", Channel: "Stage 1 1"}
+	if got, want := TrackComments(nil, e), []string{"TITLE=Kick", "TRACKNUMBER=1", "SESSION=2", "CHANNEL=Stage 1 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackComments() = %q, want %q", got, want)
+	}
+}
+
+func TestConvertFLAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flac_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range []struct {
+		format   SampleFormat
+		channels int
+		frames   int
+	}{
+		{Int16, 1, 10000},
+		{Int16, 2, 5000},
+		{Int24, 2, 9000},
+		{Int24, 8, 100},
+		{Int32, 2, 4097},
+	} {
+		desc := filepath.Join(dir, fmt.Sprintf("%s-%d", tt.format, tt.channels))
+		samples := make([]float64, tt.frames*tt.channels)
+		for i := range samples {
+			// Noise over a sine, down to the last bit.
+			v := 0.5*math.Sin(float64(i/tt.channels)/10) + 0.4*(rng.Float64()-0.5)
+			scale := float64(int64(1) << uint(tt.format.Bits-1))
+			samples[i] = math.Floor(v*scale) / scale
+		}
+		samples[0], samples[1] = -1, 1-1/float64(int64(1)<<uint(tt.format.Bits-1))
+		src, enc, dec := desc+".wav", desc+".flac", desc+"-decoded.wav"
+		writeTestWave(t, src, tt.format, 48000, tt.channels, samples, 0)
+
+		c := &Converter{Encoding: FLACEncoding}
+		sum, res, err := c.Convert(src, enc, "TITLE=Kick", "TRACKNUMBER=1")
+		if err != nil {
+			t.Errorf("%s: Convert() unexpected error %s", desc, err)
+			continue
+		}
+		if want, _ := FileChecksum(enc); sum != want {
+			t.Errorf("%s: Convert() = %s, want the checksum %s", desc, sum, want)
+		}
+		if fi, _ := os.Stat(enc); fi == nil || res.Bytes != fi.Size() {
+			t.Errorf("%s: Convert() bytes = %d, want the size of the file", desc, res.Bytes)
+		}
+		f, err := os.Open(enc)
+		if err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		d, err := flac.NewDecoder(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: NewDecoder() unexpected error %s", desc, err)
+			continue
+		}
+		if got, want := d.Comments, []string{"TITLE=Kick", "TRACKNUMBER=1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: comments = %q, want %q", desc, got, want)
+		}
+		if d.Info.BitsPerSample != tt.format.Bits || d.Info.Channels != tt.channels || d.Info.Samples != int64(tt.frames) {
+			t.Errorf("%s: info = %+v", desc, d.Info)
+		}
+
+		// Decoding gives back the samples, bit for bit.
+		if _, _, err := (&Converter{}).Convert(enc, dec); err != nil {
+			t.Errorf("%s: Convert() unexpected error decoding; %s", desc, err)
+			continue
+		}
+		_, want, _ := readTestWave(t, src)
+		h, got, _ := readTestWave(t, dec)
+		if h.bitsPerSample != uint16(tt.format.Bits) || h.channels != uint16(tt.channels) || h.sampleRate != 48000 {
+			t.Errorf("%s: decoded header = %+v", desc, h)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the decoded samples differ", desc)
+		}
+	}
+}
+
+func TestConvertFLACFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flac_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.wav")
+	samples := make([]float64, 4800)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(float64(i)/10)
+	}
+	writeTestWave(t, src, Float32, 96000, 1, samples, 0)
+
+	// Float samples need a sample format.
+	dest := filepath.Join(dir, "dest.flac")
+	if _, _, err := (&Converter{Encoding: FLACEncoding}).Convert(src, dest); err == nil {
+		t.Errorf("Convert() of float samples expected error")
+	}
+	if _, err := os.Stat(dest + partialSuffix); err == nil {
+		t.Errorf("Convert() left a partial file")
+	}
+	c := &Converter{Format: Int24, SampleRate: 48000, Encoding: FLACEncoding}
+	if _, _, err := c.Convert(src, dest); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	// Converting the FLAC file keeps its comments.
+	if _, _, err := (&Converter{Format: Int16, Encoding: FLACEncoding}).Convert(dest, dest+".16", "ALBUM=Show"); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	if _, _, err := (&Converter{Encoding: FLACEncoding}).Convert(dest+".16", dest+".copy"); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	b, err := ioutil.ReadFile(dest + ".copy")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	d, err := flac.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("NewDecoder() unexpected error %s", err)
+	}
+	if d.Info.BitsPerSample != 16 || d.Info.SampleRate != 48000 || d.Info.Samples != 2400 {
+		t.Errorf("info = %+v", d.Info)
+	}
+	if got, want := d.Comments, []string{"ALBUM=Show"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("comments = %q, want %q", got, want)
+	}
+}
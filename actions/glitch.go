@@ -0,0 +1,321 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// GlitchKind is a kind of digital glitch, as introduced on the way from the
+// stage box to the recorder, e.g. over USB or SoundGrid.
+type GlitchKind string
+
+const (
+	// GlitchDropout is a short run of zero samples within a signal, e.g. a
+	// lost buffer.
+	GlitchDropout GlitchKind = "dropout"
+	// GlitchRepeat is a block of samples repeated, e.g. a buffer sent twice.
+	GlitchRepeat GlitchKind = "repeat"
+	// GlitchDiscontinuity is a sudden jump of the signal, heard as a click.
+	GlitchDiscontinuity GlitchKind = "discontinuity"
+	// GlitchClipping is a run of samples at full scale.
+	GlitchClipping GlitchKind = "clipping"
+)
+
+// DefaultGlitchTolerance is the time within which glitches of the tracks of
+// a session are taken to be at once.
+const DefaultGlitchTolerance = 10 * time.Millisecond
+
+const (
+	glitchLevel        = 0.001 // Below which samples are taken as silence, about -60 dBFS.
+	glitchMerge        = 10 * time.Millisecond
+	dropoutMinFrames   = 8
+	dropoutMax         = 100 * time.Millisecond // Beyond which zeros are silence.
+	discontinuityLevel = 0.1                    // Of the second difference of the samples.
+	discontinuityRatio = 16                     // Of the second difference to its recent average.
+	discontinuityTime  = 10 * time.Millisecond  // Of the recent average.
+	clipLevel          = 0.999
+	clipMinFrames      = 3
+)
+
+// repeatBlocks are the sizes of the buffers of audio interfaces, in frames.
+var repeatBlocks = []int{64, 128, 256, 512, 1024}
+
+// Glitch is a glitch found in a channel of a track.
+type Glitch struct {
+	Kind     GlitchKind `json:"kind" yaml:"kind"`
+	Channel  int        `json:"channel" yaml:"channel"` // From 1.
+	Start    Seconds    `json:"start" yaml:"start"`
+	Duration Seconds    `json:"duration" yaml:"duration"`
+	// Link is true if the glitch was found on every track of the session at
+	// once, pointing to the recording link rather than the source.
+	Link bool `json:"link,omitempty" yaml:"link,omitempty"`
+
+	start, end int64 // In frames.
+}
+
+// String implements the fmt.Stringer interface.
+func (g Glitch) String() string {
+	s := fmt.Sprintf("%s %s of %s on channel %d", g.Start, g.Kind, g.Duration, g.Channel)
+	if g.Link {
+		s += ", on every track"
+	}
+	return s
+}
+
+// TrackGlitches holds the glitches found in a track.
+type TrackGlitches struct {
+	File string `json:"file" yaml:"file"`
+	// Silent is true if the track holds no signal, in which glitches could be
+	// found.
+	Silent   bool     `json:"silent,omitempty" yaml:"silent,omitempty"`
+	Glitches []Glitch `json:"glitches" yaml:"glitches"`
+}
+
+// DetectGlitches returns the glitches found in each channel of the wave or
+// FLAC file, in order of time. Dropouts are runs of zeros of up to 100 ms
+// between samples of signal, and repeats are blocks of the size of a buffer
+// repeated exactly. Discontinuities are jumps of the signal far greater than
+// its recent slope, and clipping is a run of samples at full scale. Glitches
+// of a kind less than 10 ms apart are merged.
+func DetectGlitches(file string) (*TrackGlitches, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := openSamples(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q; %s", file, err)
+	}
+	ds := make([]*glitchDetector, r.channels)
+	for c := range ds {
+		ds[c] = newGlitchDetector(r.rate)
+	}
+	for {
+		samples, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %q; %s", file, err)
+		}
+		for c, d := range ds {
+			d.push(samples[c])
+		}
+	}
+
+	t := &TrackGlitches{File: file, Silent: true, Glitches: []Glitch{}}
+	for c, d := range ds {
+		d.flush()
+		if d.peak >= glitchLevel {
+			t.Silent = false
+		}
+		for _, g := range d.glitches {
+			g.Channel = c + 1
+			g.Start = Seconds(time.Duration(g.start) * time.Second / time.Duration(r.rate))
+			g.Duration = Seconds(time.Duration(g.end-g.start) * time.Second / time.Duration(r.rate))
+			t.Glitches = append(t.Glitches, g)
+		}
+	}
+	sort.SliceStable(t.Glitches, func(i, j int) bool { return t.Glitches[i].start < t.Glitches[j].start })
+	return t, nil
+}
+
+// glitchDetector detects the glitches of a channel, a block at a time.
+type glitchDetector struct {
+	buf      []float64 // The samples pushed, from the history kept.
+	keep     int       // Frames of history kept between blocks.
+	n        int64     // Frames pushed.
+	merge    int64
+	glitches []Glitch
+	peak     float64
+
+	zeros    int64   // Of the current run of zeros.
+	before   float64 // The level of the sample before the zeros.
+	maxZeros int64
+	repeats  []int // The run of samples equal to those a block before, by block.
+	warmup   int64
+	baseline float64 // The recent average of the second difference.
+	alpha    float64
+	clip     int64
+	clipSign float64
+}
+
+func newGlitchDetector(rate int) *glitchDetector {
+	frames := func(d time.Duration) int64 { return int64(d) * int64(rate) / int64(time.Second) }
+	return &glitchDetector{
+		keep:     repeatBlocks[len(repeatBlocks)-1],
+		merge:    frames(glitchMerge),
+		maxZeros: frames(dropoutMax),
+		repeats:  make([]int, len(repeatBlocks)),
+		warmup:   frames(discontinuityTime),
+		alpha:    1 / float64(frames(discontinuityTime)+1),
+	}
+}
+
+// push detects the glitches of a block of samples.
+func (d *glitchDetector) push(samples []float64) {
+	first := len(d.buf)
+	d.buf = append(d.buf, samples...)
+	for i := first; i < len(d.buf); i++ {
+		d.sample(i)
+		d.n++
+	}
+	if n := len(d.buf) - d.keep; n > 0 {
+		d.buf = d.buf[:copy(d.buf, d.buf[n:])]
+	}
+}
+
+// sample detects the glitches ending at sample i of the buffer, the frame
+// d.n of the channel.
+func (d *glitchDetector) sample(i int) {
+	x := d.buf[i]
+	level := math.Abs(x)
+	if level > d.peak {
+		d.peak = level
+	}
+	prev := 0.0
+	if i > 0 {
+		prev = d.buf[i-1]
+	}
+
+	// Dropouts.
+	if x == 0 {
+		if d.zeros == 0 {
+			d.before = math.Abs(prev)
+		}
+		d.zeros++
+	} else {
+		if d.zeros >= dropoutMinFrames && d.zeros <= d.maxZeros && d.before >= glitchLevel && level >= glitchLevel {
+			d.add(GlitchDropout, d.n-d.zeros, d.n)
+		}
+		d.zeros = 0
+	}
+
+	// Repeats, of blocks holding signal.
+	for k, size := range repeatBlocks {
+		if int64(size) > d.n || x != d.buf[i-size] {
+			d.repeats[k] = 0
+			continue
+		}
+		if d.repeats[k]++; d.repeats[k] < size {
+			continue
+		}
+		min, max := x, x
+		for _, v := range d.buf[i-size+1 : i] {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		if max-min >= glitchLevel {
+			d.add(GlitchRepeat, d.n-int64(size)+1, d.n+1)
+		}
+		for k := range d.repeats {
+			d.repeats[k] = 0
+		}
+		break
+	}
+
+	// Discontinuities. Those to or from zero are found as dropouts.
+	if d.n >= 2 {
+		a := math.Abs(x - 2*prev + d.buf[i-2])
+		if d.n > d.warmup && x != 0 && prev != 0 && d.buf[i-2] != 0 &&
+			a >= discontinuityLevel && a >= discontinuityRatio*d.baseline {
+			d.add(GlitchDiscontinuity, d.n, d.n+1)
+		}
+		d.baseline += (a - d.baseline) * d.alpha
+	}
+
+	// Clipping.
+	if level >= clipLevel && (d.clip == 0 || math.Signbit(x) == math.Signbit(d.clipSign)) {
+		d.clip++
+		d.clipSign = x
+		return
+	}
+	d.flushClip(d.n)
+	if level >= clipLevel {
+		d.clip, d.clipSign = 1, x
+	}
+}
+
+// flushClip adds the current run of clipped samples, ending at frame end, if
+// long enough.
+func (d *glitchDetector) flushClip(end int64) {
+	if d.clip >= clipMinFrames {
+		d.add(GlitchClipping, end-d.clip, end)
+	}
+	d.clip = 0
+}
+
+// flush adds the glitches running to the end of the channel.
+func (d *glitchDetector) flush() { d.flushClip(d.n) }
+
+// add adds a glitch of the frames [start, end), merged with the last of its
+// kind if close enough.
+func (d *glitchDetector) add(kind GlitchKind, start, end int64) {
+	for i := len(d.glitches) - 1; i >= 0; i-- {
+		g := &d.glitches[i]
+		if g.Kind != kind {
+			continue
+		}
+		if start <= g.end+d.merge {
+			if end > g.end {
+				g.end = end
+			}
+			return
+		}
+		break
+	}
+	d.glitches = append(d.glitches, Glitch{Kind: kind, start: start, end: end})
+}
+
+// LinkGlitches marks the glitches found on every track of a session at once,
+// within the tolerance, as pointing to the recording link rather than a source.
+// Silent tracks are left out, as glitches can't be found in them, and at least
+// two tracks are needed. It returns the times of the glitches, as found on the
+// first track.
+func LinkGlitches(ts []*TrackGlitches, tolerance time.Duration) []Seconds {
+	tracks := []*TrackGlitches{}
+	for _, t := range ts {
+		if !t.Silent {
+			tracks = append(tracks, t)
+		}
+	}
+	if len(tracks) < 2 {
+		return nil
+	}
+	tol := Seconds(tolerance)
+	near := func(a, b *Glitch) bool {
+		return a.Start <= b.Start+b.Duration+tol && b.Start <= a.Start+a.Duration+tol
+	}
+	times := []Seconds{}
+	for i := range tracks[0].Glitches {
+		g := &tracks[0].Glitches[i]
+		matches := []*Glitch{g}
+		for _, t := range tracks[1:] {
+			found := false
+			for j := range t.Glitches {
+				if h := &t.Glitches[j]; near(g, h) {
+					matches = append(matches, h)
+					found = true
+				}
+			}
+			if !found {
+				matches = nil
+				break
+			}
+		}
+		if matches == nil {
+			continue
+		}
+		for _, m := range matches {
+			m.Link = true
+		}
+		if len(times) == 0 || !near(g, &Glitch{Start: times[len(times)-1]}) {
+			times = append(times, g.Start)
+		}
+	}
+	return times
+}
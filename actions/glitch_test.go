@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectGlitches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glitch_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const rate = 48000
+	sine := func() []float64 {
+		s := make([]float64, rate)
+		for i := range s {
+			s[i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/rate)
+		}
+		return s
+	}
+	at := func(d time.Duration) int { return int(d * rate / time.Second) }
+
+	for _, tt := range []struct {
+		desc   string
+		glitch func(s []float64)
+		kind   GlitchKind
+		start  time.Duration
+	}{
+		{"clean", func(s []float64) {}, "", 0},
+		{"dropout", func(s []float64) {
+			for i := at(100 * time.Millisecond); i < at(102*time.Millisecond); i++ {
+				s[i] = 0
+			}
+		}, GlitchDropout, 100 * time.Millisecond},
+		{"repeat", func(s []float64) {
+			i := at(300 * time.Millisecond)
+			copy(s[i:i+256], s[i-256:i])
+		}, GlitchRepeat, 300 * time.Millisecond},
+		{"discontinuity", func(s []float64) {
+			s[at(500*time.Millisecond)] += 0.3
+		}, GlitchDiscontinuity, 500 * time.Millisecond},
+		{"clipping", func(s []float64) {
+			for i := at(700 * time.Millisecond); i < at(701*time.Millisecond); i++ {
+				s[i] = math.Copysign(0.9999, s[i])
+			}
+		}, GlitchClipping, 700 * time.Millisecond},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			s := sine()
+			tt.glitch(s)
+			file := filepath.Join(dir, tt.desc+".wav")
+			writeTestWave(t, file, Int24, rate, 1, s, 0)
+			got, err := DetectGlitches(file)
+			if err != nil {
+				t.Fatalf("DetectGlitches() unexpected error %s", err)
+			}
+			if got.Silent {
+				t.Errorf("DetectGlitches() found the track silent")
+			}
+			if tt.kind == "" {
+				if len(got.Glitches) > 0 {
+					t.Errorf("DetectGlitches() = %v, want none", got.Glitches)
+				}
+				return
+			}
+			found := false
+			for _, g := range got.Glitches {
+				if g.Kind != tt.kind {
+					continue
+				}
+				if d := time.Duration(g.Start) - tt.start; d < -time.Millisecond || d > time.Millisecond {
+					t.Errorf("DetectGlitches() %s at %s, want %s", g.Kind, g.Start, tt.start)
+				}
+				if g.Channel != 1 {
+					t.Errorf("DetectGlitches() %s channel = %d, want 1", g.Kind, g.Channel)
+				}
+				found = true
+			}
+			if !found {
+				t.Errorf("DetectGlitches() = %v, want a %s", got.Glitches, tt.kind)
+			}
+		})
+	}
+
+	// Silence holds no glitches.
+	file := filepath.Join(dir, "silent.wav")
+	writeTestWave(t, file, Int24, rate, 2, make([]float64, 2*rate), 0)
+	got, err := DetectGlitches(file)
+	if err != nil {
+		t.Fatalf("DetectGlitches() unexpected error %s", err)
+	}
+	if !got.Silent || len(got.Glitches) > 0 {
+		t.Errorf("DetectGlitches() of silence = %v, %v; want silent", got.Silent, got.Glitches)
+	}
+}
+
+func TestLinkGlitches(t *testing.T) {
+	ms := func(n int) Seconds { return Seconds(time.Duration(n) * time.Millisecond) }
+	glitch := func(kind GlitchKind, start int) Glitch {
+		return Glitch{Kind: kind, Channel: 1, Start: ms(start), Duration: ms(1)}
+	}
+	ts := []*TrackGlitches{
+		{File: "a.wav", Glitches: []Glitch{glitch(GlitchDropout, 100), glitch(GlitchClipping, 500)}},
+		{File: "b.wav", Glitches: []Glitch{glitch(GlitchDiscontinuity, 105)}},
+		{File: "c.wav", Silent: true, Glitches: []Glitch{}},
+		{File: "d.wav", Glitches: []Glitch{glitch(GlitchDropout, 98), glitch(GlitchDropout, 300)}},
+	}
+	if got, want := LinkGlitches(ts, DefaultGlitchTolerance), []Seconds{ms(100)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LinkGlitches() = %v, want %v", got, want)
+	}
+	for _, tt := range []struct {
+		t, g int
+		link bool
+	}{
+		{0, 0, true},
+		{0, 1, false},
+		{1, 0, true},
+		{3, 0, true},
+		{3, 1, false},
+	} {
+		if got := ts[tt.t].Glitches[tt.g].Link; got != tt.link {
+			t.Errorf("LinkGlitches() %s glitch %d link = %v, want %v", ts[tt.t].File, tt.g, got, tt.link)
+		}
+	}
+
+	// A single track can't point to the link.
+	if got := LinkGlitches(ts[:1], DefaultGlitchTolerance); len(got) > 0 {
+		t.Errorf("LinkGlitches() of a track = %v, want none", got)
+	}
+}
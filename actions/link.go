@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LinkMode is the strategy used to link a track to its new name.
+type LinkMode int
+
+const (
+	// HardLink links within a single filesystem.
+	HardLink LinkMode = iota
+	// SymLink makes a relative symbolic link.
+	SymLink
+	// CloneLink makes a copy-on-write clone (a reflink), which shares the data
+	// of the original until either is modified. When the filesystem doesn't
+	// support clones, a hard link, or else a symbolic link, is made instead.
+	CloneLink
+)
+
+var linkModes = map[LinkMode]string{
+	HardLink:  "hard",
+	SymLink:   "symlink",
+	CloneLink: "clone",
+}
+
+var errCloneUnsupported = errors.New("clones are not supported on this platform")
+
+// ParseLinkMode returns the named link mode.
+func ParseLinkMode(name string) (LinkMode, error) {
+	for m, n := range linkModes {
+		if strings.ToLower(name) == n {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown link mode %q", name)
+}
+
+func (m LinkMode) String() string { return linkModes[m] }
+
+// Link dest to src. It returns the mode actually used, which differs from m
+// when a clone falls back to another mode.
+func (m LinkMode) Link(src, dest string) (LinkMode, error) {
+	switch m {
+	case HardLink:
+		return m, os.Link(src, dest)
+	case SymLink:
+		return m, Symlink(src, dest)
+	}
+
+	cerr := Clone(src, dest)
+	if cerr == nil {
+		return CloneLink, nil
+	}
+	if os.IsExist(cerr) {
+		return CloneLink, cerr
+	}
+	if err := os.Link(src, dest); err == nil {
+		return HardLink, nil
+	}
+	if err := Symlink(src, dest); err != nil {
+		return SymLink, fmt.Errorf("error cloning (%s) or linking; %s", cerr, err)
+	}
+	return SymLink, nil
+}
+
+// Symlink makes dest a symbolic link to src, relative to the directory of
+// dest, so that the links survive moving both directories together. Where
+// no relative path exists (e.g. across Windows volumes), the link is absolute.
+func Symlink(src, dest string) error {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(filepath.Dir(absDest), absSrc)
+	if err != nil {
+		target = absSrc
+	}
+	return os.Symlink(target, dest)
+}
+
+// Clone makes dest a copy-on-write clone of src, keeping its permissions and
+// modification time. It fails if the filesystem doesn't support clones.
+func Clone(src, dest string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := clone(src, dest, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, fi.ModTime(), fi.ModTime())
+}
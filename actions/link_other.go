@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package actions
+
+import "os"
+
+func clone(src, dest string, _ os.FileMode) error {
+	return &os.LinkError{Op: "clone", Old: src, New: dest, Err: errCloneUnsupported}
+}
@@ -0,0 +1,85 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLinkMode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mode LinkMode
+		ok   bool
+	}{
+		{"hard", HardLink, true},
+		{"symlink", SymLink, true},
+		{"Clone", CloneLink, true},
+		{"soft", 0, false},
+	} {
+		mode, err := ParseLinkMode(tt.name)
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("ParseLinkMode(%q) unexpected error %s", tt.name, err)
+			continue
+		}
+		if tt.ok && mode != tt.mode {
+			t.Errorf("ParseLinkMode(%q) = %s, want %s", tt.name, mode, tt.mode)
+		}
+	}
+}
+
+func TestLinkModeLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "link_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	srcDir, destDir := filepath.Join(dir, "src"), filepath.Join(dir, "dest")
+	for _, d := range []string{srcDir, destDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	src := filepath.Join(srcDir, "Track 01-1.wav")
+	if err := ioutil.WriteFile(src, []byte("kick"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	for _, tt := range []struct {
+		mode LinkMode
+		used []LinkMode // Acceptable modes, as clones depend on the filesystem.
+	}{
+		{HardLink, []LinkMode{HardLink}},
+		{SymLink, []LinkMode{SymLink}},
+		{CloneLink, []LinkMode{CloneLink, HardLink}},
+	} {
+		dest := filepath.Join(destDir, tt.mode.String()+".wav")
+		used, err := tt.mode.Link(src, dest)
+		if err != nil {
+			t.Errorf("%s: Link() unexpected error %s", tt.mode, err)
+			continue
+		}
+		ok := false
+		for _, m := range tt.used {
+			ok = ok || used == m
+		}
+		if !ok {
+			t.Errorf("%s: Link() used %s, want one of %v", tt.mode, used, tt.used)
+		}
+		if data, err := ioutil.ReadFile(dest); err != nil || string(data) != "kick" {
+			t.Errorf("%s: dest = %q, %v; want %q", tt.mode, data, err, "kick")
+		}
+		if used == SymLink {
+			target, err := os.Readlink(dest)
+			if got, want := target, filepath.Join("..", "src", "Track 01-1.wav"); err != nil || got != want {
+				t.Errorf("%s: link target = %q, %v; want %q", tt.mode, got, err, want)
+			}
+		}
+
+		// Linking over an existing file fails.
+		if _, err := tt.mode.Link(src, dest); err == nil {
+			t.Errorf("%s: Link() expected error when dest exists", tt.mode)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package actions
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash"
+)
+
+// HashAlgorithm is a checksum algorithm of a manifest.
+type HashAlgorithm int
+
+const (
+	MD5 HashAlgorithm = iota
+	SHA256
+	XXH64
+)
+
+var hashAlgorithms = map[HashAlgorithm]struct {
+	name string
+	new  func() hash.Hash
+	mhl  string // MHL element name, or "" if not supported by MHL.
+	sums string // Conventional name of a sum file.
+}{
+	MD5:    {"md5", md5.New, "md5", "MD5SUMS"},
+	SHA256: {"sha256", sha256.New, "", "SHA256SUMS"},
+	XXH64:  {"xxh64", func() hash.Hash { return xxhash.New() }, "xxhash64be", "XXH64SUMS"},
+}
+
+// ParseHashAlgorithm returns the named hash algorithm.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	for a, h := range hashAlgorithms {
+		if strings.ToLower(name) == h.name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported hash algorithm %q", name)
+}
+
+func (a HashAlgorithm) String() string { return hashAlgorithms[a].name }
+
+// ManifestFormat is the file format of a manifest.
+type ManifestFormat int
+
+const (
+	// SumFormat is the format of md5sum, sha256sum and xxh64sum, e.g.
+	// "d41d8cd98f00b204e9800998ecf8427e  01-01 Kick.wav".
+	SumFormat ManifestFormat = iota
+	// MHLFormat is the ASC Media Hash List (MHL) v1.1 XML format.
+	MHLFormat
+)
+
+// ParseManifestFormat returns the named manifest format.
+func ParseManifestFormat(name string) (ManifestFormat, error) {
+	switch strings.ToLower(name) {
+	case "sum":
+		return SumFormat, nil
+	case "mhl":
+		return MHLFormat, nil
+	}
+	return 0, fmt.Errorf("unsupported manifest format %q", name)
+}
+
+func (f ManifestFormat) String() string {
+	if f == MHLFormat {
+		return "mhl"
+	}
+	return "sum"
+}
+
+// ManifestName returns the conventional filename of a manifest.
+func ManifestName(a HashAlgorithm, f ManifestFormat) string {
+	if f == MHLFormat {
+		return "tracks.mhl"
+	}
+	return hashAlgorithms[a].sums
+}
+
+// ManifestEntry holds the checksum of a single file.
+type ManifestEntry struct {
+	File    string // Relative to the manifest directory.
+	Size    int64  // Not stored in the sum format.
+	ModTime time.Time
+	Sum     string // Hex encoded.
+}
+
+// Manifest holds the checksums of the files of a directory.
+type Manifest struct {
+	Algorithm HashAlgorithm
+	Created   time.Time
+	Entries   []*ManifestEntry // Sorted by file.
+}
+
+// NewManifest returns an empty manifest.
+func NewManifest(a HashAlgorithm) *Manifest {
+	return &Manifest{Algorithm: a, Created: time.Now()}
+}
+
+// Set adds an entry, replacing any entry of the same file.
+func (m *Manifest) Set(e *ManifestEntry) {
+	i := sort.Search(len(m.Entries), func(i int) bool { return m.Entries[i].File >= e.File })
+	if i < len(m.Entries) && m.Entries[i].File == e.File {
+		m.Entries[i] = e
+		return
+	}
+	m.Entries = append(m.Entries, nil)
+	copy(m.Entries[i+1:], m.Entries[i:])
+	m.Entries[i] = e
+}
+
+// Entry returns the entry of a file, or nil if there is none.
+func (m *Manifest) Entry(file string) *ManifestEntry {
+	i := sort.Search(len(m.Entries), func(i int) bool { return m.Entries[i].File >= file })
+	if i < len(m.Entries) && m.Entries[i].File == file {
+		return m.Entries[i]
+	}
+	return nil
+}
+
+// Checksum returns the manifest entry of a file in dir.
+func (m *Manifest) Checksum(dir, file string) (*ManifestEntry, error) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := hashAlgorithms[m.Algorithm].new()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &ManifestEntry{
+		File:    file,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UTC(),
+		Sum:     hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// ReadManifest reads a manifest in the given format. The algorithm of a sum
+// manifest is derived from the checksum length.
+func ReadManifest(r io.Reader, f ManifestFormat) (*Manifest, error) {
+	if f == MHLFormat {
+		return readMHL(r)
+	}
+
+	m := &Manifest{Algorithm: -1}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: invalid checksum line", line)
+		}
+		sum := strings.ToLower(parts[0])
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("line %d: invalid checksum %q", line, parts[0])
+		}
+		var a HashAlgorithm
+		switch len(sum) {
+		case 2 * md5.Size:
+			a = MD5
+		case 2 * sha256.Size:
+			a = SHA256
+		case 2 * 8:
+			a = XXH64
+		default:
+			return nil, fmt.Errorf("line %d: unsupported checksum length %d", line, len(sum))
+		}
+		if m.Algorithm >= 0 && m.Algorithm != a {
+			return nil, fmt.Errorf("line %d: mixed hash algorithms", line)
+		}
+		m.Algorithm = a
+		// The filename follows a space and a mode character ('*' for binary).
+		file := strings.TrimPrefix(strings.TrimPrefix(parts[1], " "), "*")
+		m.Set(&ManifestEntry{File: file, Sum: sum})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if m.Algorithm < 0 {
+		m.Algorithm = MD5
+	}
+	return m, nil
+}
+
+// Write the manifest in the given format.
+func (m *Manifest) Write(w io.Writer, f ManifestFormat) error {
+	if f == MHLFormat {
+		return m.writeMHL(w)
+	}
+	bw := bufio.NewWriter(w)
+	for _, e := range m.Entries {
+		fmt.Fprintf(bw, "%s  %s\n", e.Sum, e.File)
+	}
+	return bw.Flush()
+}
+
+//-----------------------------------------------------------------------------
+// Media Hash List
+
+const mhlTime = "2006-01-02T15:04:05Z"
+
+type mhlHashList struct {
+	XMLName     xml.Name       `xml:"hashlist"`
+	Version     string         `xml:"version,attr"`
+	CreatorInfo mhlCreatorInfo `xml:"creatorinfo"`
+	Hashes      []mhlHash      `xml:"hash"`
+}
+
+type mhlCreatorInfo struct {
+	Hostname   string `xml:"hostname,omitempty"`
+	Tool       string `xml:"tool"`
+	StartDate  string `xml:"startdate"`
+	FinishDate string `xml:"finishdate"`
+}
+
+type mhlHash struct {
+	File                 string `xml:"file"`
+	Size                 int64  `xml:"size"`
+	LastModificationDate string `xml:"lastmodificationdate,omitempty"`
+	MD5                  string `xml:"md5,omitempty"`
+	XXHash64BE           string `xml:"xxhash64be,omitempty"`
+	HashDate             string `xml:"hashdate"`
+}
+
+func (m *Manifest) writeMHL(w io.Writer) error {
+	if hashAlgorithms[m.Algorithm].mhl == "" {
+		return fmt.Errorf("hash algorithm %s is not supported by MHL", m.Algorithm)
+	}
+	created := m.Created.UTC().Format(mhlTime)
+	hostname, _ := os.Hostname()
+	l := mhlHashList{
+		Version: "1.1",
+		CreatorInfo: mhlCreatorInfo{
+			Hostname:   hostname,
+			Tool:       "tracks",
+			StartDate:  created,
+			FinishDate: created,
+		},
+	}
+	for _, e := range m.Entries {
+		h := mhlHash{File: e.File, Size: e.Size, HashDate: created}
+		if !e.ModTime.IsZero() {
+			h.LastModificationDate = e.ModTime.UTC().Format(mhlTime)
+		}
+		switch m.Algorithm {
+		case MD5:
+			h.MD5 = e.Sum
+		case XXH64:
+			h.XXHash64BE = e.Sum
+		}
+		l.Hashes = append(l.Hashes, h)
+	}
+	data, err := xml.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
+
+func readMHL(r io.Reader) (*Manifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := mhlHashList{}
+	if err := xml.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("error parsing MHL; %s", err)
+	}
+	m := &Manifest{}
+	m.Created, _ = time.Parse(mhlTime, l.CreatorInfo.StartDate)
+	for i, h := range l.Hashes {
+		e := &ManifestEntry{File: h.File, Size: h.Size}
+		e.ModTime, _ = time.Parse(mhlTime, h.LastModificationDate)
+		var a HashAlgorithm
+		switch {
+		case h.MD5 != "":
+			a, e.Sum = MD5, strings.ToLower(h.MD5)
+		case h.XXHash64BE != "":
+			a, e.Sum = XXH64, strings.ToLower(h.XXHash64BE)
+		default:
+			return nil, fmt.Errorf("hash %d: no supported checksum for %q", i+1, h.File)
+		}
+		if i > 0 && a != m.Algorithm {
+			return nil, fmt.Errorf("hash %d: mixed hash algorithms", i+1)
+		}
+		m.Algorithm = a
+		m.Set(e)
+	}
+	return m, nil
+}
+
+//-----------------------------------------------------------------------------
+// Verification
+
+// Verification is the result of verifying a directory against a manifest.
+type Verification struct {
+	OK      []string `json:"ok" yaml:"ok"`
+	Missing []string `json:"missing" yaml:"missing"` // In the manifest, but not in the directory.
+	Extra   []string `json:"extra" yaml:"extra"`     // In the directory, but not in the manifest.
+	Corrupt []string `json:"corrupt" yaml:"corrupt"` // Checksum or size mismatch.
+}
+
+// Passed returns true if no files were missing, extra or corrupt.
+func (v *Verification) Passed() bool {
+	return len(v.Missing) == 0 && len(v.Extra) == 0 && len(v.Corrupt) == 0
+}
+
+// VerifyManifest re-checks the files of dir against a manifest. The ignore
+// files (e.g. the manifest itself) and hidden files are never extra.
+func VerifyManifest(dir string, m *Manifest, ignore ...string) (*Verification, error) {
+	v := &Verification{OK: []string{}, Missing: []string{}, Extra: []string{}, Corrupt: []string{}}
+	for _, e := range m.Entries {
+		got, err := m.Checksum(dir, e.File)
+		switch {
+		case os.IsNotExist(err):
+			v.Missing = append(v.Missing, e.File)
+		case err != nil:
+			return nil, err
+		case got.Sum != strings.ToLower(e.Sum) || (e.Size > 0 && got.Size != e.Size):
+			v.Corrupt = append(v.Corrupt, e.File)
+		default:
+			v.OK = append(v.OK, e.File)
+		}
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	skip := map[string]bool{}
+	for _, f := range ignore {
+		skip[f] = true
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || skip[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if m.Entry(name) == nil {
+			v.Extra = append(v.Extra, name)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,194 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "abc.wav"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		sum  string
+	}{
+		{"md5", "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"xxh64", "44bc2cf5ad770999"},
+	} {
+		a, err := ParseHashAlgorithm(tt.name)
+		if err != nil {
+			t.Errorf("ParseHashAlgorithm(%q) unexpected error %s", tt.name, err)
+			continue
+		}
+		if got, want := a.String(), tt.name; got != want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, want)
+		}
+		e, err := NewManifest(a).Checksum(dir, "abc.wav")
+		if err != nil {
+			t.Errorf("%s: Checksum() unexpected error %s", tt.name, err)
+			continue
+		}
+		if got, want := e.Sum, tt.sum; got != want {
+			t.Errorf("%s: Checksum() = %s, want %s", tt.name, got, want)
+		}
+	}
+	if _, err := ParseHashAlgorithm("crc32"); err == nil {
+		t.Errorf("ParseHashAlgorithm() expected error")
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		data  string
+		ok    bool
+		alg   HashAlgorithm
+		files []string
+	}{
+		{"md5sum",
+			"900150983cd24fb0d6963f7d28e17f72  01-02 Snare.wav\nd41d8cd98f00b204e9800998ecf8427e *01-01 Kick.wav\n",
+			true, MD5, []string{"01-01 Kick.wav", "01-02 Snare.wav"}},
+		{"xxh64sum", "44bc2cf5ad770999  01-01 Kick.wav\n", true, XXH64, []string{"01-01 Kick.wav"}},
+		{"comments", "# tracks\n\n44bc2cf5ad770999  a.wav\n", true, XXH64, []string{"a.wav"}},
+		{"mixed",
+			"44bc2cf5ad770999  a.wav\nd41d8cd98f00b204e9800998ecf8427e  b.wav\n",
+			false, 0, nil},
+		{"not hex", "xyz  a.wav\n", false, 0, nil},
+		{"bad length", "abcd  a.wav\n", false, 0, nil},
+		{"no file", "44bc2cf5ad770999\n", false, 0, nil},
+	} {
+		m, err := ReadManifest(strings.NewReader(tt.data), SumFormat)
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: ReadManifest() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if got, want := m.Algorithm, tt.alg; got != want {
+			t.Errorf("%s: Algorithm = %s, want %s", tt.desc, got, want)
+		}
+		files := []string{}
+		for _, e := range m.Entries {
+			files = append(files, e.File)
+		}
+		if got, want := files, tt.files; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: files = %q, want %q", tt.desc, got, want)
+		}
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	mod := time.Date(2017, 9, 6, 22, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		alg    HashAlgorithm
+		format ManifestFormat
+		ok     bool
+	}{
+		{MD5, SumFormat, true},
+		{SHA256, SumFormat, true},
+		{XXH64, SumFormat, true},
+		{MD5, MHLFormat, true},
+		{XXH64, MHLFormat, true},
+		{SHA256, MHLFormat, false},
+	} {
+		desc := tt.alg.String() + " " + tt.format.String()
+		m := NewManifest(tt.alg)
+		sum := strings.Repeat("0f", map[HashAlgorithm]int{MD5: 16, SHA256: 32, XXH64: 8}[tt.alg])
+		m.Set(&ManifestEntry{File: "01-02 Snare.wav", Size: 2, ModTime: mod, Sum: sum})
+		m.Set(&ManifestEntry{File: "01-01 Kick & Sub.wav", Size: 1, ModTime: mod, Sum: sum})
+
+		b := &bytes.Buffer{}
+		err := m.Write(b, tt.format)
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: Write() unexpected error %s", desc, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		got, err := ReadManifest(b, tt.format)
+		if err != nil {
+			t.Errorf("%s: ReadManifest() unexpected error %s", desc, err)
+			continue
+		}
+		if got.Algorithm != tt.alg || len(got.Entries) != 2 {
+			t.Errorf("%s: ReadManifest() = %s with %d entries, want %s with 2", desc, got.Algorithm, len(got.Entries), tt.alg)
+			continue
+		}
+		if e := got.Entry("01-01 Kick & Sub.wav"); e == nil || e.Sum != sum {
+			t.Errorf("%s: Entry() = %+v, want sum %s", desc, e, sum)
+		}
+		if tt.format == MHLFormat {
+			if e := got.Entry("01-02 Snare.wav"); e == nil || e.Size != 2 || !e.ModTime.Equal(mod) {
+				t.Errorf("%s: Entry() = %+v, want size 2 and modified %s", desc, e, mod)
+			}
+		}
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(file, data string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(data), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	for _, f := range []string{"ok.wav", "corrupt.wav", "missing.wav"} {
+		write(f, f)
+	}
+
+	m := NewManifest(SHA256)
+	for _, f := range []string{"ok.wav", "corrupt.wav", "missing.wav"} {
+		e, err := m.Checksum(dir, f)
+		if err != nil {
+			t.Fatalf("Checksum() unexpected error %s", err)
+		}
+		m.Set(e)
+	}
+	write("corrupt.wav", "corrupt!wav")
+	os.Remove(filepath.Join(dir, "missing.wav"))
+	write("extra.wav", "extra")
+	write("SHA256SUMS", "")
+	write(".DS_Store", "")
+
+	v, err := VerifyManifest(dir, m, "SHA256SUMS")
+	if err != nil {
+		t.Fatalf("VerifyManifest() unexpected error %s", err)
+	}
+	if v.Passed() {
+		t.Errorf("Passed() = true, want false")
+	}
+	for _, tt := range []struct {
+		desc string
+		got  []string
+		want []string
+	}{
+		{"ok", v.OK, []string{"ok.wav"}},
+		{"missing", v.Missing, []string{"missing.wav"}},
+		{"extra", v.Extra, []string{"extra.wav"}},
+		{"corrupt", v.Corrupt, []string{"corrupt.wav"}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("VerifyManifest() %s = %q, want %q", tt.desc, tt.got, tt.want)
+		}
+	}
+}
@@ -1,26 +1,48 @@
 package actions
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/kward/tracks/tracks"
 	"github.com/kward/tracks/venue"
 )
 
-// MapTracksToNames based on their channel name.
-func MapTracksToNames(ts tracks.Tracks, devs venue.Devices) (tracks.Tracks, error) {
+// Fallback names a track that has no named input channel. It returns false if
+// it has no name for the track.
+type Fallback func(t *tracks.Track, devs venue.Devices) (string, tracks.NameSource, bool)
+
+// DefaultFallbacks are used by MapTracksToNames if none are given.
+var DefaultFallbacks = []Fallback{FallbackProTools}
+
+// MapTracksToNames based on their channel name. Tracks without a named channel,
+// including tracks beyond the available stage box inputs, are named by the
+// first fallback with a name for them, or FallbackNumber if none has.
+func MapTracksToNames(ts tracks.Tracks, devs venue.Devices, fallbacks ...Fallback) (tracks.Tracks, error) {
+	if len(fallbacks) == 0 {
+		fallbacks = DefaultFallbacks
+	}
+	chain := append(append([]Fallback{}, fallbacks...), FallbackNumber)
 	for i, t := range ts {
-		ch, err := mapTrackToChannel(t, devs)
-		if err != nil {
-			return nil, fmt.Errorf("error mapping track %q to channel; %s", t.Src(), err)
+		if ch, err := mapTrackToChannel(t, devs); err == nil && ch.CleanName() != "" {
+			ts[i].SetName(ch.CleanName()).SetNameSource(tracks.NameFromChannel)
+			continue
+		}
+		for _, fb := range chain {
+			if name, src, ok := fb(t, devs); ok {
+				ts[i].SetName(name).SetNameSource(src)
+				break
+			}
 		}
-		ts[i].SetName(ch.CleanName())
 	}
 	return ts, nil
 }
 
-// mapTrackToChannel maps a track name to the appropriate channel name.
+// mapTrackToChannel maps a track name to the appropriate input channel.
 //
 // Venue only maps the stage box inputs directly to output files. Other inputs
 // such as the "Engine AES 1" input are not mapped. To record them, the must
@@ -31,27 +53,68 @@ func mapTrackToChannel(t *tracks.Track, devs venue.Devices) (*venue.Channel, err
 	if ch == nil {
 		return nil, fmt.Errorf("channel not found")
 	}
-	if ch.Name() != "" {
-		return ch, nil
-	}
+	return ch, nil
+}
 
-	// See if the ProTools device is available.
+// FallbackProTools names a track after its Pro Tools output, if available.
+func FallbackProTools(t *tracks.Track, devs venue.Devices) (string, tracks.NameSource, bool) {
 	dev, ok := devs[venue.ProTools]
 	if !ok {
-		return ch, nil
+		return "", tracks.NameFromProTools, false
 	}
-	m := venue.Moniker(t.TrackNum())
-	ptch := dev.Output(m)
-	if ptch.Name() != "" {
-		return ptch, nil
+	name := dev.Output(venue.Moniker(t.TrackNum())).CleanName()
+	return name, tracks.NameFromProTools, name != ""
+}
+
+// FallbackOriginal names a track after its original file, e.g. "Track 01-1".
+func FallbackOriginal(t *tracks.Track, _ venue.Devices) (string, tracks.NameSource, bool) {
+	name := strings.TrimSuffix(t.Src(), filepath.Ext(t.Src()))
+	return name, tracks.NameFromOriginal, name != ""
+}
+
+// FallbackNumber names a track after its number, e.g. "Track 01".
+func FallbackNumber(t *tracks.Track, _ venue.Devices) (string, tracks.NameSource, bool) {
+	return fmt.Sprintf("Track %02d", t.TrackNum()), tracks.NameFromNumber, true
+}
+
+// FallbackNames returns a Fallback that names tracks from a map of track
+// numbers to names, e.g. as read by ReadFallbackNames.
+func FallbackNames(names map[int]string) Fallback {
+	return func(t *tracks.Track, _ venue.Devices) (string, tracks.NameSource, bool) {
+		name, ok := names[t.TrackNum()]
+		return name, tracks.NameFromFallbackFile, ok && name != ""
 	}
+}
 
-	return ch, nil
+// ReadFallbackNames reads a CSV file of track number and name pairs. A header
+// line is skipped if present.
+func ReadFallbackNames(r io.Reader) (map[int]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	names := map[int]string{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		tnum, err := strconv.Atoi(rec[0])
+		if err != nil {
+			if line == 1 {
+				continue // Header.
+			}
+			return nil, fmt.Errorf("line %d: invalid track number %q", line, rec[0])
+		}
+		names[tnum] = rec[1]
+	}
+	return names, nil
 }
 
-// MapTrackNameToFilename returns a valid filename for a track name.
+// MapTrackNameToFilename returns a valid filename for a track name, using the
+// Portable filename policy.
 func MapTrackNameToFilename(name string) string {
-	name = strings.Replace(name, "/", "_", -1)  // Unix path separator.
-	name = strings.Replace(name, "\\", "_", -1) // Windows path separator.
-	return name
+	return Portable.sanitize(name)
 }
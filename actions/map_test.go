@@ -1,6 +1,9 @@
 package actions
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kward/tracks/tracks"
@@ -81,6 +84,77 @@ func TestIssue7(t *testing.T) {
 	}
 }
 
+func TestMapTracksToNames(t *testing.T) {
+	devs := mockDevices()
+	devs[venue.Stage1].Inputs()["2"] = venue.NewChannel("2", "")
+	devs[venue.ProTools] = venue.NewDevice(
+		hardware.ProTools,
+		venue.ProTools,
+		venue.Channels{},
+		venue.Channels{
+			"Pro Tools 2": venue.NewChannel("Pro Tools 2", "ptTwo"),
+			"Pro Tools 9": venue.NewChannel("Pro Tools 9", "ptNine")},
+	)
+
+	for _, tt := range []struct {
+		desc      string
+		fallbacks []Fallback
+		tnum      int
+		name      string
+		source    tracks.NameSource
+	}{
+		{"named channel", nil, 1, "iOne", tracks.NameFromChannel},
+		{"unnamed channel", nil, 2, "ptTwo", tracks.NameFromProTools},
+		{"missing channel", nil, 9, "ptNine", tracks.NameFromProTools},
+		{"missing everything", nil, 10, "Track 10", tracks.NameFromNumber},
+		{"number", []Fallback{FallbackNumber}, 2, "Track 02", tracks.NameFromNumber},
+		{"original", []Fallback{FallbackOriginal}, 9, "Track 09-1", tracks.NameFromOriginal},
+		{"names", []Fallback{FallbackNames(map[int]string{9: "Spare"}), FallbackProTools}, 9, "Spare", tracks.NameFromFallbackFile},
+		{"names missing", []Fallback{FallbackNames(map[int]string{9: "Spare"}), FallbackProTools}, 2, "ptTwo", tracks.NameFromProTools},
+	} {
+		tr := tracks.NewTrack("Track", tt.tnum, 1).SetSrc(fmt.Sprintf("Track %02d-1.wav", tt.tnum))
+		ts, err := MapTracksToNames(tracks.Tracks{tt.tnum: tr}, devs, tt.fallbacks...)
+		if err != nil {
+			t.Errorf("%s: MapTracksToNames() unexpected error; %s", tt.desc, err)
+			continue
+		}
+		if got, want := ts[tt.tnum].Name(), tt.name; got != want {
+			t.Errorf("%s: MapTracksToNames() name = %q, want %q", tt.desc, got, want)
+		}
+		if got, want := ts[tt.tnum].NameSource(), tt.source; got != want {
+			t.Errorf("%s: MapTracksToNames() source = %s, want %s", tt.desc, got, want)
+		}
+	}
+}
+
+func TestReadFallbackNames(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		data  string
+		names map[int]string
+		ok    bool
+	}{
+		{"no header", "1,Kick\n2, Snare\n", map[int]string{1: "Kick", 2: "Snare"}, true},
+		{"header", "track,name\n49,Spare\n", map[int]string{49: "Spare"}, true},
+		{"bad track", "track,name\nx,Spare\n", nil, false},
+		{"bad fields", "1,Kick,extra\n", nil, false},
+	} {
+		names, err := ReadFallbackNames(strings.NewReader(tt.data))
+		if err == nil && !tt.ok {
+			t.Errorf("%s: ReadFallbackNames() expected error", tt.desc)
+		}
+		if err != nil && tt.ok {
+			t.Errorf("%s: ReadFallbackNames() unexpected error; %s", tt.desc, err)
+		}
+		if !tt.ok {
+			continue
+		}
+		if got, want := names, tt.names; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ReadFallbackNames() = %v, want %v", tt.desc, got, want)
+		}
+	}
+}
+
 func TestMapTrackNameToFilename(t *testing.T) {
 	for _, tt := range []struct {
 		desc     string
@@ -90,6 +164,7 @@ func TestMapTrackNameToFilename(t *testing.T) {
 		{"clean", "abc123", "abc123"},
 		{"unix separator", "abc/123", "abc_123"},
 		{"windows separator", "abc\\123", "abc_123"},
+		{"windows invalid", "abc:123?", "abc_123_"},
 		{"empty", "", ""},
 	} {
 		if got, want := MapTrackNameToFilename(tt.name), tt.filename; got != want {
@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+)
+
+var (
+	fnRename = os.Rename
+)
+
+// Move moves src to dest. When dest is on another filesystem, where a rename
+// is not possible, src is copied to dest instead, keeping its permissions and
+// modification time, and only removed once the copy has been verified.
+func Move(src, dest string) error {
+	err := fnRename(src, dest)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if _, _, err := CopyVerified(src, dest); err != nil {
+		return err
+	}
+	if err := os.Chmod(dest, fi.Mode().Perm()); err != nil {
+		return fmt.Errorf("error setting permissions of %q; %s", dest, err)
+	}
+	if err := os.Chtimes(dest, fi.ModTime(), fi.ModTime()); err != nil {
+		return fmt.Errorf("error setting modification time of %q; %s", dest, err)
+	}
+	return os.Remove(src)
+}
+
+// isCrossDevice returns true if a rename failed because the source and
+// destination are on different filesystems.
+func isCrossDevice(err error) bool {
+	le, ok := err.(*os.LinkError)
+	return ok && le.Err == errCrossDevice
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package actions
+
+import "syscall"
+
+var errCrossDevice error = syscall.EXDEV
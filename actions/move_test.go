@@ -0,0 +1,79 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	defer func() { fnRename = os.Rename }()
+
+	dir, err := ioutil.TempDir("", "move_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2017, 9, 6, 22, 30, 0, 0, time.Local)
+
+	for _, tt := range []struct {
+		desc    string
+		rename  func(src, dest string) error
+		ok      bool
+		removed bool // Whether the source should be removed.
+	}{
+		{"same filesystem", os.Rename, true, true},
+		{"cross device",
+			func(src, dest string) error {
+				return &os.LinkError{Op: "rename", Old: src, New: dest, Err: errCrossDevice}
+			},
+			true, true},
+		{"other error",
+			func(src, dest string) error {
+				return &os.LinkError{Op: "rename", Old: src, New: dest, Err: syscall.EACCES}
+			},
+			false, false},
+	} {
+		fnRename = tt.rename
+		src, dest := filepath.Join(dir, "Track 01-1.wav"), filepath.Join(dir, "01-01 Kick.wav")
+		os.Remove(dest)
+		if err := ioutil.WriteFile(src, []byte(tt.desc), 0640); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		if err := os.Chmod(src, 0640); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		if err := os.Chtimes(src, mtime, mtime); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+
+		err := Move(src, dest)
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: Move() unexpected error %v", tt.desc, err)
+			continue
+		}
+		if _, err := os.Stat(src); os.IsNotExist(err) != tt.removed {
+			t.Errorf("%s: source removed = %v, want %v", tt.desc, os.IsNotExist(err), tt.removed)
+		}
+		if !tt.ok {
+			continue
+		}
+		fi, err := os.Stat(dest)
+		if err != nil {
+			t.Errorf("%s: unexpected error; %s", tt.desc, err)
+			continue
+		}
+		if got, want := fi.Mode().Perm(), os.FileMode(0640); got != want {
+			t.Errorf("%s: dest mode = %s, want %s", tt.desc, got, want)
+		}
+		if got, want := fi.ModTime(), mtime; !got.Equal(want) {
+			t.Errorf("%s: dest modification time = %s, want %s", tt.desc, got, want)
+		}
+		if data, _ := ioutil.ReadFile(dest); string(data) != tt.desc {
+			t.Errorf("%s: dest = %q, want %q", tt.desc, data, tt.desc)
+		}
+	}
+}
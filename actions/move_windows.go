@@ -0,0 +1,6 @@
+package actions
+
+import "syscall"
+
+// ERROR_NOT_SAME_DEVICE
+var errCrossDevice error = syscall.Errno(17)
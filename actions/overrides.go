@@ -0,0 +1,224 @@
+package actions
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/kward/tracks/tracks"
+	"github.com/kward/tracks/venue"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Override replaces the name of the tracks it matches. An override matches
+// either a track number, or a stage box input by device and moniker (e.g.
+// "Stage 2" and "7"). It applies to all sessions, unless a session is given.
+//
+// When several overrides match a track, the most specific one wins: a session
+// override beats one for all sessions, and a track number beats a device
+// input. Between equally specific overrides, the last one wins.
+type Override struct {
+	Session int    `yaml:"session"`
+	Track   int    `yaml:"track"`
+	Device  string `yaml:"device"`
+	Moniker string `yaml:"moniker"`
+	Name    string `yaml:"name"`
+
+	loc string // Where the override was defined, e.g. "line 3".
+}
+
+// String implements the fmt.Stringer interface.
+func (o *Override) String() string {
+	s := []string{}
+	if o.Session != 0 {
+		s = append(s, fmt.Sprintf("session %d", o.Session))
+	}
+	if o.Track != 0 {
+		s = append(s, fmt.Sprintf("track %d", o.Track))
+	} else {
+		s = append(s, fmt.Sprintf("%s input %s", o.Device, o.Moniker))
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(s, " "), o.loc)
+}
+
+// Location returns where the override was defined, e.g. "line 3".
+func (o *Override) Location() string { return o.loc }
+
+// validate checks that the override matches exactly one kind of track.
+func (o *Override) validate() error {
+	switch {
+	case o.Name == "":
+		return fmt.Errorf("%s: missing name", o.loc)
+	case o.Track < 0 || o.Session < 0:
+		return fmt.Errorf("%s: negative track or session", o.loc)
+	case o.Track != 0 && (o.Device != "" || o.Moniker != ""):
+		return fmt.Errorf("%s: both track and device given", o.loc)
+	case o.Track == 0 && (o.Device == "" || o.Moniker == ""):
+		return fmt.Errorf("%s: missing track, or device and moniker", o.loc)
+	}
+	return nil
+}
+
+// specificity ranks how specific the override is. Higher is more specific.
+func (o *Override) specificity() int {
+	rank := 0
+	if o.Session != 0 {
+		rank += 2
+	}
+	if o.Track != 0 {
+		rank++
+	}
+	return rank
+}
+
+// matches returns true if the override applies to the track.
+func (o *Override) matches(t *tracks.Track, devs venue.Devices) bool {
+	if o.Session != 0 && o.Session != t.SessionNum() {
+		return false
+	}
+	if o.Track != 0 {
+		return o.Track == t.TrackNum()
+	}
+	num, ok := devs.InputNumber(o.Device, o.Moniker)
+	return ok && num == t.TrackNum()
+}
+
+// Overrides is a slice of overrides, in the order they were defined.
+type Overrides []*Override
+
+// Applied describes an override applied to a track.
+type Applied struct {
+	Override *Override
+	Track    *tracks.Track
+	Previous string // The track name before the override.
+}
+
+// ReadOverrides reads overrides in the given format, either "csv" or "yaml".
+//
+// A CSV file must start with a header naming its columns, from "session",
+// "track", "device", "moniker" and "name". A YAML file holds a list of
+// mappings with the same keys.
+func ReadOverrides(r io.Reader, format string) (Overrides, error) {
+	var (
+		ovs Overrides
+		err error
+	)
+	switch strings.ToLower(format) {
+	case "csv":
+		ovs, err = readCSVOverrides(r)
+	case "yaml", "yml":
+		ovs, err = readYAMLOverrides(r)
+	default:
+		return nil, fmt.Errorf("unsupported overrides format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range ovs {
+		if err := o.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return ovs, nil
+}
+
+func readCSVOverrides(r io.Reader) (Overrides, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header; %s", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch h {
+		case "session", "track", "device", "moniker", "name":
+			cols[h] = i
+		default:
+			return nil, fmt.Errorf("line 1: unknown column %q", h)
+		}
+	}
+	if _, ok := cols["name"]; !ok {
+		return nil, fmt.Errorf("line 1: missing name column")
+	}
+
+	ovs := Overrides{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		o := &Override{loc: fmt.Sprintf("line %d", line)}
+		for col, i := range cols {
+			val := rec[i]
+			switch col {
+			case "session", "track":
+				if val == "" {
+					continue
+				}
+				num, err := strconv.Atoi(val)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid %s %q", line, col, val)
+				}
+				if col == "session" {
+					o.Session = num
+				} else {
+					o.Track = num
+				}
+			case "device":
+				o.Device = val
+			case "moniker":
+				o.Moniker = val
+			case "name":
+				o.Name = val
+			}
+		}
+		ovs = append(ovs, o)
+	}
+	return ovs, nil
+}
+
+func readYAMLOverrides(r io.Reader) (Overrides, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ovs := Overrides{}
+	if err := yaml.UnmarshalStrict(data, &ovs); err != nil {
+		return nil, err
+	}
+	for i, o := range ovs {
+		o.loc = fmt.Sprintf("entry %d", i+1)
+	}
+	return ovs, nil
+}
+
+// ApplyOverrides renames the tracks matched by an override. It is meant to be
+// called after MapTracksToNames, and returns the overrides that were applied.
+func ApplyOverrides(ts tracks.Tracks, devs venue.Devices, ovs Overrides) []*Applied {
+	applied := []*Applied{}
+	for _, t := range ts.Slice() {
+		var best *Override
+		for _, o := range ovs {
+			if !o.matches(t, devs) {
+				continue
+			}
+			if best == nil || o.specificity() >= best.specificity() {
+				best = o
+			}
+		}
+		if best == nil {
+			continue
+		}
+		applied = append(applied, &Applied{best, t, t.Name()})
+		t.SetName(best.Name).SetNameSource(tracks.NameFromOverride)
+	}
+	return applied
+}
@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kward/tracks/tracks"
+)
+
+func TestReadOverrides(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		data      string
+		format    string
+		overrides []string
+		ok        bool
+	}{
+		{"csv",
+			"track,name\n23,Guest Vox\n",
+			"csv",
+			[]string{"track 23 (line 2)"},
+			true},
+		{"csv all columns",
+			"session,track,device,moniker,name\n2,5,,,Spare\n,,Stage 2,7,Keys\n",
+			"csv",
+			[]string{"session 2 track 5 (line 2)", "Stage 2 input 7 (line 3)"},
+			true},
+		{"csv unknown column", "track,label\n23,Vox\n", "csv", nil, false},
+		{"csv missing name", "track\n23\n", "csv", nil, false},
+		{"csv bad track", "track,name\nx,Vox\n", "csv", nil, false},
+		{"yaml",
+			"- track: 23\n  name: Guest Vox\n- device: Stage 2\n  moniker: 7\n  name: Keys\n",
+			"yaml",
+			[]string{"track 23 (entry 1)", "Stage 2 input 7 (entry 2)"},
+			true},
+		{"yaml unknown key", "- track: 23\n  label: Vox\n", "yml", nil, false},
+		{"track and device", "- track: 23\n  device: Stage 1\n  moniker: 1\n  name: Vox\n", "yaml", nil, false},
+		{"missing device moniker", "- device: Stage 1\n  name: Vox\n", "yaml", nil, false},
+		{"unsupported format", "", "json", nil, false},
+	} {
+		ovs, err := ReadOverrides(strings.NewReader(tt.data), tt.format)
+		if err == nil && !tt.ok {
+			t.Errorf("%s: ReadOverrides() expected error", tt.desc)
+		}
+		if err != nil && tt.ok {
+			t.Errorf("%s: ReadOverrides() unexpected error; %s", tt.desc, err)
+		}
+		if !tt.ok {
+			continue
+		}
+		got := []string{}
+		for _, o := range ovs {
+			got = append(got, o.String())
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.overrides, ", ") {
+			t.Errorf("%s: ReadOverrides() = %v, want %v", tt.desc, got, tt.overrides)
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	devs := mockDevices()
+	ovs, err := ReadOverrides(strings.NewReader(`
+- track: 1
+  name: All One
+- session: 2
+  track: 1
+  name: Session One
+- device: Stage 1
+  moniker: 1
+  name: Device One
+- device: Stage 2
+  moniker: 2
+  name: Device Six
+- track: 6
+  name: Track Six
+- session: 2
+  device: Stage 2
+  moniker: 3
+  name: Session Seven
+- track: 7
+  name: Track Seven
+`), "yaml")
+	if err != nil {
+		t.Fatalf("ReadOverrides() unexpected error; %s", err)
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		snum   int
+		tnum   int
+		name   string
+		source tracks.NameSource
+	}{
+		{"no override", 1, 2, "iTwo", tracks.NameFromChannel},
+		{"track beats device", 1, 1, "All One", tracks.NameFromOverride},
+		{"session beats all", 2, 1, "Session One", tracks.NameFromOverride},
+		{"track beats earlier device", 1, 6, "Track Six", tracks.NameFromOverride},
+		{"session device beats track", 2, 7, "Session Seven", tracks.NameFromOverride},
+		{"other session", 1, 7, "Track Seven", tracks.NameFromOverride},
+	} {
+		ts, _ := MapTracksToNames(tracks.Tracks{tt.tnum: tracks.NewTrack("Track", tt.tnum, tt.snum)}, devs)
+		applied := ApplyOverrides(ts, devs, ovs)
+		if got, want := ts[tt.tnum].Name(), tt.name; got != want {
+			t.Errorf("%s: ApplyOverrides() name = %q, want %q", tt.desc, got, want)
+		}
+		if got, want := ts[tt.tnum].NameSource(), tt.source; got != want {
+			t.Errorf("%s: ApplyOverrides() source = %s, want %s", tt.desc, got, want)
+		}
+		if tt.source != tracks.NameFromOverride {
+			if len(applied) != 0 {
+				t.Errorf("%s: ApplyOverrides() applied %d overrides, want 0", tt.desc, len(applied))
+			}
+			continue
+		}
+		if len(applied) != 1 {
+			t.Errorf("%s: ApplyOverrides() applied %d overrides, want 1", tt.desc, len(applied))
+			continue
+		}
+		if got, want := applied[0].Override.Name, tt.name; got != want {
+			t.Errorf("%s: ApplyOverrides() applied %q, want %q", tt.desc, got, want)
+		}
+	}
+}
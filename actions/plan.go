@@ -0,0 +1,163 @@
+package actions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kward/tracks/tracks"
+)
+
+// Plan describes the file operations of a copy, link or move, so that they
+// can be reviewed and edited before being applied.
+//
+// Only the entry Src and Dest are used when the plan is applied. The other
+// entry fields are informational.
+type Plan struct {
+	Action  string       `json:"action,omitempty"` // "copy", "link" or "move".
+	SrcDir  string       `json:"src_dir,omitempty"`
+	DestDir string       `json:"dest_dir,omitempty"`
+	Entries []*PlanEntry `json:"entries"`
+	Unused  Overrides    `json:"-"` // The overrides matching no track, if planned.
+	Ignored []string     `json:"-"` // The files that are not recordings, if planned.
+}
+
+// srcDir returns the source directory, which defaults to the working directory.
+func (p *Plan) srcDir() string {
+	if p.SrcDir == "" {
+		return "."
+	}
+	return p.SrcDir
+}
+
+// destDir returns the destination directory, which defaults to the source.
+func (p *Plan) destDir() string {
+	if p.DestDir == "" {
+		return p.srcDir()
+	}
+	return p.DestDir
+}
+
+// PlanEntry describes the operation on a single track.
+type PlanEntry struct {
+	Src        string            `json:"src"`  // Relative to the plan SrcDir.
+	Dest       string            `json:"dest"` // Relative to the plan DestDir.
+	Session    int               `json:"session"`
+	Track      int               `json:"track"`
+	Name       string            `json:"name"`
+	NameSource tracks.NameSource `json:"name_source"`
+	Channel    string            `json:"channel,omitempty"` // The stage box input, e.g. "Stage 1 3".
+	Override   *Applied          `json:"-"`                 // Non-nil if the name was overridden.
+}
+
+// Operation returns the, as yet unperformed, operation on the entry.
+func (e *PlanEntry) Operation(srcDir, destDir string) *Operation {
+	op := &Operation{
+		Src:        fmt.Sprintf("%s/%s", srcDir, e.Src),
+		Dest:       fmt.Sprintf("%s/%s", destDir, e.Dest),
+		Session:    e.Session,
+		Track:      e.Track,
+		Name:       e.Name,
+		NameSource: e.NameSource,
+		Channel:    e.Channel,
+	}
+	if e.Override != nil {
+		op.Override = e.Override.Override.String()
+	}
+	return op
+}
+
+var planColumns = []string{"src", "dest", "session", "track", "name", "name_source"}
+
+// ReadPlan reads a plan in the given format, either "json" or "csv". A CSV
+// plan only holds the entries, starting with a header line.
+func ReadPlan(r io.Reader, format string) (*Plan, error) {
+	var (
+		p   *Plan
+		err error
+	)
+	switch strings.ToLower(format) {
+	case "json":
+		p = &Plan{}
+		err = json.NewDecoder(r).Decode(p)
+	case "csv":
+		p, err = readCSVPlan(r)
+	default:
+		return nil, fmt.Errorf("unsupported plan format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range p.Entries {
+		if e.Src == "" || e.Dest == "" {
+			return nil, fmt.Errorf("entry %d: missing src or dest", i+1)
+		}
+	}
+	return p, nil
+}
+
+func readCSVPlan(r io.Reader) (*Plan, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(planColumns)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header; %s", err)
+	}
+	for i, col := range planColumns {
+		if strings.ToLower(strings.TrimSpace(header[i])) != col {
+			return nil, fmt.Errorf("line 1: column %d is %q, want %q", i+1, header[i], col)
+		}
+	}
+
+	p := &Plan{Entries: []*PlanEntry{}}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := &PlanEntry{Src: rec[0], Dest: rec[1], Name: rec[4]}
+		if e.Session, err = strconv.Atoi(rec[2]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid session %q", line, rec[2])
+		}
+		if e.Track, err = strconv.Atoi(rec[3]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid track %q", line, rec[3])
+		}
+		if err := e.NameSource.UnmarshalText([]byte(rec[5])); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		p.Entries = append(p.Entries, e)
+	}
+	return p, nil
+}
+
+// Write the plan in the given format, either "json" or "csv".
+func (p *Plan) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case "json":
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(planColumns)
+		for _, e := range p.Entries {
+			cw.Write([]string{
+				e.Src, e.Dest,
+				strconv.Itoa(e.Session), strconv.Itoa(e.Track),
+				e.Name, e.NameSource.String(),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unsupported plan format %q", format)
+}
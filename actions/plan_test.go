@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kward/tracks/tracks"
+)
+
+func TestPlanRoundTrip(t *testing.T) {
+	plan := &Plan{
+		Action:  "move",
+		SrcDir:  "src",
+		DestDir: "dest",
+		Entries: []*PlanEntry{
+			{Src: "Track 01-1.wav", Dest: "01-01 Kick.wav", Session: 1, Track: 1, Name: "Kick", NameSource: tracks.NameFromChannel},
+			{Src: "Track 49-1.wav", Dest: "01-49 Track 49.wav", Session: 1, Track: 49, Name: "Track 49", NameSource: tracks.NameFromNumber},
+		},
+	}
+	for _, tt := range []struct {
+		format string
+		want   *Plan
+	}{
+		{"json", plan},
+		// CSV plans only hold the entries.
+		{"csv", &Plan{Entries: plan.Entries}},
+	} {
+		var buf bytes.Buffer
+		if err := plan.Write(&buf, tt.format); err != nil {
+			t.Errorf("%s: Write() unexpected error; %s", tt.format, err)
+			continue
+		}
+		got, err := ReadPlan(&buf, tt.format)
+		if err != nil {
+			t.Errorf("%s: ReadPlan() unexpected error; %s", tt.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadPlan() = %+v, want %+v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestReadPlan(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		data   string
+		format string
+		ok     bool
+	}{
+		{"json", `{"entries": [{"src": "a.wav", "dest": "b.wav", "name_source": "override"}]}`, "json", true},
+		{"json missing dest", `{"entries": [{"src": "a.wav"}]}`, "json", false},
+		{"json bad source", `{"entries": [{"src": "a.wav", "dest": "b.wav", "name_source": "bogus"}]}`, "json", false},
+		{"csv", "src,dest,session,track,name,name_source\na.wav,b.wav,1,2,b,original\n", "csv", true},
+		{"csv bad header", "dest,src,session,track,name,name_source\n", "csv", false},
+		{"csv bad track", "src,dest,session,track,name,name_source\na.wav,b.wav,1,x,b,original\n", "csv", false},
+		{"csv bad source", "src,dest,session,track,name,name_source\na.wav,b.wav,1,2,b,bogus\n", "csv", false},
+		{"unsupported", "", "xml", false},
+	} {
+		_, err := ReadPlan(strings.NewReader(tt.data), tt.format)
+		if err == nil && !tt.ok {
+			t.Errorf("%s: ReadPlan() expected error", tt.desc)
+		}
+		if err != nil && tt.ok {
+			t.Errorf("%s: ReadPlan() unexpected error; %s", tt.desc, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kward/tracks/tracks"
+	"github.com/kward/tracks/venue"
+)
+
+// Planner plans the renaming of recorded tracks, naming them after the input
+// channels of a Venue patch. The zero value of each option is usable, e.g.
+// with no fallbacks the DefaultFallbacks are used.
+type Planner struct {
+	Devices   venue.Devices // The devices of the Venue patch.
+	Fallbacks []Fallback    // Name the tracks without a named channel.
+	Overrides Overrides     // Override the Venue names.
+	Policy    FilenamePolicy
+	MaxLength int    // The maximum filename length; see FilenamePolicy.Filename().
+	Ext       string // The filename extension; the zero value is ".wav".
+}
+
+// NewPlanner returns a planner for the devices of a Venue patch.
+func NewPlanner(devs venue.Devices) *Planner {
+	return &Planner{Devices: devs}
+}
+
+// Plan returns the plan renaming the files, e.g. as returned by
+// DiscoverFiles() or ReadFileList(). The plan action and directories are left
+// for the caller to set.
+func (p *Planner) Plan(files []string) (*Plan, error) {
+	sessions, err := tracks.ExtractSessions(files)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting sessions; %s", err)
+	}
+
+	// Map tracks to stage boxes.
+	applied := map[*tracks.Track]*Applied{}
+	used := map[*Override]bool{}
+	for _, s := range sessions {
+		ts, err := MapTracksToNames(s.Tracks(), p.Devices, p.Fallbacks...)
+		if err != nil {
+			return nil, fmt.Errorf("error mapping tracks; %s", err)
+		}
+		for _, a := range ApplyOverrides(ts, p.Devices, p.Overrides) {
+			applied[a.Track] = a
+			used[a.Override] = true
+		}
+		s.SetTracks(ts)
+	}
+	plan := &Plan{Entries: []*PlanEntry{}, Ignored: tracks.IgnoredFiles(files)}
+	for _, o := range p.Overrides {
+		if !used[o] {
+			plan.Unused = append(plan.Unused, o)
+		}
+	}
+
+	// Map tracks to new names.
+	for _, s := range sessions.Slice() {
+		for _, t := range s.Tracks().Slice() {
+			t.SetDest(p.Filename(s.Num(), t.TrackNum(), t.Name()))
+			channel := ""
+			if dev, moniker, ok := p.Devices.InputLocation(t.TrackNum()); ok {
+				channel = dev + " " + moniker
+			}
+			plan.Entries = append(plan.Entries, &PlanEntry{
+				Src:        t.Src(),
+				Dest:       t.Dest(),
+				Session:    s.Num(),
+				Track:      t.TrackNum(),
+				Name:       t.Name(),
+				NameSource: t.NameSource(),
+				Channel:    channel,
+				Override:   applied[t],
+			})
+		}
+	}
+
+	if len(plan.Entries) == 0 {
+		return nil, fmt.Errorf("no tracks found")
+	}
+	if err := checkDests(plan.Entries); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// checkDests returns an error if two entries have the same destination. Names
+// differing only in case are the same on macOS and Windows.
+func checkDests(entries []*PlanEntry) error {
+	dests := map[string]*PlanEntry{}
+	for _, e := range entries {
+		dest := strings.ToLower(e.Dest)
+		if d, ok := dests[dest]; ok {
+			return fmt.Errorf("tracks %q and %q would both be named %q", d.Src, e.Src, e.Dest)
+		}
+		dests[dest] = e
+	}
+	return nil
+}
+
+// Filename returns the filename of a track, e.g. "01-02 Kick.wav".
+func (p *Planner) Filename(session, track int, name string) string {
+	ext := p.Ext
+	if ext == "" {
+		ext = ".wav"
+	}
+	return p.Policy.Filename(fmt.Sprintf("%02d-%02d ", session, track), name, ext, p.MaxLength)
+}
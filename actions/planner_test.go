@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kward/tracks/tracks"
+	"github.com/kward/tracks/venue"
+	"github.com/kward/tracks/venue/hardware"
+)
+
+func TestPlannerPlan(t *testing.T) {
+	devs := venue.Devices{
+		"Stage 1": venue.NewDevice(
+			hardware.StageBox,
+			"Stage 1",
+			venue.Channels{
+				"1": venue.NewChannel("1", "Kick"),
+				"2": venue.NewChannel("2", "")},
+			venue.Channels{},
+		),
+	}
+	p := NewPlanner(devs)
+	p.Fallbacks = []Fallback{FallbackOriginal}
+	p.Overrides = Overrides{
+		{Session: 2, Track: 1, Name: "Guest Kick"},
+		{Track: 9, Name: "Unused"},
+	}
+	plan, err := p.Plan([]string{"Track 01-1.wav", "Track 02-1.wav", "Track 01-2.wav"})
+	if err != nil {
+		t.Fatalf("Plan() unexpected error %s", err)
+	}
+
+	for i, want := range []PlanEntry{
+		{Src: "Track 01-1.wav", Dest: "01-01 Kick.wav", Session: 1, Track: 1, Name: "Kick", NameSource: tracks.NameFromChannel, Channel: "Stage 1 1"},
+		{Src: "Track 02-1.wav", Dest: "01-02 Track 02-1.wav", Session: 1, Track: 2, Name: "Track 02-1", NameSource: tracks.NameFromOriginal, Channel: "Stage 1 2"},
+		{Src: "Track 01-2.wav", Dest: "02-01 Guest Kick.wav", Session: 2, Track: 1, Name: "Guest Kick", NameSource: tracks.NameFromOverride, Channel: "Stage 1 1"},
+	} {
+		if i >= len(plan.Entries) {
+			t.Fatalf("Plan() returned %d entries, want 3", len(plan.Entries))
+		}
+		got := *plan.Entries[i]
+		if (got.Override != nil) != (want.NameSource == tracks.NameFromOverride) {
+			t.Errorf("Plan() entry %d override = %v", i, got.Override)
+		}
+		got.Override = nil
+		if got != want {
+			t.Errorf("Plan() entry %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if got, want := len(plan.Unused), 1; got != want {
+		t.Errorf("Plan() unused overrides = %v, want %d", plan.Unused, want)
+	}
+
+	if _, err := p.Plan([]string{}); err == nil {
+		t.Errorf("Plan() of no files expected error")
+	}
+
+	p.Ext = ".flac"
+	if got, want := p.Filename(1, 2, "Snare"), "01-02 Snare.flac"; got != want {
+		t.Errorf("Filename() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckDests(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		dests []string
+		ok    bool
+	}{
+		{"unique", []string{"01-01 Kick.wav", "01-02 Snare.wav"}, true},
+		{"same", []string{"01-01 Kick.wav", "01-01 Kick.wav"}, false},
+		{"case", []string{"01-01 Kick.wav", "01-01 KICK.wav"}, false},
+	} {
+		entries := []*PlanEntry{}
+		for i, dest := range tt.dests {
+			entries = append(entries, &PlanEntry{Src: fmt.Sprintf("Track 01-%d.wav", i+1), Dest: dest})
+		}
+		if err := checkDests(entries); (err == nil) != tt.ok {
+			t.Errorf("%s: checkDests() = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// ImageFormat is the file format of a rendered image.
+type ImageFormat int
+
+const (
+	PNGImage ImageFormat = iota
+	SVGImage
+)
+
+// ParseImageFormat returns the named image format.
+func ParseImageFormat(name string) (ImageFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "png":
+		return PNGImage, nil
+	case "svg":
+		return SVGImage, nil
+	}
+	return 0, fmt.Errorf("unsupported image format %q", name)
+}
+
+func (f ImageFormat) String() string {
+	if f == SVGImage {
+		return "svg"
+	}
+	return "png"
+}
+
+// Ext returns the file extension of the format.
+func (f ImageFormat) Ext() string { return "." + f.String() }
+
+//-----------------------------------------------------------------------------
+// Waveform
+
+const waveformBlockFrames = 4096
+
+// Peak is the range of the samples of a waveform column.
+type Peak struct {
+	Min, Max float32 // Within [-1, 1].
+	RMS      float32
+}
+
+// Waveform is the overview of a wave file, its samples reduced to a peak per
+// column.
+type Waveform struct {
+	File     string   `json:"file" yaml:"file"`
+	Label    string   `json:"label" yaml:"label"` // Defaults to the filename.
+	Duration Seconds  `json:"duration" yaml:"duration"`
+	Peak     float64  `json:"peak" yaml:"peak"` // Of all the samples, within [0, 1].
+	Columns  [][]Peak `json:"-" yaml:"-"`       // By channel, then column.
+}
+
+// ReadWaveform returns the waveform of a wave file, reduced to width columns.
+// The samples are read a block at a time.
+func ReadWaveform(file string, width int) (*Waveform, error) {
+	if width < 1 {
+		return nil, fmt.Errorf("invalid waveform width %d", width)
+	}
+	r, err := waveReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	channels, frames := r.ChannelCount(), r.FrameCount()
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count of %d", channels)
+	}
+	w := &Waveform{File: file, Duration: Seconds(r.Duration()), Columns: make([][]Peak, channels)}
+	for c := range w.Columns {
+		w.Columns[c] = make([]Peak, width)
+	}
+
+	// The peaks of the current column.
+	col, n := 0, 0
+	min, max, sum := make([]float32, channels), make([]float32, channels), make([]float64, channels)
+	flush := func() {
+		for c := range w.Columns {
+			p := Peak{Min: min[c], Max: max[c]}
+			if n > 0 {
+				p.RMS = float32(math.Sqrt(sum[c] / float64(n)))
+			}
+			w.Columns[c][col] = p
+			min[c], max[c], sum[c] = 0, 0, 0
+		}
+		n = 0
+	}
+
+	blk := make([]float32, waveformBlockFrames*channels)
+	for f := 0; f < frames; {
+		read, err := r.ReadBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+		if read /= channels; read == 0 {
+			break
+		}
+		for i := 0; i < read; i, f = i+1, f+1 {
+			if c := int(int64(f) * int64(width) / int64(frames)); c != col {
+				flush()
+				col = c
+			}
+			for c, v := range blk[i*channels : (i+1)*channels] {
+				if v < min[c] {
+					min[c] = v
+				}
+				if v > max[c] {
+					max[c] = v
+				}
+				sum[c] += float64(v) * float64(v)
+				if a := math.Abs(float64(v)); a > w.Peak {
+					w.Peak = a
+				}
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		flush()
+	}
+	return w, nil
+}
+
+// Silent returns true if every sample is zero.
+func (w *Waveform) Silent() bool { return w.Peak == 0 }
+
+// caption returns the label of the waveform, with its peak level.
+func (w *Waveform) caption() string {
+	label := w.Label
+	if label == "" {
+		label = filepath.Base(w.File)
+	}
+	if w.Silent() {
+		return label + " (silent)"
+	}
+	return fmt.Sprintf("%s (%.1f dBFS)", label, 20*math.Log10(w.Peak))
+}
+
+//-----------------------------------------------------------------------------
+// Rendering
+
+var (
+	waveformBackground = color.RGBA{0x20, 0x24, 0x28, 0xff}
+	waveformSeparator  = color.RGBA{0x10, 0x12, 0x14, 0xff}
+	waveformCenter     = color.RGBA{0x3a, 0x3f, 0x44, 0xff}
+	waveformPeak       = color.RGBA{0x3f, 0x8f, 0xd2, 0xff}
+	waveformRMS        = color.RGBA{0x8c, 0xc4, 0xf2, 0xff}
+	waveformSilent     = color.RGBA{0x6b, 0x6f, 0x73, 0xff}
+	waveformLabel      = color.RGBA{0xe8, 0xe8, 0xe8, 0xff}
+	waveformLabelBox   = color.RGBA{0x00, 0x00, 0x00, 0x99}
+)
+
+// RenderWaveforms draws the waveforms stacked, each labeled with its caption,
+// in an image width pixels wide and height pixels high per waveform. The
+// waveforms share a time scale, that of the longest.
+func RenderWaveforms(w io.Writer, f ImageFormat, ws []*Waveform, width, height int) error {
+	if len(ws) == 0 {
+		return fmt.Errorf("no waveforms to render")
+	}
+	if width < 1 || height < 1 {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if f == SVGImage {
+		return renderSVG(w, ws, width, height)
+	}
+	return renderPNG(w, ws, width, height)
+}
+
+// waveformSpans calls fn with the vertical spans of the peak and RMS of each
+// pixel column of each channel of a waveform drawn in the box, given with y
+// increasing downwards.
+func waveformSpans(wf *Waveform, x0, y0, width, height int, fn func(x int, peak, rms [2]float64)) {
+	channels := len(wf.Columns)
+	band := float64(height) / float64(channels)
+	for c, cols := range wf.Columns {
+		center := float64(y0) + band*(float64(c)+0.5)
+		half := band/2 - 1
+		for x := 0; x < width && len(cols) > 0; x++ {
+			p := cols[x*len(cols)/width]
+			peak := [2]float64{center - float64(p.Max)*half, center - float64(p.Min)*half}
+			rms := [2]float64{center - float64(p.RMS)*half, center + float64(p.RMS)*half}
+			rms[0], rms[1] = math.Max(rms[0], peak[0]), math.Min(rms[1], peak[1])
+			fn(x0+x, peak, rms)
+		}
+	}
+}
+
+// waveformWidth returns the width of the waveform, scaled to the longest.
+func waveformWidth(wf *Waveform, longest Seconds, width int) int {
+	if longest <= 0 {
+		return width
+	}
+	return int(math.Ceil(float64(width) * float64(wf.Duration) / float64(longest)))
+}
+
+func longestWaveform(ws []*Waveform) Seconds {
+	longest := Seconds(0)
+	for _, wf := range ws {
+		if wf.Duration > longest {
+			longest = wf.Duration
+		}
+	}
+	return longest
+}
+
+func renderPNG(w io.Writer, ws []*Waveform, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height*len(ws)))
+	fill(img, img.Bounds(), waveformBackground)
+	longest := longestWaveform(ws)
+	for i, wf := range ws {
+		y0 := i * height
+		for c := range wf.Columns {
+			band := height / len(wf.Columns)
+			fill(img, image.Rect(0, y0+band*c+band/2, width, y0+band*c+band/2+1), waveformCenter)
+		}
+		peak, rms := waveformPeak, waveformRMS
+		if wf.Silent() {
+			peak, rms = waveformSilent, waveformSilent
+		}
+		waveformSpans(wf, 0, y0, waveformWidth(wf, longest, width), height, func(x int, p, r [2]float64) {
+			fill(img, image.Rect(x, int(math.Floor(p[0])), x+1, int(math.Ceil(p[1]))+1), peak)
+			fill(img, image.Rect(x, int(math.Floor(r[0])), x+1, int(math.Ceil(r[1]))), rms)
+		})
+		if i > 0 {
+			fill(img, image.Rect(0, y0, width, y0+1), waveformSeparator)
+		}
+		drawLabel(img, 4, y0+3, wf.caption())
+	}
+	bw := bufio.NewWriter(w)
+	if err := png.Encode(bw, img); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// fill fills the rectangle, clipped to the image, blending the color over.
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c.A == 0xff {
+				img.SetRGBA(x, y, c)
+				continue
+			}
+			// Blend the color over, as premultiplied by alpha.
+			d := img.RGBAAt(x, y)
+			blend := func(s, d uint8) uint8 { return s + uint8(uint16(d)*uint16(0xff-c.A)/0xff) }
+			img.SetRGBA(x, y, color.RGBA{blend(c.R, d.R), blend(c.G, d.G), blend(c.B, d.B), 0xff})
+		}
+	}
+}
+
+// drawLabel draws the label, over a box, with its top left corner at x, y.
+// Characters beyond ASCII are transliterated.
+func drawLabel(img *image.RGBA, x, y int, label string) {
+	text := []rune(transliterate(label))
+	fill(img, image.Rect(x, y, x+len(text)*glyphAdvance+3, y+glyphHeight+4), waveformLabelBox)
+	for i, r := range text {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		gx := x + 2 + i*glyphAdvance
+		for col, bits := range glyph {
+			for row := 0; row < glyphHeight; row++ {
+				if bits>>uint(row)&1 != 0 {
+					img.SetRGBA(gx+col, y+2+row, waveformLabel)
+				}
+			}
+		}
+	}
+}
+
+func renderSVG(w io.Writer, ws []*Waveform, width, height int) error {
+	bw := bufio.NewWriter(w)
+	hex := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height*len(ws), width, height*len(ws))
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(waveformBackground))
+	longest := longestWaveform(ws)
+	for i, wf := range ws {
+		y0 := i * height
+		fmt.Fprintf(bw, "<g>\n")
+		band := float64(height) / float64(len(wf.Columns))
+		for c := range wf.Columns {
+			y := float64(y0) + band*(float64(c)+0.5)
+			fmt.Fprintf(bw, `<line x1="0" y1="%.1f" x2="%d" y2="%.1f" stroke="%s"/>`+"\n", y, width, y, hex(waveformCenter))
+		}
+		peak, rms := waveformPeak, waveformRMS
+		if wf.Silent() {
+			peak, rms = waveformSilent, waveformSilent
+		}
+		// The outline of each channel, along the top and back along the bottom.
+		var tops, bottoms, rtops, rbottoms []string
+		outline := func(fill color.RGBA, tops, bottoms []string) {
+			if len(tops) == 0 {
+				return
+			}
+			for i, j := 0, len(bottoms)-1; i < j; i, j = i+1, j-1 {
+				bottoms[i], bottoms[j] = bottoms[j], bottoms[i]
+			}
+			fmt.Fprintf(bw, `<path fill="%s" d="M%s L%sZ"/>`+"\n", hex(fill), strings.Join(tops, " "), strings.Join(bottoms, " "))
+		}
+		lastX := -1
+		waveformSpans(wf, 0, y0, waveformWidth(wf, longest, width), height, func(x int, p, r [2]float64) {
+			if x < lastX { // The next channel.
+				outline(peak, tops, bottoms)
+				outline(rms, rtops, rbottoms)
+				tops, bottoms, rtops, rbottoms = nil, nil, nil, nil
+			}
+			lastX = x
+			tops = append(tops, fmt.Sprintf("%d,%.1f %d,%.1f", x, p[0], x+1, p[0]))
+			bottoms = append(bottoms, fmt.Sprintf("%d,%.1f %d,%.1f", x+1, p[1]+1, x, p[1]+1))
+			rtops = append(rtops, fmt.Sprintf("%d,%.1f %d,%.1f", x, r[0], x+1, r[0]))
+			rbottoms = append(rbottoms, fmt.Sprintf("%d,%.1f %d,%.1f", x+1, r[1], x, r[1]))
+		})
+		outline(peak, tops, bottoms)
+		outline(rms, rtops, rbottoms)
+		if i > 0 {
+			fmt.Fprintf(bw, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", y0, width, y0, hex(waveformSeparator))
+		}
+		text := &strings.Builder{}
+		xml.EscapeText(text, []byte(wf.caption()))
+		fmt.Fprintf(bw, `<text x="6" y="%d" font-family="sans-serif" font-size="11" fill="%s">%s</text>`+"\n",
+			y0+14, hex(waveformLabel), text)
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,152 @@
+package actions
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImageFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		ok     bool
+		format ImageFormat
+	}{
+		{"png", true, PNGImage},
+		{".SVG", true, SVGImage},
+		{"gif", false, 0},
+	} {
+		f, err := ParseImageFormat(tt.name)
+		if (err == nil) != tt.ok || f != tt.format {
+			t.Errorf("ParseImageFormat(%q) = %s, %v; want %s", tt.name, f, err, tt.format)
+		}
+	}
+}
+
+// testWaveforms writes a stereo wave file, silent then at half scale on the
+// left channel, and a silent mono one, and returns their waveforms.
+func testWaveforms(t *testing.T, dir string, width int) []*Waveform {
+	stereo := make([]float64, 2*4800)
+	for i := 2400; i < 4800; i++ {
+		stereo[2*i] = 0.5 * math.Copysign(1, math.Sin(float64(i)))
+	}
+	files := []string{filepath.Join(dir, "stereo.wav"), filepath.Join(dir, "silent.wav")}
+	writeTestWave(t, files[0], Int16, 48000, 2, stereo, 0)
+	writeTestWave(t, files[1], Int16, 48000, 1, make([]float64, 2400), 0)
+	ws := []*Waveform{}
+	for _, f := range files {
+		w, err := ReadWaveform(f, width)
+		if err != nil {
+			t.Fatalf("ReadWaveform() unexpected error %s", err)
+		}
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+func TestReadWaveform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ws := testWaveforms(t, dir, 10)
+
+	w := ws[0]
+	if got, want := len(w.Columns), 2; got != want {
+		t.Fatalf("ReadWaveform() channels = %d, want %d", got, want)
+	}
+	for col, p := range w.Columns[0] {
+		want := Peak{}
+		if col >= 5 {
+			want = Peak{Min: -0.5, Max: 0.5, RMS: 0.5}
+		}
+		if p != want {
+			t.Errorf("ReadWaveform() left column %d = %+v, want %+v", col, p, want)
+		}
+	}
+	for col, p := range w.Columns[1] {
+		if p != (Peak{}) {
+			t.Errorf("ReadWaveform() right column %d = %+v, want silence", col, p)
+		}
+	}
+	if got, want := w.Peak, 0.5; got != want {
+		t.Errorf("ReadWaveform() peak = %g, want %g", got, want)
+	}
+	if w.Silent() || !ws[1].Silent() {
+		t.Errorf("Silent() = %t, %t; want false, true", w.Silent(), ws[1].Silent())
+	}
+	if got, want := w.caption(), "stereo.wav (-6.0 dBFS)"; got != want {
+		t.Errorf("caption() = %q, want %q", got, want)
+	}
+
+	if _, err := ReadWaveform(filepath.Join(dir, "missing.wav"), 10); err == nil {
+		t.Errorf("ReadWaveform() of a missing file expected error")
+	}
+}
+
+func TestRenderWaveforms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ws := testWaveforms(t, dir, 200)
+	ws[0].Label = "Kick & Snare"
+
+	b := &bytes.Buffer{}
+	if err := RenderWaveforms(b, PNGImage, ws, 200, 40); err != nil {
+		t.Fatalf("RenderWaveforms() unexpected error %s", err)
+	}
+	img, err := png.Decode(b)
+	if err != nil {
+		t.Fatalf("png.Decode() unexpected error %s", err)
+	}
+	if got, want := img.Bounds().Size().X, 200; got != want {
+		t.Errorf("width = %d, want %d", got, want)
+	}
+	if got, want := img.Bounds().Size().Y, 80; got != want {
+		t.Errorf("height = %d, want %d", got, want)
+	}
+	// The left channel has signal in its second half only.
+	at := func(x, y int) [3]uint32 {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return [3]uint32{r >> 8, g >> 8, b >> 8}
+	}
+	rgb := func(c color.RGBA) [3]uint32 { return [3]uint32{uint32(c.R), uint32(c.G), uint32(c.B)} }
+	rms := rgb(waveformRMS)
+	if got := at(190, 10); got != rms {
+		t.Errorf("color of the signal = %v, want %v", got, rms)
+	}
+	if got, want := at(50, 17), rgb(waveformBackground); got != want {
+		t.Errorf("color of the silence = %v, want %v", got, want)
+	}
+	// The silent file is half as long, its waveform a flat line.
+	if got, want := at(50, 60), rgb(waveformSilent); got != want {
+		t.Errorf("color of the silent track = %v, want %v", got, want)
+	}
+	if got, want := at(150, 60), rgb(waveformCenter); got != want {
+		t.Errorf("color past the end = %v, want %v", got, want)
+	}
+
+	b.Reset()
+	if err := RenderWaveforms(b, SVGImage, ws, 200, 40); err != nil {
+		t.Fatalf("RenderWaveforms() unexpected error %s", err)
+	}
+	svg := b.String()
+	for _, want := range []string{`<svg `, `height="80"`, "Kick &amp; Snare (-6.0 dBFS)", "silent.wav (silent)", "<path "} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("RenderWaveforms() SVG lacks %q", want)
+		}
+	}
+
+	if err := RenderWaveforms(b, PNGImage, nil, 200, 40); err == nil {
+		t.Errorf("RenderWaveforms() of no waveforms expected error")
+	}
+}
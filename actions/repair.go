@@ -0,0 +1,281 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// WaveRepair describes the problems found with the headers of a wave file, as
+// left by a recorder that crashed or lost power before closing it.
+type WaveRepair struct {
+	File     string   `json:"file" yaml:"file"`
+	Problems []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+	Frames   int64    `json:"frames" yaml:"frames"` // Of the repaired file.
+	// Dropped is the number of bytes of a partial frame at the end of the data.
+	Dropped int64 `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+
+	h        *waveHeader
+	dataSize int64       // Of the repaired file.
+	trailing []riffChunk // The chunks following the data chunk.
+}
+
+// Broken returns true if problems were found.
+func (r *WaveRepair) Broken() bool { return len(r.Problems) > 0 }
+
+// String implements the fmt.Stringer interface.
+func (r *WaveRepair) String() string {
+	if !r.Broken() {
+		return fmt.Sprintf("%s: ok, %d frames", r.File, r.Frames)
+	}
+	return fmt.Sprintf("%s: %d frames; %s", r.File, r.Frames, strings.Join(r.Problems, "; "))
+}
+
+// DiagnoseWave checks the RIFF and data chunk sizes of a wave file against the
+// length of the file and its audio format. When the data chunk size is
+// missing, or too small or too large, the data is taken to run to the end of
+// the file, less a partial frame. A data chunk size is trusted only if the
+// bytes following the data are well formed chunks, e.g. a LIST chunk.
+func DiagnoseWave(file string) (*WaveRepair, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q; %s", file, err)
+	}
+	if h.channels == 0 || h.blockAlign == 0 || h.sampleRate == 0 {
+		return nil, fmt.Errorf("error reading %q; invalid format", file)
+	}
+	size, align := fi.Size(), int64(h.blockAlign)
+	r := &WaveRepair{File: file, h: h}
+
+	r.dataSize = h.dataSize
+	end := h.dataOffset + h.dataSize + h.dataSize%2
+	switch {
+	case h.dataSize == 0 || h.dataOffset+h.dataSize > size:
+		r.dataSize = size - h.dataOffset
+	case end < size:
+		if r.trailing, err = readChunks(f, end, size); err != nil {
+			r.dataSize = size - h.dataOffset // A stale size, followed by more audio.
+		}
+	}
+	r.Dropped = r.dataSize % align
+	r.dataSize -= r.Dropped
+	r.Frames = r.dataSize / align
+
+	if r.dataSize != h.dataSize {
+		r.Problems = append(r.Problems, fmt.Sprintf("data size %d, of %d bytes", h.dataSize, r.dataSize))
+	}
+	if r.Dropped > 0 {
+		r.Problems = append(r.Problems, fmt.Sprintf("partial frame of %d bytes", r.Dropped))
+	}
+	if h.riffSize+chunkHeaderSize != size {
+		r.Problems = append(r.Problems, fmt.Sprintf("RIFF size %d, of a %d byte file", h.riffSize, size))
+	}
+	for _, c := range h.chunks {
+		if c.id != "fact" || c.size < 4 || h.id != "RIFF" {
+			continue
+		}
+		b := make([]byte, 4)
+		if _, err := f.ReadAt(b, c.offset); err != nil {
+			return nil, fmt.Errorf("error reading %q; %s", file, err)
+		}
+		if frames := int64(binary.LittleEndian.Uint32(b)); frames != r.Frames {
+			r.Problems = append(r.Problems, fmt.Sprintf("fact frames %d, of %d frames", frames, r.Frames))
+		}
+	}
+	return r, nil
+}
+
+// readChunks reads the headers of the chunks between the offsets off and end.
+// It returns an error unless they are well formed, and fill the space.
+func readChunks(r io.ReaderAt, off, end int64) ([]riffChunk, error) {
+	chunks := []riffChunk{}
+	b := make([]byte, chunkHeaderSize)
+	for off < end {
+		if _, err := r.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		for _, c := range b[0:4] {
+			if c < ' ' || c > '~' {
+				return nil, fmt.Errorf("invalid chunk ID %q at %d", b[0:4], off)
+			}
+		}
+		size := int64(binary.LittleEndian.Uint32(b[4:8]))
+		next := off + chunkHeaderSize + size + size%2
+		if next > end && off+chunkHeaderSize+size != end {
+			return nil, fmt.Errorf("%q chunk at %d runs past the end", b[0:4], off)
+		}
+		chunks = append(chunks, riffChunk{id: string(b[0:4]), offset: off + chunkHeaderSize, size: size})
+		off = next
+	}
+	return chunks, nil
+}
+
+// Repair writes a copy of the diagnosed wave file to dest, with its sizes
+// recomputed. The chunks of the file are copied as they are, less any JUNK
+// padding, and the audio data byte for byte, less any partial frame. The copy
+// is written as RF64 if it is too large for RIFF, and verified as by
+// Convert().
+func (r *WaveRepair) Repair(dest string) error {
+	if r.dataSize == 0 {
+		return fmt.Errorf("error repairing %q; no audio data", r.File)
+	}
+	if err := writePartial(dest, r.repair); err != nil {
+		return fmt.Errorf("error repairing %q; %s", r.File, err)
+	}
+	return nil
+}
+
+func (r *WaveRepair) repair(file string) error {
+	in, err := os.Open(r.File)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	read := func(c riffChunk) ([]byte, error) {
+		data := make([]byte, c.size)
+		if _, err := in.ReadAt(data, c.offset); err != nil {
+			return nil, fmt.Errorf("error reading %s chunk; %s", c.id, err)
+		}
+		return data, nil
+	}
+
+	// The headers, less the sizes.
+	b := &bytes.Buffer{}
+	le := func(v interface{}) { binary.Write(b, binary.LittleEndian, v) }
+	chunk := func(id string, data []byte) {
+		b.WriteString(id)
+		le(uint32(len(data)))
+		b.Write(data)
+		if len(data)%2 == 1 {
+			b.WriteByte(0)
+		}
+	}
+	b.WriteString("RIFF")
+	le(uint32(0))
+	b.WriteString("WAVE")
+	b.WriteString("ds64")
+	le(uint32(ds64Size))
+	b.Write(make([]byte, ds64Size))
+	var factAt int64
+	for _, c := range r.h.chunks {
+		if c.id == "ds64" || c.id == "JUNK" {
+			continue // Replaced by the reserved chunk.
+		}
+		data, err := read(c)
+		if err != nil {
+			return err
+		}
+		if c.id == "fact" && len(data) >= 4 {
+			factAt = int64(b.Len()) + chunkHeaderSize
+		}
+		chunk(c.id, data)
+	}
+	b.WriteString("data")
+	dataSizeAt := int64(b.Len())
+	le(uint32(0))
+	header := append([]byte{}, b.Bytes()...)
+
+	trailer := &bytes.Buffer{}
+	if r.dataSize%2 == 1 {
+		trailer.WriteByte(0)
+	}
+	for _, c := range r.trailing {
+		data, err := read(c)
+		if err != nil {
+			return err
+		}
+		b.Reset()
+		chunk(c.id, data)
+		trailer.Write(b.Bytes())
+	}
+
+	// The sizes, as RIFF or RF64.
+	size := int64(len(header)) + r.dataSize + int64(trailer.Len())
+	put32 := func(at int64, v uint32) { binary.LittleEndian.PutUint32(header[at:], v) }
+	if size-chunkHeaderSize <= maxRIFFSize {
+		copy(header[riffHeaderSize:], "JUNK")
+		put32(4, uint32(size-chunkHeaderSize))
+		put32(dataSizeAt, uint32(r.dataSize))
+		if factAt > 0 {
+			put32(factAt, uint32(r.Frames))
+		}
+	} else {
+		copy(header[0:], "RF64")
+		put32(4, rf64Size)
+		ds64 := header[riffHeaderSize+chunkHeaderSize:]
+		binary.LittleEndian.PutUint64(ds64[0:], uint64(size-chunkHeaderSize))
+		binary.LittleEndian.PutUint64(ds64[8:], uint64(r.dataSize))
+		binary.LittleEndian.PutUint64(ds64[16:], uint64(r.Frames))
+		put32(dataSizeAt, rf64Size)
+		if factAt > 0 {
+			put32(factAt, rf64Size)
+		}
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(out, copyBlockSize)
+	sum := sha256.New()
+	_, err = w.Write(header)
+	if err == nil {
+		data := io.NewSectionReader(in, r.h.dataOffset, r.dataSize)
+		_, err = io.Copy(io.MultiWriter(w, sum), data)
+	}
+	if err == nil {
+		_, err = w.Write(trailer.Bytes())
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return verifyRepair(file, int64(len(header)), r.dataSize, size, sum.Sum(nil))
+}
+
+// verifyRepair reads back the repaired file, and verifies its headers, size
+// and data checksum.
+func verifyRepair(file string, dataOffset, dataSize, size int64, dataSum []byte) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return err
+	}
+	if !h.complete(size) || h.dataOffset != dataOffset || h.dataSize != dataSize {
+		return fmt.Errorf("headers differ after writing")
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, io.NewSectionReader(f, dataOffset, dataSize)); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum.Sum(nil), dataSum) {
+		return fmt.Errorf("checksum mismatch of the written data")
+	}
+	return nil
+}
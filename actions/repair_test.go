@@ -0,0 +1,129 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repair_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const frames = 1000
+	samples := make([]float64, 2*frames)
+	for i := range samples {
+		samples[i] = float64(i%512-256) / 512
+	}
+	good := filepath.Join(dir, "good.wav")
+	writeTestWave(t, good, Int24, 48000, 2, samples, 4800)
+	orig, err := ioutil.ReadFile(good)
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	h, err := readWaveHeader(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatalf("readWaveHeader() unexpected error %s", err)
+	}
+	list := append([]byte("LIST"), 4, 0, 0, 0, 'I', 'N', 'F', 'O')
+
+	// broken returns a copy of the file, with the RIFF and data sizes given,
+	// and the bytes appended.
+	broken := func(riffSize, dataSize uint32, more []byte) []byte {
+		b := append(append([]byte{}, orig...), more...)
+		binary.LittleEndian.PutUint32(b[4:], riffSize)
+		binary.LittleEndian.PutUint32(b[h.dataSizeAt:], dataSize)
+		return b
+	}
+	dataSize := uint32(h.dataSize)
+	riffSize := uint32(len(orig) - chunkHeaderSize)
+
+	for _, tt := range []struct {
+		desc     string
+		data     []byte
+		rf64     bool
+		problems int
+		dropped  int64
+		trailing []byte
+	}{
+		{"healthy", orig, false, 0, 0, nil},
+		{"zero sizes", broken(0, 0, nil), false, 2, 0, nil},
+		{"partial frame", broken(0, 0, []byte{1, 2, 3}), false, 3, 3, nil},
+		{"stale sizes", broken(riffSize-3000, dataSize-3000, nil), false, 2, 0, nil},
+		{"data size past the end", broken(riffSize, dataSize+6000, nil), false, 1, 0, nil},
+		{"trailing chunk", broken(riffSize, dataSize, list), false, 1, 0, list},
+		{"as RF64", broken(0, 0, nil), true, 2, 0, nil},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			src := filepath.Join(dir, "src.wav")
+			if err := ioutil.WriteFile(src, tt.data, 0644); err != nil {
+				t.Fatalf("unexpected error; %s", err)
+			}
+			r, err := DiagnoseWave(src)
+			if err != nil {
+				t.Fatalf("DiagnoseWave() unexpected error %s", err)
+			}
+			if got := len(r.Problems); got != tt.problems {
+				t.Errorf("DiagnoseWave() problems = %q, want %d", r.Problems, tt.problems)
+			}
+			if r.Broken() != (tt.problems > 0) {
+				t.Errorf("Broken() = %v, want %v", r.Broken(), tt.problems > 0)
+			}
+			if r.Frames != frames || r.Dropped != tt.dropped {
+				t.Errorf("DiagnoseWave() = %d frames, %d dropped; want %d, %d", r.Frames, r.Dropped, frames, tt.dropped)
+			}
+
+			if tt.rf64 {
+				defer func(size int64) { maxRIFFSize = size }(maxRIFFSize)
+				maxRIFFSize = 4000
+			}
+			dest := filepath.Join(dir, "repaired.wav")
+			if err := r.Repair(dest); err != nil {
+				t.Fatalf("Repair() unexpected error %s", err)
+			}
+			gotH, got, bext := readTestWave(t, dest)
+			if want := map[bool]string{false: "RIFF", true: "RF64"}[tt.rf64]; gotH.id != want {
+				t.Errorf("Repair() wrote a %s file, want %s", gotH.id, want)
+			}
+			if !reflect.DeepEqual(got, samples) {
+				t.Errorf("Repair() samples differ")
+			}
+			if _, _, want := readTestWave(t, good); !bytes.Equal(bext, want) {
+				t.Errorf("Repair() bext chunk differs")
+			}
+			b, err := ioutil.ReadFile(dest)
+			if err != nil {
+				t.Fatalf("unexpected error; %s", err)
+			}
+			if trailing := b[gotH.dataOffset+gotH.dataSize:]; !bytes.Equal(trailing, tt.trailing) {
+				t.Errorf("Repair() trailing chunks = %q, want %q", trailing, tt.trailing)
+			}
+			if r, err := DiagnoseWave(dest); err != nil || r.Broken() {
+				t.Errorf("DiagnoseWave() of the repaired file = %v, %v", r, err)
+			}
+		})
+	}
+
+	// Without audio, there is nothing to repair.
+	empty := filepath.Join(dir, "empty.wav")
+	if err := ioutil.WriteFile(empty, broken(0, 0, nil)[:h.dataOffset], 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	r, err := DiagnoseWave(empty)
+	if err != nil {
+		t.Fatalf("DiagnoseWave() unexpected error %s", err)
+	}
+	if err := r.Repair(filepath.Join(dir, "repaired.wav")); err == nil {
+		t.Errorf("Repair() of a file without audio expected error")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+partialSuffix)); len(files) > 0 {
+		t.Errorf("Repair() left %q behind", files)
+	}
+}
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kward/tracks/tracks"
+)
+
+// Seconds is a duration, encoded as a number of seconds in JSON and YAML.
+type Seconds time.Duration
+
+// String implements the fmt.Stringer interface.
+func (s Seconds) String() string { return time.Duration(s).String() }
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s Seconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(s).Seconds())
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (s Seconds) MarshalYAML() (interface{}, error) {
+	return time.Duration(s).Seconds(), nil
+}
+
+// Operation describes the file operation on a single track, as performed.
+type Operation struct {
+	Src        string            `json:"src" yaml:"src"`
+	Dest       string            `json:"dest" yaml:"dest"`
+	Session    int               `json:"session" yaml:"session"`
+	Track      int               `json:"track" yaml:"track"`
+	Name       string            `json:"name" yaml:"name"`
+	NameSource tracks.NameSource `json:"name_source" yaml:"name_source"`
+	Channel    string            `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Override   string            `json:"override,omitempty" yaml:"override,omitempty"` // The override that named the track.
+	LinkMode   string            `json:"link_mode,omitempty" yaml:"link_mode,omitempty"`
+	Status     OperationStatus   `json:"status" yaml:"status"`
+	Error      string            `json:"error,omitempty" yaml:"error,omitempty"`
+	// Copies holds the copy to each destination of a Copier, once performed.
+	Copies []*Operation `json:"copies,omitempty" yaml:"copies,omitempty"`
+}
+
+// OperationStatus is the outcome of an Operation.
+type OperationStatus string
+
+const (
+	OperationDone    OperationStatus = "done"
+	OperationSkipped OperationStatus = "skipped" // E.g. already copied.
+	OperationDryRun  OperationStatus = "dry run"
+	OperationFailed  OperationStatus = "failed"
+)
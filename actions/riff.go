@@ -0,0 +1,175 @@
+package actions
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	riffHeaderSize  = 12 // "RIFF", size, "WAVE".
+	chunkHeaderSize = 8  // ID, size.
+	// rf64Size marks a RIFF or data chunk size as stored in the ds64 chunk.
+	rf64Size = 0xffffffff
+	// ds64Size is the size of a ds64 chunk without a table of chunk sizes.
+	ds64Size = 28
+
+	waveFormatPCM        = 1
+	waveFormatFloat      = 3
+	waveFormatExtensible = 0xfffe
+)
+
+// maxRIFFSize is the greatest RIFF size of a RIFF wave file, beyond which
+// files are written as RF64.
+var maxRIFFSize int64 = math.MaxUint32
+
+// waveHeader describes the structure of a RIFF, RF64 or BW64 wave file, as
+// found in its headers. Sizes are as recorded in the file, and may be wrong if the
+// file was not closed properly.
+type waveHeader struct {
+	id            string // "RIFF", "RF64" or "BW64".
+	riffSize      int64  // Size of the file, less the first 8 bytes.
+	format        uint16 // 1 = PCM, 3 = IEEE float, 0xfffe = extensible.
+	subFormat     uint16 // Of the extensible format, e.g. 1 or 3.
+	channels      uint16
+	sampleRate    uint32
+	bitsPerSample uint16
+	blockAlign    uint16
+	dataOffset    int64 // Offset of the data chunk payload.
+	dataSize      int64
+	dataSizeAt    int64       // Offset of the 32-bit data chunk size field.
+	ds64At        int64       // Offset of the ds64 chunk payload, or 0 if none.
+	chunks        []riffChunk // The chunks preceding the data chunk.
+}
+
+// riffChunk locates a chunk within a RIFF file.
+type riffChunk struct {
+	id     string
+	offset int64 // Of the payload.
+	size   int64
+	data   []byte // The payload, once read.
+}
+
+// readWaveHeader reads the headers of a wave file, stopping at the start of
+// the data chunk.
+func readWaveHeader(r io.ReaderAt) (*waveHeader, error) {
+	buf := make([]byte, riffHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("error reading RIFF header; %s", err)
+	}
+	h := &waveHeader{id: string(buf[0:4])}
+	if h.id != "RIFF" && h.id != "RF64" && h.id != "BW64" {
+		return nil, fmt.Errorf("unsupported file type %q", h.id)
+	}
+	if string(buf[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("unsupported RIFF type %q", buf[8:12])
+	}
+	h.riffSize = int64(binary.LittleEndian.Uint32(buf[4:8]))
+
+	var ds64DataSize int64 = -1
+	off := int64(riffHeaderSize)
+	for {
+		chunk := make([]byte, chunkHeaderSize)
+		if _, err := r.ReadAt(chunk, off); err != nil {
+			return nil, fmt.Errorf("data chunk not found; %s", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		payload := off + chunkHeaderSize
+
+		switch id {
+		case "ds64":
+			b := make([]byte, 16)
+			if _, err := r.ReadAt(b, payload); err != nil {
+				return nil, fmt.Errorf("error reading ds64 chunk; %s", err)
+			}
+			h.ds64At = payload
+			if h.id != "RIFF" {
+				h.riffSize = int64(binary.LittleEndian.Uint64(b[0:8]))
+			}
+			ds64DataSize = int64(binary.LittleEndian.Uint64(b[8:16]))
+		case "fmt ":
+			b := make([]byte, 16)
+			if _, err := r.ReadAt(b, payload); err != nil {
+				return nil, fmt.Errorf("error reading fmt chunk; %s", err)
+			}
+			h.format = binary.LittleEndian.Uint16(b[0:2])
+			h.channels = binary.LittleEndian.Uint16(b[2:4])
+			h.sampleRate = binary.LittleEndian.Uint32(b[4:8])
+			h.blockAlign = binary.LittleEndian.Uint16(b[12:14])
+			h.bitsPerSample = binary.LittleEndian.Uint16(b[14:16])
+			if h.format == waveFormatExtensible && size >= 26 {
+				if _, err := r.ReadAt(b[:2], payload+24); err != nil {
+					return nil, fmt.Errorf("error reading fmt chunk; %s", err)
+				}
+				h.subFormat = binary.LittleEndian.Uint16(b[0:2])
+			}
+		case "data":
+			if h.blockAlign == 0 {
+				return nil, fmt.Errorf("data chunk found before fmt chunk")
+			}
+			h.dataOffset = payload
+			h.dataSizeAt = off + 4
+			h.dataSize = size
+			if h.id != "RIFF" && size == rf64Size && ds64DataSize >= 0 {
+				h.dataSize = ds64DataSize
+			}
+			return h, nil
+		}
+		h.chunks = append(h.chunks, riffChunk{id: id, offset: payload, size: size})
+		off = payload + size + size%2 // Chunks are padded to an even size.
+	}
+}
+
+// sampleFormat returns the format of the samples, resolving the extensible
+// format to its sub format.
+func (h *waveHeader) sampleFormat() uint16 {
+	if h.format == waveFormatExtensible {
+		return h.subFormat
+	}
+	return h.format
+}
+
+// dataFrames returns the number of frames of the data chunk. The data size is
+// wrong in files that were not closed properly, so the data is then taken to
+// run to the end of the file.
+func (h *waveHeader) dataFrames(fileSize int64) int64 {
+	size := h.dataSize
+	if size == 0 || h.dataOffset+size > fileSize {
+		size = fileSize - h.dataOffset
+	}
+	return size / int64(h.blockAlign)
+}
+
+// complete returns true if the header sizes are consistent with the file
+// size, as they are once the recorder has closed the file.
+func (h *waveHeader) complete(fileSize int64) bool {
+	if h.riffSize+chunkHeaderSize != fileSize {
+		return false
+	}
+	if h.dataSize == 0 || h.dataSize%int64(h.blockAlign) != 0 {
+		return false
+	}
+	return h.dataOffset+h.dataSize <= fileSize
+}
+
+// WaveComplete returns true if the wave file headers are complete, i.e. the
+// file was closed properly, and is not still being written.
+func WaveComplete(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return false, err
+	}
+	return h.complete(fi.Size()), nil
+}
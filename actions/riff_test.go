@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kward/tracks/actions/wavetest"
+)
+
+func TestReadWaveHeader(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		data     []byte
+		ok       bool
+		complete bool
+	}{
+		{"riff", wavetest.Silence(1, 100).Bytes(), true, true},
+		{"rf64", wavetest.Wave{Channels: 2, Samples: make([]float64, 200), RF64: true}.Bytes(), true, true},
+		{"truncated", wavetest.Silence(1, 100).Bytes()[:100], true, false},
+		{"recording", wavetest.Wave{Channels: 1, Samples: make([]float64, 100), Recording: true}.Bytes(), true, false},
+		{"not riff", []byte("RIFX\x00\x00\x00\x00WAVE"), false, false},
+		{"not wave", []byte("RIFF\x00\x00\x00\x00AVI "), false, false},
+		{"no data", wavetest.Silence(1, 0).Bytes()[:36], false, false},
+	} {
+		h, err := readWaveHeader(bytes.NewReader(tt.data))
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: readWaveHeader() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if got, want := h.complete(int64(len(tt.data))), tt.complete; got != want {
+			t.Errorf("%s: complete() = %v, want %v", tt.desc, got, want)
+		}
+		if got, want := h.blockAlign, uint16(h.channels*2); got != want {
+			t.Errorf("%s: blockAlign = %d, want %d", tt.desc, got, want)
+		}
+	}
+}
+
+func TestWaveComplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "riff_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "Track 01-1.wav")
+	if err := ioutil.WriteFile(file, wavetest.Silence(1, 480).Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if ok, err := WaveComplete(file); err != nil || !ok {
+		t.Errorf("WaveComplete() = %v, %v; want true, nil", ok, err)
+	}
+	if _, err := WaveComplete(filepath.Join(dir, "missing.wav")); err == nil {
+		t.Errorf("WaveComplete() expected error for missing file")
+	}
+}
+
+func TestWaveInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "riff_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		desc string
+		data []byte
+	}{
+		{"riff", wavetest.Silence(2, 480).Bytes()},
+		{"rf64", wavetest.Wave{Channels: 2, Samples: make([]float64, 960), RF64: true}.Bytes()},
+		{"bw64", append([]byte("BW64"), wavetest.Wave{Channels: 2, Samples: make([]float64, 960), RF64: true}.Bytes()[4:]...)},
+	} {
+		file := filepath.Join(dir, tt.desc+".wav")
+		if err := ioutil.WriteFile(file, tt.data, 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		p, err := WaveInfo(file)
+		if err != nil {
+			t.Errorf("%s: WaveInfo() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if p.Channels != 2 || p.Frames != 480 || p.Duration != Seconds(10*time.Millisecond) {
+			t.Errorf("%s: WaveInfo() = %s", tt.desc, p)
+		}
+		_, n, err := WaveDump(file, 5*time.Millisecond, 10*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: WaveDump() unexpected error %s", tt.desc, err)
+		} else if n != 480 {
+			t.Errorf("%s: WaveDump() read %d samples, want 480", tt.desc, n)
+		}
+	}
+}
+
+func TestWaveReadBlockError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "riff_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "track.wav")
+	if err := ioutil.WriteFile(file, wavetest.Silence(2, 480).Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	r, err := waveReader(file)
+	if err != nil {
+		t.Fatalf("waveReader() unexpected error %s", err)
+	}
+	blk := make([]float32, 96)
+	if n, err := r.ReadBlock(blk); err != nil || n != len(blk) {
+		t.Errorf("ReadBlock() = %d, %v; want %d, nil", n, err, len(blk))
+	}
+	r.Close()
+	if _, err := r.ReadBlock(blk); err == nil {
+		t.Errorf("ReadBlock() of a closed file expected error")
+	}
+}
@@ -0,0 +1,369 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ScriptShell is the shell a rename script is written for.
+type ScriptShell int
+
+const (
+	// POSIXShell is a POSIX sh script, for macOS, Linux and the like.
+	POSIXShell ScriptShell = iota
+	// PowerShell is a Windows PowerShell script.
+	PowerShell
+	// BatchShell is a Windows cmd.exe batch file.
+	BatchShell
+)
+
+var scriptShells = map[ScriptShell][]string{
+	POSIXShell: {"sh", "posix"},
+	PowerShell: {"powershell", "ps1"},
+	BatchShell: {"batch", "bat", "cmd"},
+}
+
+// ParseScriptShell returns the named shell.
+func ParseScriptShell(name string) (ScriptShell, error) {
+	for s, names := range scriptShells {
+		for _, n := range names {
+			if strings.ToLower(name) == n {
+				return s, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown script shell %q", name)
+}
+
+func (s ScriptShell) String() string { return scriptShells[s][0] }
+
+// Ext returns the file extension of scripts for the shell.
+func (s ScriptShell) Ext() string {
+	switch s {
+	case PowerShell:
+		return ".ps1"
+	case BatchShell:
+		return ".bat"
+	}
+	return ".sh"
+}
+
+// ScriptAction is the file operation of a script.
+type ScriptAction int
+
+const (
+	ScriptMove ScriptAction = iota
+	ScriptCopy
+	ScriptLink // A hard link.
+)
+
+var scriptActions = map[ScriptAction]string{
+	ScriptMove: "move",
+	ScriptCopy: "copy",
+	ScriptLink: "link",
+}
+
+// ParseScriptAction returns the named action.
+func ParseScriptAction(name string) (ScriptAction, error) {
+	for a, n := range scriptActions {
+		if strings.ToLower(name) == n {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown script action %q", name)
+}
+
+func (a ScriptAction) String() string { return scriptActions[a] }
+
+// Rename is a single file of a script, from its original to its new name.
+type Rename struct {
+	Src, Dest string
+}
+
+// Script is a rename script, to run where tracks itself can't, e.g. on a studio
+// computer. The script first checks that every source file exists and that no
+// destination file does, and only then acts. The undo script reverts it.
+type Script struct {
+	Shell           ScriptShell
+	Action          ScriptAction
+	SrcDir, DestDir string
+	Renames         []Rename
+}
+
+// Check the script for names that collide, including names that differ only in
+// case, as most Mac and Windows filesystems ignore case. Names that the shell
+// can't quote are errors too.
+func (s *Script) Check() error {
+	dests := map[string]string{}
+	srcs := map[string]bool{}
+	sameDir := s.srcDir() == s.destDir()
+	for _, r := range s.Renames {
+		srcs[strings.ToLower(r.Src)] = true
+	}
+	for _, r := range s.Renames {
+		for _, n := range []string{r.Src, r.Dest} {
+			if err := s.Shell.check(n); err != nil {
+				return err
+			}
+		}
+		key := strings.ToLower(r.Dest)
+		if src, ok := dests[key]; ok {
+			return fmt.Errorf("%q and %q both become %q", src, r.Src, r.Dest)
+		}
+		dests[key] = r.Src
+		if sameDir && srcs[key] && !strings.EqualFold(r.Src, r.Dest) {
+			return fmt.Errorf("%q would replace the original file %q", r.Src, r.Dest)
+		}
+	}
+	for _, d := range []string{s.SrcDir, s.DestDir} {
+		if err := s.Shell.check(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the script to w.
+func (s *Script) Write(w io.Writer) error {
+	return s.write(w, false)
+}
+
+// WriteUndo writes the script reverting the script to w. Moves are moved back,
+// and copies and links are removed.
+func (s *Script) WriteUndo(w io.Writer) error {
+	return s.write(w, true)
+}
+
+func (s *Script) write(w io.Writer, undo bool) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	var g scriptGen
+	switch s.Shell {
+	case PowerShell:
+		g = &powerShellGen{}
+	case BatchShell:
+		g = &batchGen{}
+	default:
+		g = &posixGen{}
+	}
+
+	desc := fmt.Sprintf("%s %d files", s.Action, len(s.Renames))
+	if undo {
+		desc = "undo the " + desc
+	}
+	g.begin(desc)
+	// Check all files before acting on any.
+	for _, r := range s.Renames {
+		src, dest := s.join(s.srcDir(), r.Src), s.join(s.destDir(), r.Dest)
+		if undo {
+			g.checkExists(dest)
+			if s.Action == ScriptMove {
+				g.checkMissing(src)
+			}
+			continue
+		}
+		g.checkExists(src)
+		g.checkMissing(dest)
+	}
+	g.exitIfFailed()
+	if !undo && s.destDir() != s.srcDir() {
+		g.mkdir(s.destDir())
+	}
+	for _, r := range s.Renames {
+		src, dest := s.join(s.srcDir(), r.Src), s.join(s.destDir(), r.Dest)
+		switch {
+		case undo && s.Action == ScriptMove:
+			g.move(dest, src)
+		case undo:
+			g.remove(dest)
+		case s.Action == ScriptCopy:
+			g.copy(src, dest)
+		case s.Action == ScriptLink:
+			g.link(src, dest)
+		default:
+			g.move(src, dest)
+		}
+	}
+
+	text := g.String()
+	if s.Shell != POSIXShell {
+		text = strings.Replace(text, "\n", "\r\n", -1)
+	}
+	if s.Shell == PowerShell {
+		// Windows PowerShell reads scripts without a byte order mark as ANSI.
+		text = "\ufeff" + text
+	}
+	_, err := io.WriteString(w, text)
+	return err
+}
+
+func (s *Script) srcDir() string {
+	if s.SrcDir == "" {
+		return "."
+	}
+	return s.SrcDir
+}
+
+func (s *Script) destDir() string {
+	if s.DestDir == "" {
+		return s.srcDir()
+	}
+	return s.DestDir
+}
+
+// join the directory and file name with the separator of the shell.
+func (s *Script) join(dir, file string) string {
+	sep := "/"
+	if s.Shell != POSIXShell {
+		sep = `\`
+		dir = strings.Replace(dir, "/", sep, -1)
+	}
+	if strings.HasSuffix(dir, sep) {
+		return dir + file
+	}
+	return dir + sep + file
+}
+
+// check returns an error if the name can't be quoted by the shell.
+func (s ScriptShell) check(name string) error {
+	if s != BatchShell {
+		return nil
+	}
+	if strings.ContainsAny(name, "\"\r\n") {
+		return fmt.Errorf("%q can't be quoted in a batch file", name)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Script generators.
+
+type scriptGen interface {
+	begin(desc string)
+	checkExists(file string)
+	checkMissing(file string)
+	exitIfFailed()
+	mkdir(dir string)
+	move(src, dest string)
+	copy(src, dest string)
+	link(src, dest string)
+	remove(file string)
+	String() string
+}
+
+// posixGen generates POSIX sh scripts. Names are single quoted, which quotes
+// everything but single quotes themselves.
+type posixGen struct{ strings.Builder }
+
+func posixQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func (g *posixGen) begin(desc string) {
+	fmt.Fprintf(g, "#!/bin/sh\n# Generated by tracks to %s.\nfailed=0\n", desc)
+}
+func (g *posixGen) checkExists(file string) {
+	fmt.Fprintf(g, "[ -e %[1]s ] || { printf 'missing: %%s\\n' %[1]s >&2; failed=1; }\n", posixQuote(file))
+}
+func (g *posixGen) checkMissing(file string) {
+	fmt.Fprintf(g, "if [ -e %[1]s ] || [ -L %[1]s ]; then printf 'exists: %%s\\n' %[1]s >&2; failed=1; fi\n", posixQuote(file))
+}
+func (g *posixGen) exitIfFailed() { g.WriteString("[ \"$failed\" -eq 0 ] || exit 1\n") }
+func (g *posixGen) mkdir(dir string) {
+	fmt.Fprintf(g, "mkdir -p -- %s || exit 1\n", posixQuote(dir))
+}
+func (g *posixGen) move(src, dest string) {
+	fmt.Fprintf(g, "mv -- %s %s || exit 1\n", posixQuote(src), posixQuote(dest))
+}
+func (g *posixGen) copy(src, dest string) {
+	fmt.Fprintf(g, "cp -p -- %s %s || exit 1\n", posixQuote(src), posixQuote(dest))
+}
+func (g *posixGen) link(src, dest string) {
+	fmt.Fprintf(g, "ln -- %s %s || exit 1\n", posixQuote(src), posixQuote(dest))
+}
+func (g *posixGen) remove(file string) {
+	fmt.Fprintf(g, "rm -f -- %s || exit 1\n", posixQuote(file))
+}
+
+// powerShellGen generates PowerShell scripts. Names are single quoted, and
+// -LiteralPath keeps wildcards, e.g. brackets, from being expanded.
+type powerShellGen struct{ strings.Builder }
+
+// powerShellQuote single quotes s. PowerShell also takes the typographic
+// quotes for single quotes, so these are doubled too.
+func powerShellQuote(s string) string {
+	var b strings.Builder
+	b.WriteRune('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteRune('\'')
+	return b.String()
+}
+
+func (g *powerShellGen) begin(desc string) {
+	fmt.Fprintf(g, "# Generated by tracks to %s.\n$ErrorActionPreference = 'Stop'\n$failed = $false\n", desc)
+}
+func (g *powerShellGen) checkExists(file string) {
+	fmt.Fprintf(g, "if (-not (Test-Path -LiteralPath %[1]s)) { Write-Warning ('missing: ' + %[1]s); $failed = $true }\n", powerShellQuote(file))
+}
+func (g *powerShellGen) checkMissing(file string) {
+	fmt.Fprintf(g, "if (Test-Path -LiteralPath %[1]s) { Write-Warning ('exists: ' + %[1]s); $failed = $true }\n", powerShellQuote(file))
+}
+func (g *powerShellGen) exitIfFailed() { g.WriteString("if ($failed) { exit 1 }\n") }
+func (g *powerShellGen) mkdir(dir string) {
+	fmt.Fprintf(g, "New-Item -ItemType Directory -Force -Path %s | Out-Null\n", powerShellQuote(dir))
+}
+func (g *powerShellGen) move(src, dest string) {
+	fmt.Fprintf(g, "Move-Item -LiteralPath %s -Destination %s\n", powerShellQuote(src), powerShellQuote(dest))
+}
+func (g *powerShellGen) copy(src, dest string) {
+	fmt.Fprintf(g, "Copy-Item -LiteralPath %s -Destination %s\n", powerShellQuote(src), powerShellQuote(dest))
+}
+func (g *powerShellGen) link(src, dest string) {
+	fmt.Fprintf(g, "New-Item -ItemType HardLink -Path %s -Target %s | Out-Null\n", powerShellQuote(dest), powerShellQuote(src))
+}
+func (g *powerShellGen) remove(file string) {
+	fmt.Fprintf(g, "Remove-Item -LiteralPath %s\n", powerShellQuote(file))
+}
+
+// batchGen generates cmd.exe batch files. Names are double quoted, which
+// protects everything but percent signs, which are doubled. Double quotes and
+// line breaks can't be quoted; see ScriptShell.check().
+type batchGen struct{ strings.Builder }
+
+func batchQuote(s string) string {
+	return `"` + strings.Replace(s, "%", "%%", -1) + `"`
+}
+
+func (g *batchGen) begin(desc string) {
+	fmt.Fprintf(g, "@echo off\nrem Generated by tracks to %s.\nsetlocal DisableDelayedExpansion\nchcp 65001 >nul\nset \"failed=\"\n", desc)
+}
+func (g *batchGen) checkExists(file string) {
+	fmt.Fprintf(g, "if not exist %[1]s (echo missing: %[1]s& set \"failed=1\")\n", batchQuote(file))
+}
+func (g *batchGen) checkMissing(file string) {
+	fmt.Fprintf(g, "if exist %[1]s (echo exists: %[1]s& set \"failed=1\")\n", batchQuote(file))
+}
+func (g *batchGen) exitIfFailed() { g.WriteString("if defined failed exit /b 1\n") }
+func (g *batchGen) mkdir(dir string) {
+	fmt.Fprintf(g, "if not exist %[1]s mkdir %[1]s || exit /b 1\n", batchQuote(dir))
+}
+func (g *batchGen) move(src, dest string) {
+	fmt.Fprintf(g, "move %s %s >nul || exit /b 1\n", batchQuote(src), batchQuote(dest))
+}
+func (g *batchGen) copy(src, dest string) {
+	fmt.Fprintf(g, "copy /b %s %s >nul || exit /b 1\n", batchQuote(src), batchQuote(dest))
+}
+func (g *batchGen) link(src, dest string) {
+	fmt.Fprintf(g, "mklink /h %s %s >nul || exit /b 1\n", batchQuote(dest), batchQuote(src))
+}
+func (g *batchGen) remove(file string) {
+	fmt.Fprintf(g, "del %s || exit /b 1\n", batchQuote(file))
+}
@@ -0,0 +1,151 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScriptQuote(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		quote func(string) string
+		s     string
+		want  string
+	}{
+		{"posix plain", posixQuote, "01-01 Kick.wav", `'01-01 Kick.wav'`},
+		{"posix specials", posixQuote, "$HOME `x` \"y\".wav", "'$HOME `x` \"y\".wav'"},
+		{"posix quote", posixQuote, "Ben's Gtr.wav", `'Ben'\''s Gtr.wav'`},
+		{"powershell quote", powerShellQuote, "Ben's Gtr.wav", `'Ben''s Gtr.wav'`},
+		{"powershell typographic", powerShellQuote, "Ben\u2019s $Gtr.wav", "'Ben\u2019\u2019s $Gtr.wav'"},
+		{"batch percent", batchQuote, "100% & more.wav", `"100%% & more.wav"`},
+	} {
+		if got := tt.quote(tt.s); got != tt.want {
+			t.Errorf("%s: quote(%q) = %s, want %s", tt.desc, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestScriptCheck(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		shell   ScriptShell
+		destDir string
+		renames []Rename
+		ok      bool
+	}{
+		{"ok", POSIXShell, "", []Rename{{"Track 01-1.wav", "01-01 Kick.wav"}, {"Track 02-1.wav", "01-02 Snare.wav"}}, true},
+		{"same dest", POSIXShell, "", []Rename{{"Track 01-1.wav", "01-01 Kick.wav"}, {"Track 02-1.wav", "01-01 kick.wav"}}, false},
+		{"replaces a source", POSIXShell, "", []Rename{{"Track 01-1.wav", "Track 02-1.wav"}, {"Track 02-1.wav", "01-02 Snare.wav"}}, false},
+		{"source in another dir", POSIXShell, "out", []Rename{{"Track 01-1.wav", "Track 02-1.wav"}, {"Track 02-1.wav", "01-02 Snare.wav"}}, true},
+		{"batch double quote", BatchShell, "", []Rename{{"Track 01-1.wav", `01-01 "Kick".wav`}}, false},
+		{"powershell double quote", PowerShell, "", []Rename{{"Track 01-1.wav", `01-01 "Kick".wav`}}, true},
+	} {
+		s := &Script{Shell: tt.shell, DestDir: tt.destDir, Renames: tt.renames}
+		if err := s.Check(); (err == nil) != tt.ok {
+			t.Errorf("%s: Check() unexpected error %v", tt.desc, err)
+		}
+	}
+}
+
+func TestScriptWrite(t *testing.T) {
+	s := &Script{Shell: BatchShell, SrcDir: "D:/Rec", Renames: []Rename{{"Track 01-1.wav", "01-01 100% Kick.wav"}}}
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatalf("Write() unexpected error %s", err)
+	}
+	for _, want := range []string{
+		"if not exist \"D:\\Rec\\Track 01-1.wav\" (echo missing:",
+		"move \"D:\\Rec\\Track 01-1.wav\" \"D:\\Rec\\01-01 100%% Kick.wav\" >nul || exit /b 1\r\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Write() = %q, want it to contain %q", buf.String(), want)
+		}
+	}
+}
+
+// TestScriptRun runs generated POSIX scripts, and their undo scripts.
+func TestScriptRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell")
+	}
+	renames := []Rename{
+		{"Track 01-1.wav", "01-01 Ben's \"Kick\" $HOME.wav"},
+		{"Track 02-1.wav", "01-02 -n `Snare`.wav"},
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		action ScriptAction
+		dest   string
+	}{
+		{"move in place", ScriptMove, ""},
+		{"copy", ScriptCopy, "out dir"},
+		{"link", ScriptLink, "out dir"},
+	} {
+		dir, err := ioutil.TempDir("", "script_test")
+		if err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		defer os.RemoveAll(dir)
+		src, dest := filepath.Join(dir, "src"), filepath.Join(dir, "src")
+		if tt.dest != "" {
+			dest = filepath.Join(dir, tt.dest)
+		}
+		if err := os.Mkdir(src, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+		for _, r := range renames {
+			if err := ioutil.WriteFile(filepath.Join(src, r.Src), []byte(r.Src), 0644); err != nil {
+				t.Fatalf("unexpected error; %s", err)
+			}
+		}
+		s := &Script{Shell: POSIXShell, Action: tt.action, SrcDir: src, DestDir: dest, Renames: renames}
+
+		for _, run := range []struct {
+			desc  string
+			write func(w *bytes.Buffer) error
+			ok    bool
+			src   bool // Whether the source files are expected.
+			dest  bool // Whether the destination files are expected.
+		}{
+			{"script", func(w *bytes.Buffer) error { return s.Write(w) }, true, tt.action != ScriptMove, true},
+			{"script again", func(w *bytes.Buffer) error { return s.Write(w) }, false, tt.action != ScriptMove, true},
+			{"undo", func(w *bytes.Buffer) error { return s.WriteUndo(w) }, true, true, false},
+		} {
+			var buf bytes.Buffer
+			if err := run.write(&buf); err != nil {
+				t.Fatalf("%s: %s: unexpected error %s", tt.desc, run.desc, err)
+			}
+			cmd := exec.Command("sh")
+			cmd.Stdin = &buf
+			out, err := cmd.CombinedOutput()
+			if (err == nil) != run.ok {
+				t.Errorf("%s: %s: sh error %v; %s", tt.desc, run.desc, err, out)
+				continue
+			}
+			for _, r := range renames {
+				for _, f := range []struct {
+					file string
+					want bool
+				}{
+					{filepath.Join(src, r.Src), run.src},
+					{filepath.Join(dest, r.Dest), run.dest},
+				} {
+					_, err := os.Stat(f.file)
+					if got := err == nil; got != f.want {
+						t.Errorf("%s: %s: %q exists = %v, want %v", tt.desc, run.desc, f.file, got, f.want)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ChannelCount returns the number of channels of the wave or FLAC file, as
+// found in its headers.
+func ChannelCount(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r, err := openSamples(f)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %q; %s", file, err)
+	}
+	return r.channels, nil
+}
+
+// Split writes each channel of the polyphonic wave or FLAC file src to the
+// mono wave file dests[c], sample for sample in the format of src. Channels
+// with an empty dest are skipped. The Broadcast Wave (bext) and metadata
+// chunks of a wave file are copied to each. Each file is verified as by
+// Convert().
+func Split(src string, dests []string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := openSamples(in)
+	if err != nil {
+		return fmt.Errorf("error reading %q; %s", src, err)
+	}
+	if len(dests) != r.channels {
+		return fmt.Errorf("%d files given for the %d channels of %q", len(dests), r.channels, src)
+	}
+	if !r.format.writable() {
+		return fmt.Errorf("unsupported sample format %s", r.format)
+	}
+	var chunks []riffChunk
+	if r.header != nil {
+		if chunks, err = convertedChunks(in, r.header, r.rate); err != nil {
+			return fmt.Errorf("error reading %q; %s", src, err)
+		}
+	}
+
+	ws := make([]*waveWriter, len(dests))
+	abort := func() {
+		for c, w := range ws {
+			if w != nil {
+				w.abort()
+				os.Remove(dests[c] + partialSuffix)
+			}
+		}
+	}
+	for c, dest := range dests {
+		if dest == "" {
+			continue
+		}
+		if ws[c], err = newWaveWriter(dest+partialSuffix, r.format, 1, r.rate, chunks); err != nil {
+			abort()
+			return err
+		}
+	}
+	if err := splitSamples(ws, r); err != nil {
+		abort()
+		return fmt.Errorf("error splitting %q; %s", src, err)
+	}
+	for c, w := range ws {
+		if w == nil {
+			continue
+		}
+		err := writePartial(dests[c], func(string) error {
+			_, _, err := closeVerified(w, nil)
+			return err
+		})
+		if err != nil {
+			abort()
+			return fmt.Errorf("error writing %q; %s", dests[c], err)
+		}
+	}
+	return nil
+}
+
+// splitSamples writes each channel read from r to its writer, if any.
+func splitSamples(ws []*waveWriter, r *sampleReader) error {
+	mono := make([][]float64, 1)
+	for {
+		samples, err := r.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for c, w := range ws {
+			if w == nil {
+				continue
+			}
+			mono[0] = samples[c]
+			if err := w.write(mono); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Interleave writes the channels of the wave or FLAC files srcs, in order, to
+// the polyphonic wave file dest, the reverse of Split(). The files must share
+// a sample rate and format. Files shorter than the longest are padded with
+// silence. The Broadcast Wave (bext) and metadata chunks of the first file
+// are copied to dest, which is verified as by Convert().
+func Interleave(srcs []string, dest string) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("no files to interleave")
+	}
+	rs := make([]*sampleReader, len(srcs))
+	channels := 0
+	for i, src := range srcs {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if rs[i], err = openSamples(in); err != nil {
+			return fmt.Errorf("error reading %q; %s", src, err)
+		}
+		if rs[i].rate != rs[0].rate || rs[i].format != rs[0].format {
+			return fmt.Errorf("%q is %s bits at %d Hz, unlike %q at %s bits and %d Hz",
+				src, rs[i].format, rs[i].rate, srcs[0], rs[0].format, rs[0].rate)
+		}
+		channels += rs[i].channels
+	}
+	if !rs[0].format.writable() {
+		return fmt.Errorf("unsupported sample format %s", rs[0].format)
+	}
+	var chunks []riffChunk
+	if h := rs[0].header; h != nil {
+		in, err := os.Open(srcs[0])
+		if err != nil {
+			return err
+		}
+		chunks, err = convertedChunks(in, h, rs[0].rate)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %q; %s", srcs[0], err)
+		}
+	}
+
+	err := writePartial(dest, func(partial string) error {
+		w, err := newWaveWriter(partial, rs[0].format, channels, rs[0].rate, chunks)
+		if err != nil {
+			return err
+		}
+		_, _, err = closeVerified(w, interleaveSamples(w, rs, channels))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error interleaving %q; %s", dest, err)
+	}
+	return nil
+}
+
+// interleaveSamples writes the channels read from rs to w, a block at a time.
+// The readers may return blocks of differing sizes.
+func interleaveSamples(w *waveWriter, rs []*sampleReader, channels int) error {
+	pending := make([][]float64, channels) // Samples read, by channel.
+	done := make([]bool, len(rs))
+	out := make([][]float64, channels)
+	for {
+		// Read at least a block of each file, until its end.
+		first := 0 // The first channel of the file.
+		for i, r := range rs {
+			for !done[i] && len(pending[first]) < convertBlockFrames {
+				samples, err := r.read()
+				if err == io.EOF {
+					done[i] = true
+					break
+				}
+				if err != nil {
+					return err
+				}
+				for c, s := range samples {
+					pending[first+c] = append(pending[first+c], s...)
+				}
+			}
+			first += r.channels
+		}
+
+		frames := 0
+		for _, p := range pending {
+			if len(p) > frames {
+				frames = len(p)
+			}
+		}
+		if frames == 0 {
+			return nil
+		}
+		if frames > convertBlockFrames {
+			frames = convertBlockFrames
+		}
+		for c, p := range pending {
+			n := len(p)
+			if n > frames {
+				n = frames
+			}
+			out[c] = append(out[c][:0], p[:n]...)
+			for len(out[c]) < frames {
+				out[c] = append(out[c], 0) // Past the end of a shorter file.
+			}
+			pending[c] = append(p[:0], p[n:]...)
+		}
+		if err := w.write(out); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitInterleave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "split_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Three channels, of more than a block of frames.
+	const frames = convertBlockFrames + 100
+	poly := make([]float64, 3*frames)
+	mono := make([][]float64, 3)
+	for f := 0; f < frames; f++ {
+		for c := range mono {
+			v := float64((f*(c+1))%4096-2048) / 4096
+			poly[3*f+c] = v
+			mono[c] = append(mono[c], v)
+		}
+	}
+	src := filepath.Join(dir, "poly.wav")
+	writeTestWave(t, src, Int24, 48000, 3, poly, 4800)
+	_, _, srcBext := readTestWave(t, src)
+
+	if got, err := ChannelCount(src); err != nil || got != 3 {
+		t.Errorf("ChannelCount() = %d, %v; want 3", got, err)
+	}
+
+	dests := []string{filepath.Join(dir, "Kick.wav"), "", filepath.Join(dir, "Bass.wav")}
+	if err := Split(src, dests); err != nil {
+		t.Fatalf("Split() unexpected error %s", err)
+	}
+	for _, c := range []int{0, 2} {
+		h, samples, bext := readTestWave(t, dests[c])
+		if h.channels != 1 || h.bitsPerSample != 24 || h.sampleRate != 48000 {
+			t.Errorf("Split() %s format = %d channels of %d bits at %d Hz", dests[c], h.channels, h.bitsPerSample, h.sampleRate)
+		}
+		if !reflect.DeepEqual(samples, mono[c]) {
+			t.Errorf("Split() %s samples differ", dests[c])
+		}
+		if !bytes.Equal(bext, srcBext) {
+			t.Errorf("Split() %s bext chunk differs", dests[c])
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+partialSuffix)); len(files) > 0 {
+		t.Errorf("Split() left %q behind", files)
+	}
+
+	// Interleave the split files, and a shorter one.
+	short := filepath.Join(dir, "short.wav")
+	writeTestWave(t, short, Int24, 48000, 1, mono[1][:100], 0)
+	dest := filepath.Join(dir, "interleaved.wav")
+	if err := Interleave([]string{dests[0], short, dests[2]}, dest); err != nil {
+		t.Fatalf("Interleave() unexpected error %s", err)
+	}
+	h, samples, bext := readTestWave(t, dest)
+	if h.channels != 3 {
+		t.Errorf("Interleave() channels = %d, want 3", h.channels)
+	}
+	want := append([]float64{}, poly...)
+	for f := 100; f < frames; f++ {
+		want[3*f+1] = 0
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Errorf("Interleave() samples differ")
+	}
+	if !bytes.Equal(bext, srcBext) {
+		t.Errorf("Interleave() bext chunk differs")
+	}
+
+	// Errors.
+	if err := Split(src, dests[:2]); err == nil {
+		t.Errorf("Split() of too few files expected error")
+	}
+	other := filepath.Join(dir, "other.wav")
+	writeTestWave(t, other, Int16, 48000, 1, mono[0], 0)
+	if err := Interleave([]string{dests[0], other}, dest); err == nil {
+		t.Errorf("Interleave() of differing formats expected error")
+	}
+	if err := Interleave(nil, dest); err == nil {
+		t.Errorf("Interleave() of no files expected error")
+	}
+}
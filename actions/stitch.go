@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// waveSegment describes a wave file, as a possible part of a recording split
+// across files.
+type waveSegment struct {
+	file    string
+	h       *waveHeader
+	frames  int64
+	timeRef uint64 // Of the bext chunk, in samples since midnight.
+	hasRef  bool
+}
+
+func readWaveSegment(file string) (*waveSegment, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h, err := readWaveHeader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q; %s", file, err)
+	}
+	s := &waveSegment{file: file, h: h, frames: h.dataFrames(fi.Size())}
+	for _, c := range h.chunks {
+		if c.id != "bext" || c.size < bextTimeReferenceAt+8 {
+			continue
+		}
+		b := make([]byte, 8)
+		if _, err := f.ReadAt(b, c.offset+bextTimeReferenceAt); err != nil {
+			return nil, fmt.Errorf("error reading %q; %s", file, err)
+		}
+		s.timeRef, s.hasRef = binary.LittleEndian.Uint64(b), true
+	}
+	return s, nil
+}
+
+// continues returns true if the segment carries on where the previous one
+// ends: of the same format, and starting at the time it ends.
+func (s *waveSegment) continues(prev *waveSegment) bool {
+	if !s.hasRef || !prev.hasRef {
+		return false
+	}
+	if s.h.sampleFormat() != prev.h.sampleFormat() || s.h.bitsPerSample != prev.h.bitsPerSample ||
+		s.h.channels != prev.h.channels || s.h.sampleRate != prev.h.sampleRate {
+		return false
+	}
+	return s.timeRef == prev.timeRef+uint64(prev.frames)
+}
+
+// Continuations groups the wave files, in the order given, into recordings.
+// Recorders split long recordings into continuation files, e.g. at the 4 GiB
+// RIFF limit. A file continues the previous one if it has the same format, and
+// its Broadcast Wave (bext) time reference is the sample after the end of the
+// previous one. Files without a time reference are a recording of their own.
+func Continuations(files []string) ([][]string, error) {
+	groups := [][]string{}
+	var prev *waveSegment
+	for _, file := range files {
+		s, err := readWaveSegment(file)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && s.continues(prev) {
+			groups[len(groups)-1] = append(groups[len(groups)-1], file)
+		} else {
+			groups = append(groups, []string{file})
+		}
+		prev = s
+	}
+	return groups, nil
+}
+
+// Stitch writes the samples of the wave files srcs, one after the other, to
+// the wave file dest, e.g. to join the continuation files of a recording. The
+// files must share a format. The Broadcast Wave (bext) and metadata chunks of
+// the first file are copied to dest, which is written as RF64 if it grows
+// beyond 4 GiB, and verified as by Convert().
+func Stitch(srcs []string, dest string) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("no files to stitch")
+	}
+	err := writePartial(dest, func(partial string) error {
+		w, err := stitchSamples(srcs, partial)
+		if w == nil {
+			return err
+		}
+		_, _, err = closeVerified(w, err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error stitching %q; %s", dest, err)
+	}
+	return nil
+}
+
+// stitchSamples writes the samples of srcs to file. It returns the writer of
+// file, if it was created, with any error.
+func stitchSamples(srcs []string, file string) (*waveWriter, error) {
+	var w *waveWriter
+	var first *sampleReader
+	for i, src := range srcs {
+		in, err := os.Open(src)
+		if err != nil {
+			return w, err
+		}
+		defer in.Close()
+		r, err := openSamples(in)
+		if err != nil {
+			return w, fmt.Errorf("error reading %q; %s", src, err)
+		}
+		if i == 0 {
+			if !r.format.writable() {
+				return w, fmt.Errorf("unsupported sample format %s", r.format)
+			}
+			var chunks []riffChunk
+			if r.header != nil {
+				if chunks, err = convertedChunks(in, r.header, r.rate); err != nil {
+					return w, fmt.Errorf("error reading %q; %s", src, err)
+				}
+			}
+			if w, err = newWaveWriter(file, r.format, r.channels, r.rate, chunks); err != nil {
+				return w, err
+			}
+			first = r
+		} else if r.format != first.format || r.channels != first.channels || r.rate != first.rate {
+			return w, fmt.Errorf("%q has %d channels of %s bits at %d Hz, unlike %q of %d channels of %s bits at %d Hz",
+				src, r.channels, r.format, r.rate, srcs[0], first.channels, first.format, first.rate)
+		}
+		for {
+			samples, err := r.read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return w, fmt.Errorf("error reading %q; %s", src, err)
+			}
+			if err := w.write(samples); err != nil {
+				return w, err
+			}
+		}
+	}
+	return w, nil
+}
@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStitch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stitch_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	samples := func(frames int, v float64) []float64 {
+		s := make([]float64, 2*frames)
+		for i := range s {
+			s[i] = v
+		}
+		return s
+	}
+	file := func(name string, f SampleFormat, s []float64, timeRef uint64) string {
+		file := filepath.Join(dir, name)
+		writeTestWave(t, file, f, 48000, 2, s, timeRef)
+		return file
+	}
+	a := file("REC001.WAV", Int24, samples(5000, 0.25), 48000)
+	b := file("REC001_1.WAV", Int24, samples(300, -0.5), 48000+5000)
+	c := file("REC002.WAV", Int24, samples(300, 0.5), 48000+5300)
+	d := file("REC003.WAV", Int16, samples(300, 0.5), 48000+5600)
+
+	groups, err := Continuations([]string{a, b, c, d})
+	if err != nil {
+		t.Fatalf("Continuations() unexpected error %s", err)
+	}
+	// The third file continues the second, but not the first recording.
+	if want := [][]string{{a, b, c}, {d}}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("Continuations() = %q, want %q", groups, want)
+	}
+	if groups, err = Continuations([]string{b, a}); err != nil || len(groups) != 2 {
+		t.Errorf("Continuations() of files out of order = %q, %v; want 2 recordings", groups, err)
+	}
+
+	// Stitched beyond the RIFF size limit, the file is written as RF64.
+	defer func(size int64) { maxRIFFSize = size }(maxRIFFSize)
+	maxRIFFSize = 16000
+	dest := filepath.Join(dir, "stitched.wav")
+	if err := Stitch([]string{a, b}, dest); err != nil {
+		t.Fatalf("Stitch() unexpected error %s", err)
+	}
+	h, got, bext := readTestWave(t, dest)
+	if h.id != "RF64" {
+		t.Errorf("Stitch() wrote a %s file, want RF64", h.id)
+	}
+	_, want, wantBext := readTestWave(t, a)
+	_, more, _ := readTestWave(t, b)
+	if want = append(want, more...); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stitch() samples differ")
+	}
+	if !bytes.Equal(bext, wantBext) {
+		t.Errorf("Stitch() bext chunk differs from the first file")
+	}
+	info, err := WaveInfo(dest)
+	if err != nil {
+		t.Fatalf("WaveInfo() unexpected error %s", err)
+	}
+	if info.Frames != 5300 || info.Channels != 2 || info.BitsPerSample != 24 {
+		t.Errorf("WaveInfo() = %s", info)
+	}
+
+	// Within the limit, a RIFF file.
+	if err := Stitch([]string{b, c}, dest); err != nil {
+		t.Fatalf("Stitch() unexpected error %s", err)
+	}
+	if h, _, _ := readTestWave(t, dest); h.id != "RIFF" {
+		t.Errorf("Stitch() wrote a %s file, want RIFF", h.id)
+	}
+
+	if err := Stitch([]string{c, d}, dest); err == nil {
+		t.Errorf("Stitch() of differing formats expected error")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+partialSuffix)); len(files) > 0 {
+		t.Errorf("Stitch() left %q behind", files)
+	}
+}
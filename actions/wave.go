@@ -2,32 +2,46 @@ package actions
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
 	"time"
-
-	"github.com/kward/goaudio/codec/wav"
 )
 
 const silenceFrames = 10
 
-func WaveCheck(file string) error {
+// Silence is a region of silence within a wave file.
+type Silence struct {
+	Start Seconds `json:"start" yaml:"start"`
+	End   Seconds `json:"end" yaml:"end"`
+}
+
+// String implements the fmt.Stringer interface.
+func (s Silence) String() string { return fmt.Sprintf("%s - %s", s.Start, s.End) }
+
+// WaveCheck returns the regions of silence in a wave file.
+func WaveCheck(file string) ([]Silence, error) {
 	r, err := waveReader(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer r.Close()
 
+	silences := []Silence{}
 	cap := int(1 * r.SampleRate() * r.ChannelCount()) // 1 sec of data
 	blk := make([]float32, cap, cap)
 	start := 0 * time.Second
 	o := 0
 	for ; o < r.FrameCount(); o += cap {
-		frames := r.ReadBlock(blk)
+		frames, err := r.ReadBlock(blk)
+		if err != nil {
+			return nil, err
+		}
 		zeros := 0
 		for f := 0; f < frames; f++ {
 			if blk[f] != 0 {
 				zeros = 0
 				if start > 0 {
-					fmt.Printf("%s - %s\n", start, time.Duration(o/cap)*time.Second)
+					silences = append(silences, Silence{Seconds(start), Seconds(time.Duration(o/cap) * time.Second)})
 					start = 0 * time.Second
 				}
 				continue
@@ -40,10 +54,10 @@ func WaveCheck(file string) error {
 		}
 	}
 	if start > 0 {
-		fmt.Printf("%s - %s\n", start, time.Duration(o)*time.Second)
+		silences = append(silences, Silence{Seconds(start), Seconds(time.Duration(o/cap) * time.Second)})
 	}
 
-	return nil
+	return silences, nil
 }
 
 func WaveDump(file string, offset, length time.Duration) ([]float32, int, error) {
@@ -51,38 +65,143 @@ func WaveDump(file string, offset, length time.Duration) ([]float32, int, error)
 	if err != nil {
 		return []float32{}, 0, err
 	}
+	defer r.Close()
 
 	cap := int(length.Seconds() * float64(r.SampleRate()))
 	block := make([]float32, cap, cap)
 	r.Seek(offset)
-	frames := r.ReadBlock(block)
+	frames, err := r.ReadBlock(block)
+	if err != nil {
+		return []float32{}, 0, err
+	}
 
 	return block, frames, nil
 }
 
-func WaveInfo(file string) (string, error) {
+// WaveProperties describes the format of a wave file.
+type WaveProperties struct {
+	SampleRate    int     `json:"sample_rate" yaml:"sample_rate"` // In Hz.
+	Channels      int     `json:"channels" yaml:"channels"`
+	BitsPerSample int     `json:"bits_per_sample" yaml:"bits_per_sample"`
+	Frames        int     `json:"frames" yaml:"frames"`
+	Duration      Seconds `json:"duration" yaml:"duration"`
+}
+
+// String implements the fmt.Stringer interface.
+func (p *WaveProperties) String() string {
+	return fmt.Sprintf("sample_rate: %d Hz, channels: %d bits_per_sample: %d frame_count: %d duration: %s",
+		p.SampleRate, p.Channels, p.BitsPerSample, p.Frames, p.Duration)
+}
+
+// WaveInfo returns the properties of a wave file.
+func WaveInfo(file string) (*WaveProperties, error) {
 	r, err := waveReader(file)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer r.Close()
 
-	return fmt.Sprintf("sample_rate: %d Hz, channels: %d bits_per_sample: %d frame_count: %d duration: %s",
-		r.SampleRate(), r.ChannelCount(), r.BitsPerSample(), r.FrameCount(), r.Duration()), nil
+	return &WaveProperties{
+		SampleRate:    int(r.SampleRate()),
+		Channels:      int(r.ChannelCount()),
+		BitsPerSample: int(r.BitsPerSample()),
+		Frames:        int(r.FrameCount()),
+		Duration:      Seconds(r.Duration()),
+	}, nil
+}
+
+// waveFile reads the samples of a RIFF, RF64 or BW64 wave file, a block at a
+// time, as values within [-1, 1). It replaces the goaudio wav codec, which
+// reads whole files into memory, and doesn't read RF64.
+type waveFile struct {
+	f      *os.File
+	h      *waveHeader
+	decode func([]byte) float64
+	frames int64
+	off    int64 // Of the next sample, within the data.
+	buf    []byte
+}
+
+func waveReader(file string) (*waveFile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newWaveFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
 }
 
-func waveReader(file string) (*wav.Reader, error) {
-	d, err := ioutil.ReadFile(file)
+func newWaveFile(f *os.File) (*waveFile, error) {
+	fi, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
-	r, err := wav.NewBytesReader(d)
+	h, err := readWaveHeader(f)
 	if err != nil {
 		return nil, err
 	}
+	if h.sampleRate == 0 {
+		return nil, fmt.Errorf("invalid sample rate of %d", h.sampleRate)
+	}
+	if h.channels == 0 || int(h.blockAlign) != int(h.channels)*(int(h.bitsPerSample+7)/8) {
+		return nil, fmt.Errorf("invalid block alignment of %d for %d channels", h.blockAlign, h.channels)
+	}
+	decode, err := sampleDecoder(h)
+	if err != nil {
+		return nil, err
+	}
+	return &waveFile{f: f, h: h, decode: decode, frames: h.dataFrames(fi.Size())}, nil
+}
 
-	if r.SampleRate() <= 0 {
-		return nil, fmt.Errorf("invalid sample rate of %d", r.SampleRate())
+func (r *waveFile) SampleRate() int    { return int(r.h.sampleRate) }
+func (r *waveFile) ChannelCount() int  { return int(r.h.channels) }
+func (r *waveFile) BitsPerSample() int { return int(r.h.bitsPerSample) }
+func (r *waveFile) FrameCount() int    { return int(r.frames) }
+
+// Duration returns the duration of the samples.
+func (r *waveFile) Duration() time.Duration {
+	return time.Duration(float64(r.frames) / float64(r.h.sampleRate) * float64(time.Second))
+}
+
+// Seek moves to the frame at the time t.
+func (r *waveFile) Seek(t time.Duration) {
+	frame := int64(t.Seconds() * float64(r.h.sampleRate))
+	if frame < 0 {
+		frame = 0
 	}
+	if frame > r.frames {
+		frame = r.frames
+	}
+	r.off = frame * int64(r.h.blockAlign)
+}
 
-	return r, nil
+// ReadBlock reads the next samples, interleaved by channel, into blk. It
+// returns the number of samples read, 0 at the end of the data.
+func (r *waveFile) ReadBlock(blk []float32) (int, error) {
+	width := int(r.h.bitsPerSample+7) / 8
+	n := int64(len(blk) * width)
+	if end := r.frames * int64(r.h.blockAlign); r.off+n > end {
+		n = end - r.off
+	}
+	if cap(r.buf) < int(n) {
+		r.buf = make([]byte, n)
+	}
+	buf := r.buf[:n]
+	read, err := r.f.ReadAt(buf, r.h.dataOffset+r.off)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("error reading samples; %s", err)
+	}
+	samples := read / width
+	for i := 0; i < samples; i++ {
+		blk[i] = float32(r.decode(buf[i*width:]))
+	}
+	r.off += int64(samples * width)
+	return samples, nil
 }
+
+// Close closes the file.
+func (r *waveFile) Close() error { return r.f.Close() }
@@ -0,0 +1,125 @@
+// Package wavetest writes wave files for tests.
+package wavetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Wave describes a wave file. The zero value, but for Channels, is a 16-bit
+// 48 kHz file of no samples.
+type Wave struct {
+	Bits     int       // Bits per sample; 16 if 0.
+	Float    bool      // Floating point samples, of 32 bits.
+	Rate     int       // Sample rate in Hz; 48000 if 0.
+	Channels int       // Channel count.
+	Samples  []float64 // Interleaved by channel, within [-1, 1).
+	// Description and TimeRef, the sample count since midnight, are written to
+	// a Broadcast Wave (bext) chunk, if either is set.
+	Description string
+	TimeRef     uint64
+	RF64        bool // Written as RF64, with a ds64 chunk.
+	Recording   bool // Sizes left 0, as by Tracks Live until recording stops.
+}
+
+// Silence returns a wave of frames of silence.
+func Silence(channels, frames int) Wave {
+	return Wave{Channels: channels, Samples: make([]float64, channels*frames)}
+}
+
+// Mono returns a mono 16-bit wave of the samples.
+func Mono(samples ...int16) Wave {
+	w := Wave{Channels: 1}
+	for _, s := range samples {
+		w.Samples = append(w.Samples, float64(s)/(1<<15))
+	}
+	return w
+}
+
+// Bytes returns the wave file.
+func (w Wave) Bytes() []byte {
+	bits, rate := w.Bits, w.Rate
+	if w.Float {
+		bits = 32
+	}
+	if bits == 0 {
+		bits = 16
+	}
+	if rate == 0 {
+		rate = 48000
+	}
+	width := bits / 8
+
+	data := &bytes.Buffer{}
+	for _, v := range w.Samples {
+		q := math.Float32bits(float32(v))
+		if !w.Float {
+			q = uint32(int32(math.Floor(v*float64(int64(1)<<uint(bits-1)) + 0.5)))
+		}
+		for i := 0; i < width; i++ {
+			data.WriteByte(byte(q >> (8 * uint(i))))
+		}
+	}
+	var bext []byte
+	if w.Description != "" || w.TimeRef != 0 {
+		bext = make([]byte, 602)
+		copy(bext, w.Description)
+		binary.LittleEndian.PutUint64(bext[338:], w.TimeRef)
+	}
+
+	b := &bytes.Buffer{}
+	le := func(v interface{}) { binary.Write(b, binary.LittleEndian, v) }
+	chunks := 4 + 8 + 16 + 8 + data.Len()
+	if bext != nil {
+		chunks += 8 + len(bext)
+	}
+	switch {
+	case w.RF64:
+		b.WriteString("RF64")
+		le(uint32(math.MaxUint32))
+	case w.Recording:
+		b.WriteString("RIFF")
+		le(uint32(0))
+	default:
+		b.WriteString("RIFF")
+		le(uint32(chunks))
+	}
+	b.WriteString("WAVE")
+	if w.RF64 {
+		b.WriteString("ds64")
+		le(uint32(28))
+		le(uint64(chunks + 8 + 28)) // RIFF size.
+		le(uint64(data.Len()))
+		le(uint64(len(w.Samples) / w.Channels))
+		le(uint32(0)) // Table length.
+	}
+	if bext != nil {
+		b.WriteString("bext")
+		le(uint32(len(bext)))
+		b.Write(bext)
+	}
+	b.WriteString("fmt ")
+	le(uint32(16))
+	if w.Float {
+		le(uint16(3)) // IEEE float.
+	} else {
+		le(uint16(1)) // PCM.
+	}
+	le(uint16(w.Channels))
+	le(uint32(rate))
+	le(uint32(rate * w.Channels * width))
+	le(uint16(w.Channels * width))
+	le(uint16(bits))
+	b.WriteString("data")
+	switch {
+	case w.RF64:
+		le(uint32(math.MaxUint32))
+	case w.Recording:
+		le(uint32(0))
+	default:
+		le(uint32(data.Len()))
+	}
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
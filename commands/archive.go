@@ -0,0 +1,378 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/venue"
+	"github.com/urfave/cli"
+)
+
+// archiveReadme is the name of the summary in an archive.
+const archiveReadme = "README.txt"
+
+func init() {
+	commands = append(commands, []cli.Command{
+		{
+			Name:      "archive",
+			Usage:     "archive the renamed tracks of a show, with its patch and checksums",
+			ArgsUsage: "[dir]",
+			Category:  "venue",
+			Flags: flagLists([]cli.Flag{
+				cli.StringFlag{
+					Name:  "archive_file,f",
+					Usage: "archive file to write (default: the directory name, with the format extension)",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "archive format (tar, zip); defaults to the archive file extension, or tar",
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of the top directory of the archive (default: the directory name)",
+				},
+				cli.StringFlag{
+					Name:  "patch_file,p",
+					Usage: "Venue patch or info file to include, with its parsed devices",
+				},
+				cli.StringFlag{
+					Name:  "checksum",
+					Value: actions.MD5.String(),
+					Usage: "checksum of the archived files (md5, sha256, xxh64)",
+				},
+				cli.StringFlag{
+					Name:  "manifest_format",
+					Value: actions.SumFormat.String(),
+					Usage: "checksum manifest format (sum, mhl)",
+				},
+				cli.StringFlag{
+					Name:  "volume_size",
+					Usage: "split the archive into volumes of this size (e.g. 700M, 4G)",
+				},
+			}, formatFlagList),
+			Action: ArchiveAction,
+		}, {
+			Name:      "extract",
+			Usage:     "extract an archive, and verify its files against its checksums",
+			ArgsUsage: "archive_file [dir]",
+			Category:  "venue",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "archive format (tar, zip); defaults to the archive file extension",
+				},
+			},
+			Action: ExtractAction,
+		},
+	}...)
+}
+
+// ArchiveAction implements cli.ActionFunc.
+func ArchiveAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	dir := ctx.Args().First()
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	name := ctx.String("name")
+	if name == "" {
+		name = filepath.Base(abs)
+	}
+	file := ctx.String("archive_file")
+	format, err := actions.ParseArchiveFormat(planFormat(ctx.String("format"), file, "tar"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	if file == "" {
+		file = name + format.Ext()
+	}
+	checksum, err := actions.ParseHashAlgorithm(ctx.String("checksum"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	manifestFormat, err := actions.ParseManifestFormat(ctx.String("manifest_format"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	if manifestFormat == actions.MHLFormat && checksum == actions.SHA256 {
+		return cli.NewExitError(fmt.Errorf("the mhl manifest format does not support %s", checksum), sysexits.Usage.Int())
+	}
+	volumeSize, err := parseBytes(ctx.String("volume_size"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	c, err := convertFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	if c != nil && *c == (actions.Converter{Encoding: actions.WaveEncoding}) {
+		c = nil // Archived as they are.
+	}
+
+	vw := actions.NewVolumeWriter(file, volumeSize)
+	ar := actions.NewArchiver(vw, format, name, checksum)
+	manifest := actions.ManifestName(checksum, manifestFormat)
+	sum, err := archiveShow(ar, dir, name, ctx.String("patch_file"), manifest, c, output)
+	if err == nil {
+		err = ar.AddManifest(manifestFormat)
+	}
+	if err == nil {
+		err = ar.Close()
+	}
+	if cerr := vw.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		for _, f := range vw.Files() {
+			os.Remove(f)
+		}
+		return cli.NewExitError(fmt.Errorf("error writing archive %q; %s", file, err), sysexits.IOError.Int())
+	}
+
+	sum.Volumes = vw.Files()
+	sum.Manifest = manifest
+	err = output.render(os.Stdout, sum, func(w io.Writer) {
+		fmt.Fprintf(w, "Archived %d files (%s) to %s\n", sum.Files, formatBytes(sum.Bytes), strings.Join(sum.Volumes, ", "))
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// archiveSummary describes an archive.
+type archiveSummary struct {
+	Name     string   `json:"name" yaml:"name"`
+	Volumes  []string `json:"volumes" yaml:"volumes"`
+	Files    int      `json:"files" yaml:"files"`
+	Bytes    int64    `json:"bytes" yaml:"bytes"`
+	Manifest string   `json:"manifest" yaml:"manifest"`
+}
+
+// archiveShow adds the wave files of dir, the patch file and its devices, and
+// a README summarizing the show. The manifest is added last, by the caller.
+// If c is set, the wave files are converted, one at a time, as they are added.
+func archiveShow(ar *actions.Archiver, dir, name, patchFile, manifest string, c *actions.Converter, output outputFormat) (*archiveSummary, error) {
+	sum := &archiveSummary{Name: name}
+	readme := &bytes.Buffer{}
+	fmt.Fprintf(readme, "%s\n\nArchived by tracks on %s.\n\n", name, time.Now().Format("2006-01-02 15:04"))
+
+	var v *venue.Venue
+	if patchFile != "" {
+		data, err := ioutil.ReadFile(patchFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Venue patch file; %s", err)
+		}
+		v = venue.NewVenue()
+		if err := v.Parse(data); err != nil {
+			return nil, fmt.Errorf("error parsing the Venue data; %s", err)
+		}
+		devs, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Base(patchFile)
+		parsed := strings.TrimSuffix(base, filepath.Ext(base)) + ".json"
+		for _, f := range []struct {
+			name string
+			data []byte
+		}{
+			{base, data},
+			{parsed, append(devs, '\n')},
+		} {
+			if err := ar.AddData(f.name, f.data, false); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Fprintf(readme, "Venue patch: %s (show %q, %s %s), parsed into %s.\n\n", base, v.Show(), v.Console(), v.Version(), parsed)
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	tmp := ""
+	if c != nil {
+		if tmp, err = ioutil.TempDir("", "tracks-archive"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+	}
+	fmt.Fprintf(readme, "Tracks:\n")
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".") || len(actions.FilterWaves([]string{fi.Name()})) == 0 {
+			continue
+		}
+		output.printf("  %q\n", fi.Name())
+		file, path, size := fi.Name(), filepath.Join(dir, fi.Name()), fi.Size()
+		if c != nil {
+			file = strings.TrimSuffix(file, filepath.Ext(file)) + c.Encoding.Ext()
+			_, res, err := c.Convert(path, filepath.Join(tmp, file), archiveComments(v, fi.Name())...)
+			if err != nil {
+				return nil, err
+			}
+			path, size = res.Dest, res.Bytes
+		}
+		if err := ar.AddFile(file, path); err != nil {
+			return nil, err
+		}
+		if c != nil {
+			os.Remove(path)
+		}
+		sum.Files++
+		sum.Bytes += size
+		fmt.Fprintf(readme, "  %s (%s)\n", file, formatBytes(size))
+	}
+	if sum.Files == 0 {
+		return nil, fmt.Errorf("no wave files found in %q", dir)
+	}
+	fmt.Fprintf(readme, "\n%d tracks, %s. The checksums of all files are in %s.\n",
+		sum.Files, formatBytes(sum.Bytes), manifest)
+	if err := ar.AddData(archiveReadme, readme.Bytes(), false); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// archiveTrackRE matches the filename of a renamed track, e.g. "01-02 Kick.wav".
+var archiveTrackRE = regexp.MustCompile(`^([0-9]+)-([0-9]+) (.*)\.[wW][aA][vV]$`)
+
+// archiveComments returns the Vorbis comments of a renamed track, with the
+// show of the Venue patch v, if not nil.
+func archiveComments(v *venue.Venue, file string) []string {
+	m := archiveTrackRE.FindStringSubmatch(file)
+	if m == nil {
+		return []string{"TITLE=" + strings.TrimSuffix(file, filepath.Ext(file))}
+	}
+	snum, _ := strconv.Atoi(m[1])
+	tnum, _ := strconv.Atoi(m[2])
+	return actions.TrackComments(v, &actions.PlanEntry{Session: snum, Track: tnum, Name: m[3]})
+}
+
+// ExtractAction implements cli.ActionFunc.
+func ExtractAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	file := ctx.Args().Get(0)
+	if file == "" {
+		return cli.NewExitError(fmt.Errorf("missing archive_file argument"), sysexits.Usage.Int())
+	}
+	dir := ctx.Args().Get(1)
+	if dir == "" {
+		dir = "."
+	}
+	format, err := actions.ParseArchiveFormat(planFormat(ctx.String("format"), strings.TrimSuffix(file, ".001"), ""))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+
+	vs, err := actions.OpenVolumes(file)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	defer vs.Close()
+	files, err := actions.ExtractArchive(vs, vs.Size(), format, dir)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error extracting %q; %s", file, err), sysexits.DataError.Int())
+	}
+
+	// Verify each top directory holding a manifest.
+	res := []*manifestVerification{}
+	seen := map[string]bool{}
+	for _, f := range files {
+		top := strings.SplitN(f, "/", 2)[0]
+		if seen[top] || top == f { // Not in a directory.
+			continue
+		}
+		seen[top] = true
+		v, err := extractVerify(filepath.Join(dir, top))
+		if err != nil {
+			return cli.NewExitError(err, sysexits.DataError.Int())
+		}
+		res = append(res, v)
+	}
+	if len(res) == 0 {
+		return cli.NewExitError(fmt.Errorf("no manifest found in %q", file), sysexits.DataError.Int())
+	}
+
+	err = output.render(os.Stdout, res, func(w io.Writer) {
+		for _, v := range res {
+			writeVerification(w, v, true)
+		}
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	for _, v := range res {
+		if !v.Passed {
+			return cli.NewExitError(fmt.Errorf("verification of %q failed", v.Dir), sysexits.DataError.Int())
+		}
+	}
+	return nil
+}
+
+// extractVerify verifies an extracted directory against its manifest.
+func extractVerify(dir string) (*manifestVerification, error) {
+	file := findManifest(dir)
+	if file == "" {
+		return nil, fmt.Errorf("no manifest found in %q", dir)
+	}
+	format, err := manifestFileFormat(file, "")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	m, err := actions.ReadManifest(f, format)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest %q; %s", file, err)
+	}
+	v, err := actions.VerifyManifest(dir, m, filepath.Base(file))
+	if err != nil {
+		return nil, fmt.Errorf("error verifying %q; %s", dir, err)
+	}
+	return &manifestVerification{dir, file, m.Algorithm.String(), v.Passed(), *v}, nil
+}
+
+// parseBytes parses a byte count, with an optional K, M, G or T suffix for
+// kibibytes, mebibytes, and so on. An empty string is 0.
+func parseBytes(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+		mult = 1 << (10 * uint(i+1))
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(n * float64(mult)), nil
+}
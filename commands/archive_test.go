@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/actions/wavetest"
+	"github.com/kward/tracks/flac"
+	"github.com/urfave/cli"
+)
+
+func TestArchiveShow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	for _, f := range []string{"01-01 Kick.wav", "01-02 Snare.wav", "notes.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(src, f), []byte(f), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	file := filepath.Join(dir, "show.zip")
+	vw := actions.NewVolumeWriter(file, 0)
+	ar := actions.NewArchiver(vw, actions.ZipArchive, "show", actions.XXH64)
+	manifest := actions.ManifestName(actions.XXH64, actions.MHLFormat)
+	sum, err := archiveShow(ar, src, "show", "../testdata/20170906 ICF Ladies Night.html", manifest, nil, jsonOutput)
+	if err != nil {
+		t.Fatalf("archiveShow() unexpected error %s", err)
+	}
+	if got, want := sum.Files, 2; got != want {
+		t.Errorf("archiveShow() archived %d files, want %d", got, want)
+	}
+	if err := ar.AddManifest(actions.MHLFormat); err != nil {
+		t.Fatalf("AddManifest() unexpected error %s", err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatalf("Close() unexpected error %s", err)
+	}
+	if err := vw.Close(); err != nil {
+		t.Fatalf("Close() unexpected error %s", err)
+	}
+
+	vs, err := actions.OpenVolumes(file)
+	if err != nil {
+		t.Fatalf("OpenVolumes() unexpected error %s", err)
+	}
+	defer vs.Close()
+	out := filepath.Join(dir, "out")
+	if _, err := actions.ExtractArchive(vs, vs.Size(), actions.ZipArchive, out); err != nil {
+		t.Fatalf("ExtractArchive() unexpected error %s", err)
+	}
+	v, err := extractVerify(filepath.Join(out, "show"))
+	if err != nil {
+		t.Fatalf("extractVerify() unexpected error %s", err)
+	}
+	if !v.Passed {
+		t.Errorf("extractVerify() = %+v", v)
+	}
+	// The tracks, the patch, the parsed patch and the README.
+	if got, want := len(v.OK), 5; got != want {
+		t.Errorf("extractVerify() verified %d files, want %d; %v", got, want, v.OK)
+	}
+	for _, f := range []string{"20170906 ICF Ladies Night.json", archiveReadme, manifest} {
+		if _, err := os.Stat(filepath.Join(out, "show", f)); err != nil {
+			t.Errorf("%s: %s", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "show", "notes.txt")); err == nil {
+		t.Errorf("archiveShow() archived notes.txt")
+	}
+
+	// Corrupt a file.
+	if err := ioutil.WriteFile(filepath.Join(out, "show", "01-01 Kick.wav"), []byte("oops"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if v, err := extractVerify(filepath.Join(out, "show")); err != nil || v.Passed {
+		t.Errorf("extractVerify() = %+v, %v; want a failure", v, err)
+	}
+}
+
+func TestArchiveShowFLAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	wave := wavetest.Mono(1, -1, 1000, -32768).Bytes()
+	if err := ioutil.WriteFile(filepath.Join(src, "01-02 Snare.wav"), wave, 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	file := filepath.Join(dir, "show.tar")
+	vw := actions.NewVolumeWriter(file, 0)
+	ar := actions.NewArchiver(vw, actions.TarArchive, "show", actions.MD5)
+	manifest := actions.ManifestName(actions.MD5, actions.SumFormat)
+	c := &actions.Converter{Encoding: actions.FLACEncoding}
+	if _, err := archiveShow(ar, src, "show", "../testdata/20170906 ICF Ladies Night.html", manifest, c, jsonOutput); err != nil {
+		t.Fatalf("archiveShow() unexpected error %s", err)
+	}
+	for _, err := range []error{ar.AddManifest(actions.SumFormat), ar.Close(), vw.Close()} {
+		if err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	vs, err := actions.OpenVolumes(file)
+	if err != nil {
+		t.Fatalf("OpenVolumes() unexpected error %s", err)
+	}
+	defer vs.Close()
+	out := filepath.Join(dir, "out")
+	if _, err := actions.ExtractArchive(vs, vs.Size(), actions.TarArchive, out); err != nil {
+		t.Fatalf("ExtractArchive() unexpected error %s", err)
+	}
+	if v, err := extractVerify(filepath.Join(out, "show")); err != nil || !v.Passed {
+		t.Errorf("extractVerify() = %+v, %v", v, err)
+	}
+	f, err := os.Open(filepath.Join(out, "show", "01-02 Snare.flac"))
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer f.Close()
+	d, err := flac.NewDecoder(f)
+	if err != nil {
+		t.Fatalf("NewDecoder() unexpected error %s", err)
+	}
+	comments := strings.Join(d.Comments, "\n")
+	for _, want := range []string{"TITLE=Snare", "TRACKNUMBER=2", "SESSION=1", "CHANNEL=Stage 1 2", "ALBUM="} {
+		if !strings.Contains(comments, want) {
+			t.Errorf("comments = %q, want %q", d.Comments, want)
+		}
+	}
+
+	// Decoding gives back the data chunk of the wave file.
+	dec := filepath.Join(dir, "decoded.wav")
+	if _, _, err := (&actions.Converter{}).Convert(f.Name(), dec); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+	if got, _ := ioutil.ReadFile(dec); !bytes.HasSuffix(got, wave[36:]) {
+		t.Errorf("the decoded wave file differs")
+	}
+}
+
+func TestArchiveActionFloat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	// Tracks Live records 32-bit float.
+	wave := filepath.Join(dir, "int.wav")
+	if err := ioutil.WriteFile(wave, wavetest.Mono(1, -1, 1000, -32768).Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if _, _, err := (&actions.Converter{Format: actions.Float32}).Convert(wave, filepath.Join(src, "01-02 Snare.wav")); err != nil {
+		t.Fatalf("Convert() unexpected error %s", err)
+	}
+
+	defer func(exiter func(int)) { cli.OsExiter = exiter }(cli.OsExiter)
+	cli.OsExiter = func(int) {}
+	for _, tt := range []struct {
+		desc string
+		args []string
+		ok   bool
+	}{
+		{"flac of float", []string{"--encoding", "flac"}, false},
+		{"flac of 24-bit", []string{"--encoding", "flac", "--sample_format", "24"}, true},
+	} {
+		file := filepath.Join(dir, "show.tar")
+		app := cli.NewApp()
+		app.Commands = []cli.Command{{
+			Name:   "archive",
+			Flags:  commandNamed("archive").Flags,
+			Action: ArchiveAction,
+		}}
+		app.Writer, app.ErrWriter = ioutil.Discard, ioutil.Discard
+		args := append([]string{"tracks", "archive", "--archive_file", file}, tt.args...)
+		err := app.Run(append(args, src))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Run() = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+		_, serr := os.Stat(file)
+		if (serr == nil) != tt.ok {
+			t.Errorf("%s: archive written = %v, want %v", tt.desc, serr == nil, tt.ok)
+		}
+		os.Remove(file)
+	}
+}
+
+// commandNamed returns the command of the name.
+func commandNamed(name string) cli.Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return cli.Command{}
+}
+
+func TestParseBytes(t *testing.T) {
+	for _, tt := range []struct {
+		size string
+		ok   bool
+		n    int64
+	}{
+		{"", true, 0},
+		{"1024", true, 1024},
+		{"1.5KiB", true, 1536},
+		{"700M", true, 700 << 20},
+		{"4G", true, 4 << 30},
+		{"4gb", true, 4 << 30},
+		{"1T", true, 1 << 40},
+		{"x", false, 0},
+		{"-1M", false, 0},
+	} {
+		n, err := parseBytes(tt.size)
+		if err == nil && !tt.ok {
+			t.Errorf("parseBytes(%q) expected error", tt.size)
+			continue
+		}
+		if err != nil && tt.ok {
+			t.Errorf("parseBytes(%q) unexpected error %s", tt.size, err)
+			continue
+		}
+		if got, want := n, tt.n; got != want {
+			t.Errorf("parseBytes(%q) = %d, want %d", tt.size, got, want)
+		}
+	}
+}
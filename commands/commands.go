@@ -1,8 +1,68 @@
 package commands
 
-import "github.com/urfave/cli"
+import (
+	"sync"
+
+	"github.com/urfave/cli"
+)
 
 var commands []cli.Command
 
-// Commands returns the supported cli commands.
-func Commands() []cli.Command { return commands }
+// Commands returns the supported cli commands. Each command applies the
+// defaults of the configuration file (see Config) to its flags.
+func Commands() []cli.Command {
+	cs := make([]cli.Command, len(commands))
+	for i, c := range commands {
+		c.Before = withConfig(c.Before)
+		cs[i] = c
+	}
+	return cs
+}
+
+// flagLists returns the concatenation of flag lists.
+func flagLists(lists ...[]cli.Flag) []cli.Flag {
+	fs := []cli.Flag{}
+	for _, l := range lists {
+		fs = append(fs, l...)
+	}
+	return fs
+}
+
+// parallel calls fn for each index below count, with up to n calls at once.
+// Once a call fails, no further calls are made, and its error is returned.
+func parallel(n, count int, fn func(i int) error) error {
+	if n < 1 {
+		n = 1
+	}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		next  int
+		first error
+	)
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if first != nil || next == count {
+					mu.Unlock()
+					return
+				}
+				i := next
+				next++
+				mu.Unlock()
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the name of the project configuration file, which is looked
+// for in the working directory and its parents.
+const ConfigFile = ".tracks.yaml"
+
+// pathFlags are the flags holding paths. Relative paths in a configuration
+// file are relative to the directory of the file.
+var pathFlags = map[string]bool{
+	"backup_dir":    true,
+	"dest_dir":      true,
+	"fallback_file": true,
+	"log_file":      true,
+	"manifest_file": true,
+	"overrides":     true,
+	"patch_file":    true,
+	"plan_file":     true,
+	"src_dir":       true,
+	"state_file":    true,
+}
+
+// Config holds flag defaults, e.g.
+//
+//	flags:             # For all commands.
+//	  patch_file: venue/patch.html
+//	commands:          # For one command.
+//	  copy:
+//	    backup_dir: [/Volumes/Backup]
+//	profiles:          # Chosen with --profile.
+//	  zurich:
+//	    flags:
+//	      src_dir: /Volumes/Tracks/Zurich
+//
+// A profile overrides the defaults, and flags given on the command line
+// override both.
+type Config struct {
+	Flags    map[string]interface{}            `yaml:"flags"`
+	Commands map[string]map[string]interface{} `yaml:"commands"`
+	Profiles map[string]*Config                `yaml:"profiles"`
+
+	file string
+}
+
+// FindConfig looks for the configuration file in dir and its parents. It
+// returns "" if there is none.
+func FindConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		file := filepath.Join(dir, ConfigFile)
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			return file
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ReadConfig reads a configuration file.
+func ReadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("error parsing %q; %s", file, err)
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("%s: profile %q is empty", file, name)
+		}
+		if len(p.Profiles) > 0 {
+			return nil, fmt.Errorf("%s: profile %q cannot have profiles", file, name)
+		}
+	}
+	c.file = file
+	return c, nil
+}
+
+// Defaults returns the flag values of a command, for the given profile (or
+// none if ""). Values of later sources replace those of earlier ones, in the
+// order: flags, command, profile flags, profile command.
+func (c *Config) Defaults(command, profile string) (map[string][]string, error) {
+	sources := []map[string]interface{}{c.Flags, c.Commands[command]}
+	if profile != "" {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown profile %q", c.file, profile)
+		}
+		sources = append(sources, p.Flags, p.Commands[command])
+	}
+
+	defs := map[string][]string{}
+	for _, src := range sources {
+		for name, v := range src {
+			vals, err := configValues(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: flag %s; %s", c.file, name, err)
+			}
+			if pathFlags[name] {
+				for i, val := range vals {
+					vals[i] = c.path(val)
+				}
+			}
+			defs[name] = vals
+		}
+	}
+	return defs, nil
+}
+
+// path resolves a path relative to the configuration file, expanding "~".
+func (c *Config) path(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home := homeDir(); home != "" {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	if p == "" || filepath.IsAbs(p) || c.file == "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(c.file), p)
+}
+
+// homeDir returns the home directory of the user, or "" if unknown.
+func homeDir() string {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("USERPROFILE")
+	}
+	return os.Getenv("HOME")
+}
+
+// configValues returns the string values of a YAML flag value.
+func configValues(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return []string{}, nil
+	case []interface{}:
+		vals := []string{}
+		for _, e := range v {
+			sub, err := configValues(e)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) != 1 {
+				return nil, fmt.Errorf("unsupported list value %v", e)
+			}
+			vals = append(vals, sub...)
+		}
+		return vals, nil
+	case string, bool, int, float64:
+		return []string{fmt.Sprint(v)}, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+// withConfig returns a cli.BeforeFunc that applies the configuration file
+// defaults to the command flags, before calling before (if not nil).
+func withConfig(before cli.BeforeFunc) cli.BeforeFunc {
+	return func(ctx *cli.Context) error {
+		if err := applyConfig(ctx); err != nil {
+			return cli.NewExitError(err, sysexits.Usage.Int())
+		}
+		if before != nil {
+			return before(ctx)
+		}
+		return nil
+	}
+}
+
+// applyConfig sets the flags of the command that were not given on the
+// command line from the configuration file, if any.
+func applyConfig(ctx *cli.Context) error {
+	file, profile := ctx.GlobalString("config"), ctx.GlobalString("profile")
+	if file == "" {
+		file = FindConfig(".")
+	}
+	if file == "" {
+		if profile != "" {
+			return fmt.Errorf("profile %q given, but no %s found", profile, ConfigFile)
+		}
+		return nil
+	}
+	c, err := ReadConfig(file)
+	if err != nil {
+		return err
+	}
+	defs, err := c.Defaults(ctx.Command.Name, profile)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, f := range ctx.Command.Flags {
+		known[strings.TrimSpace(strings.Split(f.GetName(), ",")[0])] = true
+	}
+	cmds := []map[string]interface{}{c.Commands[ctx.Command.Name]}
+	if p, ok := c.Profiles[profile]; ok {
+		cmds = append(cmds, p.Commands[ctx.Command.Name])
+	}
+	for _, cmd := range cmds {
+		for name := range cmd {
+			if !known[name] {
+				return fmt.Errorf("%s: unknown flag %s of command %s", file, name, ctx.Command.Name)
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Flags for all commands only apply to commands that have them.
+		if !known[name] || ctx.IsSet(name) {
+			continue
+		}
+		for _, v := range defs[name] {
+			if err := ctx.Set(name, v); err != nil {
+				return fmt.Errorf("%s: flag %s; %s", file, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+const testConfig = `
+flags:
+  patch_file: venue/patch.html
+  dest_dir: /Volumes/Stems
+  max_filename_length: 64
+commands:
+  copy:
+    backup_dir: [backup1, /Volumes/Backup2]
+profiles:
+  zurich:
+    flags:
+      dest_dir: zurich
+    commands:
+      copy:
+        checksum: md5
+`
+
+func writeConfig(t *testing.T, dir, data string) string {
+	file := filepath.Join(dir, ConfigFile)
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	return file
+}
+
+func TestFindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	file := writeConfig(t, dir, testConfig)
+	if got, want := FindConfig(sub), file; got != want {
+		t.Errorf("FindConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := ReadConfig(writeConfig(t, dir, testConfig))
+	if err != nil {
+		t.Fatalf("ReadConfig() unexpected error %s", err)
+	}
+
+	for _, tt := range []struct {
+		desc             string
+		command, profile string
+		ok               bool
+		want             map[string][]string
+	}{
+		{"move", "move", "", true, map[string][]string{
+			"patch_file":          {filepath.Join(dir, "venue/patch.html")},
+			"dest_dir":            {"/Volumes/Stems"},
+			"max_filename_length": {"64"},
+		}},
+		{"copy", "copy", "", true, map[string][]string{
+			"patch_file":          {filepath.Join(dir, "venue/patch.html")},
+			"dest_dir":            {"/Volumes/Stems"},
+			"max_filename_length": {"64"},
+			"backup_dir":          {filepath.Join(dir, "backup1"), "/Volumes/Backup2"},
+		}},
+		{"copy zurich", "copy", "zurich", true, map[string][]string{
+			"patch_file":          {filepath.Join(dir, "venue/patch.html")},
+			"dest_dir":            {filepath.Join(dir, "zurich")},
+			"max_filename_length": {"64"},
+			"backup_dir":          {filepath.Join(dir, "backup1"), "/Volumes/Backup2"},
+			"checksum":            {"md5"},
+		}},
+		{"unknown profile", "copy", "geneva", false, nil},
+	} {
+		got, err := c.Defaults(tt.command, tt.profile)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Defaults() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Defaults() = %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	env := "HOME"
+	if runtime.GOOS == "windows" {
+		env = "USERPROFILE"
+	}
+	defer os.Setenv(env, os.Getenv(env))
+	os.Setenv(env, "/home/fred")
+
+	c := &Config{file: "/shows/.tracks.yaml"}
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"~", "/home/fred"},
+		{"~/Stems", "/home/fred/Stems"},
+		{"Stems", "/shows/Stems"},
+		{"/Volumes/Stems", "/Volumes/Stems"},
+		{"", ""},
+	} {
+		if got, want := c.path(tt.path), filepath.FromSlash(tt.want); got != want {
+			t.Errorf("path(%q) = %q, want %q", tt.path, got, want)
+		}
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		desc string
+		data string
+	}{
+		{"unknown key", "flag:\n  dest_dir: x\n"},
+		{"nested profiles", "profiles:\n  a:\n    profiles:\n      b: {}\n"},
+		{"empty profile", "profiles:\n  a:\n"},
+	} {
+		if _, err := ReadConfig(writeConfig(t, dir, tt.data)); err == nil {
+			t.Errorf("%s: ReadConfig() expected error", tt.desc)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, testConfig)
+	defer func(exiter func(int)) { cli.OsExiter = exiter }(cli.OsExiter)
+	cli.OsExiter = func(int) {}
+
+	for _, tt := range []struct {
+		desc string
+		args []string
+		ok   bool
+		dest string
+		max  int
+	}{
+		{"defaults", []string{"--config", file, "copy"}, true, "/Volumes/Stems", 64},
+		{"profile", []string{"--config", file, "--profile", "zurich", "copy"}, true, filepath.Join(dir, "zurich"), 64},
+		{"command line", []string{"--config", file, "--profile", "zurich", "copy", "--dest_dir", "cli"}, true, "cli", 64},
+		{"unknown profile", []string{"--config", file, "--profile", "geneva", "copy"}, false, "", 0},
+	} {
+		var dest string
+		var max int
+		var backups []string
+		app := cli.NewApp()
+		app.Flags = []cli.Flag{
+			cli.StringFlag{Name: "config"},
+			cli.StringFlag{Name: "profile"},
+		}
+		app.Commands = []cli.Command{{
+			Name: "copy",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dest_dir,d"},
+				cli.IntFlag{Name: "max_filename_length", Value: 255},
+				cli.StringSliceFlag{Name: "backup_dir,b"},
+				cli.StringFlag{Name: "checksum"},
+			},
+			Before: withConfig(nil),
+			Action: func(ctx *cli.Context) error {
+				dest, max, backups = ctx.String("dest_dir"), ctx.Int("max_filename_length"), ctx.StringSlice("backup_dir")
+				return nil
+			},
+		}}
+		app.ErrWriter = ioutil.Discard
+
+		err := app.Run(append([]string{"tracks"}, tt.args...))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Run() unexpected error %v", tt.desc, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if dest != tt.dest || max != tt.max {
+			t.Errorf("%s: dest_dir, max_filename_length = %q, %d; want %q, %d", tt.desc, dest, max, tt.dest, tt.max)
+		}
+		if got, want := backups, []string{filepath.Join(dir, "backup1"), "/Volumes/Backup2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: backup_dir = %q, want %q", tt.desc, got, want)
+		}
+	}
+}
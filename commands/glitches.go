@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:      "glitches",
+		Usage:     "find dropouts, repeated buffers, clicks and clipping in wave files, and glitches of the recording link",
+		ArgsUsage: "[file...]",
+		Category:  "wave",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "src_dir,s", Usage: "source directory, to check each session of its tracks"},
+			cli.IntFlag{Name: "session", Usage: "only check this session of the source directory"},
+			cli.DurationFlag{
+				Name:  "tolerance",
+				Value: actions.DefaultGlitchTolerance,
+				Usage: "time within which glitches of the tracks of a session are taken to be at once",
+			},
+			cli.IntFlag{
+				Name:  "jobs,j",
+				Value: runtime.NumCPU(),
+				Usage: "number of files to check at once",
+			},
+		},
+		Action: WaveGlitchesAction,
+	})
+}
+
+// sessionGlitches holds the glitches found in the tracks of a session.
+type sessionGlitches struct {
+	Session int                      `json:"session,omitempty" yaml:"session,omitempty"`
+	Tracks  []*actions.TrackGlitches `json:"tracks" yaml:"tracks"`
+	// Link holds the times of the glitches found on every track at once.
+	Link  []actions.Seconds `json:"link,omitempty" yaml:"link,omitempty"`
+	files []string
+}
+
+// WaveGlitchesAction implements cli.ActionFunc. The files given are checked
+// as the tracks of a single session. With a source directory, each session of
+// its Tracks Live recordings is checked in turn.
+func WaveGlitchesAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	if ctx.Duration("tolerance") < 0 {
+		return cli.NewExitError(fmt.Errorf("invalid tolerance %s", ctx.Duration("tolerance")), sysexits.Usage.Int())
+	}
+	sessions, err := glitchSessions(ctx.Args(), ctx.String("src_dir"), ctx.Int("session"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+
+	for _, s := range sessions {
+		s.Tracks = make([]*actions.TrackGlitches, len(s.files))
+		err := parallel(ctx.Int("jobs"), len(s.files), func(i int) error {
+			var err error
+			s.Tracks[i], err = actions.DetectGlitches(s.files[i])
+			return err
+		})
+		if err != nil {
+			return cli.NewExitError(err, sysexits.DataError.Int())
+		}
+		s.Link = actions.LinkGlitches(s.Tracks, ctx.Duration("tolerance"))
+	}
+	err = output.render(os.Stdout, sessions, func(w io.Writer) {
+		for _, s := range sessions {
+			for _, t := range s.Tracks {
+				for _, g := range t.Glitches {
+					fmt.Fprintf(w, "%s: %s\n", t.File, g)
+				}
+			}
+			if len(s.Link) == 0 {
+				continue
+			}
+			times := []string{}
+			for _, t := range s.Link {
+				times = append(times, t.String())
+			}
+			name := "files given"
+			if s.Session != 0 {
+				name = fmt.Sprintf("session %d", s.Session)
+			}
+			fmt.Fprintf(w, "%s: glitches on every track, pointing to the recording link, at %s\n", name, strings.Join(times, ", "))
+		}
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// glitchSessions returns the sessions to check: the files given, or else each
+// session of the source directory, limited to a session if not zero.
+func glitchSessions(args []string, srcDir string, session int) ([]*sessionGlitches, error) {
+	if len(args) > 0 {
+		if srcDir != "" || session != 0 {
+			return nil, fmt.Errorf("files given with the src_dir or session flag")
+		}
+		return []*sessionGlitches{{files: args}}, nil
+	}
+	if srcDir == "" {
+		return nil, fmt.Errorf("missing file argument or %s flag", "src_dir")
+	}
+	ss, err := srcDirSessions(srcDir, session)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*sessionGlitches{}
+	for _, s := range ss {
+		sessions = append(sessions, &sessionGlitches{Session: s.Num(), files: sessionTrackFiles(srcDir, s)})
+	}
+	return sessions, nil
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestGlitchSessions(t *testing.T) {
+	discoverFilesFn = func(_ string, _ ...actions.Filter) ([]string, error) {
+		return []string{"Track 02-2.wav", "Track 01-1.wav", "Track 01-2.wav", "Track 02-1.wav"}, nil
+	}
+	defer resetDiscoverFiles()
+
+	type session struct {
+		num   int
+		files []string
+	}
+	rec := func(file string) string { return filepath.Join("rec", file) }
+	for _, tt := range []struct {
+		desc    string
+		args    []string
+		session int
+		want    []session
+		ok      bool
+	}{
+		{"files", []string{"a.wav", "b.wav"}, 0, []session{{0, []string{"a.wav", "b.wav"}}}, true},
+		{"src_dir", nil, 0, []session{
+			{1, []string{rec("Track 01-1.wav"), rec("Track 02-1.wav")}},
+			{2, []string{rec("Track 01-2.wav"), rec("Track 02-2.wav")}},
+		}, true},
+		{"session", nil, 2, []session{{2, []string{rec("Track 01-2.wav"), rec("Track 02-2.wav")}}}, true},
+		{"missing session", nil, 3, nil, false},
+	} {
+		srcDir := "rec"
+		if tt.args != nil {
+			srcDir = ""
+		}
+		ss, err := glitchSessions(tt.args, srcDir, tt.session)
+		if err != nil && tt.ok {
+			t.Errorf("%s: glitchSessions() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if err == nil && !tt.ok {
+			t.Errorf("%s: glitchSessions() expected error", tt.desc)
+			continue
+		}
+		var got []session
+		for _, s := range ss {
+			got = append(got, session{s.Session, s.files})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: glitchSessions() = %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+
+	if _, err := glitchSessions([]string{"a.wav"}, "rec", 0); err == nil {
+		t.Errorf("glitchSessions() of files and src_dir expected error")
+	}
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// outputFormat is the format of the command output, chosen with the global
+// output flag. Text output is printed as the command progresses, whereas
+// JSON and YAML output is rendered once the command is done.
+type outputFormat int
+
+const (
+	textOutput outputFormat = iota
+	jsonOutput
+	yamlOutput
+)
+
+func parseOutputFormat(name string) (outputFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return textOutput, nil
+	case "json":
+		return jsonOutput, nil
+	case "yaml", "yml":
+		return yamlOutput, nil
+	}
+	return textOutput, fmt.Errorf("unsupported output format %q", name)
+}
+
+// outputFlag returns the output format of the global output flag.
+func outputFlag(ctx *cli.Context) (outputFormat, error) {
+	return parseOutputFormat(ctx.GlobalString("output"))
+}
+
+// printf prints text output. It prints nothing for other output formats.
+func (f outputFormat) printf(format string, a ...interface{}) {
+	if f == textOutput {
+		fmt.Printf(format, a...)
+	}
+}
+
+// render writes v as JSON or YAML. For text output, it calls text instead,
+// unless text is nil.
+func (f outputFormat) render(w io.Writer, v interface{}, text func(w io.Writer)) error {
+	switch f {
+	case jsonOutput:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case yamlOutput:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		// Each result is a separate document, e.g. for each session watched.
+		_, err = fmt.Fprintf(w, "---\n%s", data)
+		return err
+	}
+	if text != nil {
+		text(w)
+	}
+	return nil
+}
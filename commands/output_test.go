@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/tracks"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want outputFormat
+		ok   bool
+	}{
+		{"", textOutput, true},
+		{"text", textOutput, true},
+		{"JSON", jsonOutput, true},
+		{"yaml", yamlOutput, true},
+		{"yml", yamlOutput, true},
+		{"xml", textOutput, false},
+	} {
+		got, err := parseOutputFormat(tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseOutputFormat(%q) unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseOutputFormat(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	op := &actions.Operation{
+		Src:        "Track 02-1.wav",
+		Dest:       "01-02 Kick.wav",
+		Session:    1,
+		Track:      2,
+		Name:       "Kick",
+		NameSource: tracks.NameFromChannel,
+		Status:     actions.OperationDone,
+	}
+	v := struct {
+		Operations []*actions.Operation `json:"operations" yaml:"operations"`
+		Duration   actions.Seconds      `json:"duration" yaml:"duration"`
+	}{[]*actions.Operation{op}, actions.Seconds(1500 * time.Millisecond)}
+
+	for _, tt := range []struct {
+		desc   string
+		format outputFormat
+		want   string
+	}{
+		{"text", textOutput, "1 operations\n"},
+		{"json", jsonOutput, `{
+  "operations": [
+    {
+      "src": "Track 02-1.wav",
+      "dest": "01-02 Kick.wav",
+      "session": 1,
+      "track": 2,
+      "name": "Kick",
+      "name_source": "channel",
+      "status": "done"
+    }
+  ],
+  "duration": 1.5
+}
+`},
+		{"yaml", yamlOutput, `---
+operations:
+- src: Track 02-1.wav
+  dest: 01-02 Kick.wav
+  session: 1
+  track: 2
+  name: Kick
+  name_source: channel
+  status: done
+duration: 1.5
+`},
+	} {
+		var buf bytes.Buffer
+		err := tt.format.render(&buf, v, func(w io.Writer) {
+			fmt.Fprintf(w, "%d operations\n", len(v.Operations))
+		})
+		if err != nil {
+			t.Errorf("%s: render() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: render() = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/tracks"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:      "polarity",
+		Usage:     "check the polarity of, and time offset between, tracks of the same source",
+		ArgsUsage: "[file...]",
+		Category:  "wave",
+		Flags: flagLists([]cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "pair",
+				Usage: "channel names of two tracks of the same source, e.g. \"Kick 91,Kick 52\"; may be repeated",
+			},
+			cli.DurationFlag{
+				Name:  "max_lag",
+				Value: actions.DefaultMaxLag,
+				Usage: "greatest time offset looked for between tracks",
+			},
+		}, venueFlagList),
+		Action: WavePolarityAction,
+	})
+}
+
+// WavePolarityAction implements cli.ActionFunc. Without a patch file, the first
+// file is compared with each of the others. With a patch file, the tracks of
+// each session whose Venue channel names are of the same source, e.g.
+// "Kick In" and "Kick Out", are compared, as are the pairs given.
+func WavePolarityAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	pairs, err := parsePairs(ctx.StringSlice("pair"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+
+	var cs []*trackCorrelation
+	if ctx.String("patch_file") == "" {
+		if len(ctx.Args()) < 2 {
+			return cli.NewExitError(fmt.Errorf("missing file arguments or patch_file flag"), sysexits.Usage.Int())
+		}
+		if len(pairs) > 0 {
+			return cli.NewExitError(fmt.Errorf("the pair flag needs a patch_file"), sysexits.Usage.Int())
+		}
+		for _, file := range ctx.Args()[1:] {
+			cs = append(cs, &trackCorrelation{
+				A: filepath.Base(ctx.Args()[0]), B: filepath.Base(file),
+				fileA: ctx.Args()[0], fileB: file,
+			})
+		}
+	} else {
+		if ctx.Args().Present() {
+			return cli.NewExitError(fmt.Errorf("the tracks of a patch_file are found in src_dir, not given as arguments"), sysexits.Usage.Int())
+		}
+		flags, err := venueFlags(ctx)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.Usage.Int())
+		}
+		names, err := venueNames(flags)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.Software.Int())
+		}
+		cs = relatedTracks(flags, names, pairs)
+	}
+
+	for _, c := range cs {
+		corr, err := actions.Correlate(c.fileA, c.fileB, ctx.Duration("max_lag"))
+		if err != nil {
+			return cli.NewExitError(err, sysexits.DataError.Int())
+		}
+		c.Correlation = corr
+	}
+	err = output.render(os.Stdout, cs, func(w io.Writer) {
+		if len(cs) == 0 {
+			fmt.Fprintln(w, "no tracks of the same source")
+		}
+		for _, c := range cs {
+			fmt.Fprintf(w, "%s / %s: %s\n", c.A, c.B, c.Correlation)
+		}
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// trackCorrelation describes the correlation of two tracks.
+type trackCorrelation struct {
+	Session      int                  `json:"session,omitempty" yaml:"session,omitempty"`
+	A            string               `json:"track_a" yaml:"track_a"`
+	B            string               `json:"track_b" yaml:"track_b"`
+	Correlation  *actions.Correlation `json:"correlation" yaml:"correlation"`
+	fileA, fileB string
+}
+
+// parsePairs parses pairs of channel names, e.g. "Kick 91,Kick 52".
+func parsePairs(values []string) ([][2]string, error) {
+	pairs := [][2]string{}
+	for _, v := range values {
+		names := strings.Split(v, ",")
+		if len(names) != 2 || strings.TrimSpace(names[0]) == "" || strings.TrimSpace(names[1]) == "" {
+			return nil, fmt.Errorf("invalid pair %q; want two channel names separated by a comma", v)
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(names[0]), strings.TrimSpace(names[1])})
+	}
+	return pairs, nil
+}
+
+// relatedTracks returns the tracks of each session to compare: those named
+// after the same source, and those of the pairs, matched by channel name
+// regardless of case. Tracks not named from the patch are never related.
+func relatedTracks(flags VenueFlags, names []VenueNames, pairs [][2]string) []*trackCorrelation {
+	cs := []*trackCorrelation{}
+	bySession := map[int][]VenueNames{}
+	sessions := []int{}
+	for _, n := range names {
+		if _, ok := bySession[n.snum]; !ok {
+			sessions = append(sessions, n.snum)
+		}
+		bySession[n.snum] = append(bySession[n.snum], n)
+	}
+	for _, snum := range sessions {
+		ns := bySession[snum]
+		named := []string{}
+		for _, n := range ns {
+			name := n.name
+			if n.source == tracks.NameFromOriginal || n.source == tracks.NameFromNumber {
+				name = ""
+			}
+			named = append(named, name)
+		}
+		related := actions.RelatedTracks(named)
+		for _, p := range pairs {
+			a, b := -1, -1
+			for i, name := range named {
+				switch {
+				case a < 0 && channelNamed(name, p[0]):
+					a = i
+				case b < 0 && channelNamed(name, p[1]):
+					b = i
+				}
+			}
+			if a < 0 || b < 0 || containsPair(related, a, b) {
+				continue
+			}
+			related = append(related, [2]int{a, b})
+		}
+		for _, r := range related {
+			a, b := ns[r[0]], ns[r[1]]
+			cs = append(cs, &trackCorrelation{
+				Session: snum,
+				A:       fmt.Sprintf("%02d-%02d %s", snum, a.tnum, a.name),
+				B:       fmt.Sprintf("%02d-%02d %s", snum, b.tnum, b.name),
+				fileA:   filepath.Join(flags.srcDir, a.orig),
+				fileB:   filepath.Join(flags.srcDir, b.orig),
+			})
+		}
+	}
+	return cs
+}
+
+// containsPair returns true if the pairs hold a and b, in either order.
+func containsPair(pairs [][2]int, a, b int) bool {
+	for _, p := range pairs {
+		if p == [2]int{a, b} || p == [2]int{b, a} {
+			return true
+		}
+	}
+	return false
+}
+
+// channelNamed returns true if the track name, or one of a list of names, e.g.
+// "Kick 91, iKick", is the channel name, regardless of case.
+func channelNamed(name, channel string) bool {
+	for _, n := range strings.Split(name, ",") {
+		if strings.EqualFold(strings.TrimSpace(n), channel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kward/tracks/tracks"
+)
+
+func TestRelatedTracks(t *testing.T) {
+	flags := VenueFlags{srcDir: "rec"}
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", name: "Kick In", snum: 1, tnum: 1, source: tracks.NameFromChannel},
+		{orig: "Track 02-1.wav", name: "Kick Out", snum: 1, tnum: 2, source: tracks.NameFromChannel},
+		{orig: "Track 03-1.wav", name: "Bass, iBass", snum: 1, tnum: 3, source: tracks.NameFromChannel},
+		{orig: "Track 04-1.wav", name: "Bass Amp", snum: 1, tnum: 4, source: tracks.NameFromOverride},
+		{orig: "Track 05-1.wav", name: "Track 05", snum: 1, tnum: 5, source: tracks.NameFromNumber},
+		{orig: "Track 06-1.wav", name: "Track 06", snum: 1, tnum: 6, source: tracks.NameFromNumber},
+		{orig: "Track 01-2.wav", name: "Kick In", snum: 2, tnum: 1, source: tracks.NameFromChannel},
+	}
+	pairs, err := parsePairs([]string{"bass, Bass Amp", "Kick In,Kick Out"})
+	if err != nil {
+		t.Fatalf("parsePairs() unexpected error %s", err)
+	}
+	cs := relatedTracks(flags, names, pairs)
+	want := [][2]string{
+		{"01-01 Kick In", "01-02 Kick Out"},
+		{"01-03 Bass, iBass", "01-04 Bass Amp"},
+	}
+	if got := len(cs); got != len(want) {
+		t.Fatalf("relatedTracks() returned %d pairs, want %d", got, len(want))
+	}
+	for i, c := range cs {
+		if c.A != want[i][0] || c.B != want[i][1] || c.Session != 1 {
+			t.Errorf("relatedTracks()[%d] = %d %q / %q, want 1 %q / %q", i, c.Session, c.A, c.B, want[i][0], want[i][1])
+		}
+	}
+	if got, want := cs[0].fileB, filepath.Join("rec", "Track 02-1.wav"); got != want {
+		t.Errorf("relatedTracks() file = %q, want %q", got, want)
+	}
+
+	for _, pair := range []string{"Kick In", "Kick In,", "a,b,c"} {
+		if _, err := parsePairs([]string{pair}); err == nil {
+			t.Errorf("parsePairs(%q) expected error", pair)
+		}
+	}
+}
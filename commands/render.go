@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:      "render",
+		Usage:     "render waveform overviews of wave files, or of each session of a show",
+		ArgsUsage: "[file...]",
+		Category:  "wave",
+		Flags: flagLists([]cli.Flag{
+			cli.StringFlag{
+				Name:  "format",
+				Value: actions.PNGImage.String(),
+				Usage: "image format (png, svg)",
+			},
+			cli.IntFlag{
+				Name:  "width",
+				Value: 1200,
+				Usage: "image width, in pixels",
+			},
+			cli.IntFlag{
+				Name:  "height",
+				Value: 60,
+				Usage: "height of each track, in pixels",
+			},
+		}, venueFlagList),
+		Action: WaveRenderAction,
+	})
+}
+
+// WaveRenderAction implements cli.ActionFunc. Without a patch file, each file
+// is rendered to an image of its own, next to it unless a destination
+// directory is given. With a patch file, the tracks found in the source
+// directory are stacked in an image per session, labeled with their Venue
+// channel names.
+func WaveRenderAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	format, err := actions.ParseImageFormat(ctx.String("format"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	width, height := ctx.Int("width"), ctx.Int("height")
+	if width < 1 || height < 1 {
+		return cli.NewExitError(fmt.Errorf("invalid image size %dx%d", width, height), sysexits.Usage.Int())
+	}
+
+	var images []*waveImage
+	if ctx.String("patch_file") == "" {
+		if !ctx.Args().Present() {
+			return cli.NewExitError(fmt.Errorf("missing file argument or patch_file flag"), sysexits.Usage.Int())
+		}
+		for _, file := range ctx.Args() {
+			dir := ctx.String("dest_dir")
+			if dir == "" {
+				dir = filepath.Dir(file)
+			}
+			base := filepath.Base(file)
+			images = append(images, &waveImage{
+				Image: filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base))+format.Ext()),
+				Files: []string{file},
+			})
+		}
+	} else {
+		if ctx.Args().Present() {
+			return cli.NewExitError(fmt.Errorf("the tracks of a patch_file are found in src_dir, not given as arguments"), sysexits.Usage.Int())
+		}
+		flags, err := venueFlags(ctx)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.Usage.Int())
+		}
+		names, err := venueNames(flags)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.Software.Int())
+		}
+		images = renderSessions(flags, names, format)
+	}
+
+	for _, img := range images {
+		output.printf("  %q\n", img.Image)
+		if ctx.GlobalBool("dry_run") {
+			continue
+		}
+		if err := renderImage(img, format, width, height); err != nil {
+			return cli.NewExitError(fmt.Errorf("error rendering %q; %s", img.Image, err), sysexits.DataError.Int())
+		}
+	}
+	err = output.render(os.Stdout, images, func(w io.Writer) {
+		for _, img := range images {
+			silent := []string{}
+			for _, wf := range img.Waveforms {
+				if wf.Silent() {
+					silent = append(silent, wf.Label)
+				}
+			}
+			if len(silent) > 0 {
+				fmt.Fprintf(w, "%s: silent tracks: %s\n", img.Image, strings.Join(silent, ", "))
+			}
+		}
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// waveImage describes an image of the waveforms of files.
+type waveImage struct {
+	Image     string              `json:"image" yaml:"image"`
+	Files     []string            `json:"-" yaml:"-"`
+	Labels    []string            `json:"-" yaml:"-"` // Of the files, if known.
+	Waveforms []*actions.Waveform `json:"waveforms,omitempty" yaml:"waveforms,omitempty"`
+}
+
+// renderSessions returns an image of each session of the tracks, e.g.
+// "01 Overview.png", with the tracks in order and labeled with their names.
+func renderSessions(flags VenueFlags, names []VenueNames, format actions.ImageFormat) []*waveImage {
+	images := []*waveImage{}
+	bySession := map[int]*waveImage{}
+	for _, n := range names {
+		img, ok := bySession[n.snum]
+		if !ok {
+			img = &waveImage{Image: filepath.Join(flags.destDir, fmt.Sprintf("%02d Overview%s", n.snum, format.Ext()))}
+			bySession[n.snum] = img
+			images = append(images, img)
+		}
+		img.Files = append(img.Files, filepath.Join(flags.srcDir, n.orig))
+		img.Labels = append(img.Labels, fmt.Sprintf("%02d %s", n.tnum, n.name))
+	}
+	return images
+}
+
+// renderImage reads the waveforms of the files of the image, and writes it.
+func renderImage(img *waveImage, format actions.ImageFormat, width, height int) error {
+	img.Waveforms = []*actions.Waveform{}
+	for i, file := range img.Files {
+		wf, err := actions.ReadWaveform(file, width)
+		if err != nil {
+			return fmt.Errorf("error reading %q; %s", file, err)
+		}
+		if i < len(img.Labels) {
+			wf.Label = img.Labels[i]
+		} else {
+			wf.Label = filepath.Base(file)
+		}
+		img.Waveforms = append(img.Waveforms, wf)
+	}
+	f, err := os.Create(img.Image)
+	if err != nil {
+		return err
+	}
+	if err := actions.RenderWaveforms(f, format, img.Waveforms, width, height); err != nil {
+		f.Close()
+		os.Remove(img.Image)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/actions/wavetest"
+)
+
+func TestRenderSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	flags := VenueFlags{srcDir: dir, destDir: dir}
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", name: "Kick", snum: 1, tnum: 1},
+		{orig: "Track 02-1.wav", name: "Snare", snum: 1, tnum: 2},
+		{orig: "Track 01-2.wav", name: "Kick", snum: 2, tnum: 1},
+	}
+	for i, n := range names {
+		wave := wavetest.Mono(1, -1, 1000, -32768).Bytes()
+		if i == 1 {
+			wave = wavetest.Mono(0, 0, 0, 0).Bytes()
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, n.orig), wave, 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	images := renderSessions(flags, names, actions.PNGImage)
+	if got, want := len(images), 2; got != want {
+		t.Fatalf("renderSessions() returned %d images, want %d", got, want)
+	}
+	img := images[0]
+	if got, want := img.Image, filepath.Join(dir, "01 Overview.png"); got != want {
+		t.Errorf("renderSessions() image = %q, want %q", got, want)
+	}
+	if got, want := img.Labels, []string{"01 Kick", "02 Snare"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("renderSessions() labels = %q, want %q", got, want)
+	}
+
+	if err := renderImage(img, actions.PNGImage, 100, 20); err != nil {
+		t.Fatalf("renderImage() unexpected error %s", err)
+	}
+	if got, want := len(img.Waveforms), 2; got != want {
+		t.Fatalf("renderImage() read %d waveforms, want %d", got, want)
+	}
+	if img.Waveforms[0].Silent() || !img.Waveforms[1].Silent() {
+		t.Errorf("renderImage() silent = %t, %t; want false, true", img.Waveforms[0].Silent(), img.Waveforms[1].Silent())
+	}
+	f, err := os.Open(img.Image)
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("png.DecodeConfig() unexpected error %s", err)
+	}
+	if cfg.Width != 100 || cfg.Height != 40 {
+		t.Errorf("image size = %dx%d, want 100x40", cfg.Width, cfg.Height)
+	}
+
+	// A missing track leaves no image behind.
+	img = &waveImage{Image: filepath.Join(dir, "missing.png"), Files: []string{filepath.Join(dir, "missing.wav")}}
+	if err := renderImage(img, actions.PNGImage, 100, 20); err == nil {
+		t.Errorf("renderImage() expected error")
+	}
+	if _, err := os.Stat(img.Image); !os.IsNotExist(err) {
+		t.Errorf("renderImage() left %q behind", img.Image)
+	}
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/tracks"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:      "repair",
+		Usage:     "repair wave files left broken by a recorder crash",
+		ArgsUsage: "[file...]",
+		Category:  "wave",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "src_dir,s", Usage: "source directory, to repair all of its wave files"},
+			cli.IntFlag{Name: "session", Usage: "only repair the tracks of this session of the source directory"},
+			cli.StringFlag{Name: "dest_dir,d", Usage: "destination directory"},
+		},
+		Action: WaveRepairAction,
+	})
+}
+
+// waveRepair describes the repair of a single file.
+type waveRepair struct {
+	waveConversion `yaml:",inline"`
+	Repair         *actions.WaveRepair `json:"repair,omitempty" yaml:"repair,omitempty"`
+}
+
+// WaveRepairAction implements cli.ActionFunc. The RIFF and data chunk sizes of
+// each file are checked, and broken files are copied to the destination
+// directory with their sizes recomputed. Healthy files are left alone.
+func WaveRepairAction(ctx *cli.Context) error {
+	destDir := ctx.String("dest_dir")
+	if destDir == "" {
+		return cli.NewExitError(fmt.Errorf("missing %s flag", "dest_dir"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	files, err := repairFiles(ctx.Args(), ctx.String("src_dir"), ctx.Int("session"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	for _, file := range files {
+		if abs, _ := filepath.Abs(file); abs != "" {
+			if absDest, _ := filepath.Abs(filepath.Join(destDir, filepath.Base(file))); abs == absDest {
+				return cli.NewExitError(fmt.Errorf("%q would be repaired onto itself", file), sysexits.Usage.Int())
+			}
+		}
+	}
+
+	ops := []*waveRepair{}
+	for _, file := range files {
+		var r *actions.WaveRepair
+		if r, err = actions.DiagnoseWave(file); err != nil {
+			break
+		}
+		if !r.Broken() {
+			output.printf("  %q is ok\n", file)
+			continue
+		}
+		dest := filepath.Join(destDir, filepath.Base(file))
+		op := &waveRepair{waveConversion{Src: file, Dest: dest, Status: actions.OperationDryRun}, r}
+		ops = append(ops, op)
+		output.printf("  %q --> %q\n", file, dest)
+		for _, p := range r.Problems {
+			output.printf("    %s\n", p)
+		}
+		if ctx.GlobalBool("dry_run") {
+			continue
+		}
+		if err = r.Repair(dest); err != nil {
+			op.Status, op.Error = actions.OperationFailed, err.Error()
+			break
+		}
+		op.Status = actions.OperationDone
+	}
+	if rerr := output.render(os.Stdout, ops, nil); rerr != nil && err == nil {
+		return cli.NewExitError(rerr, sysexits.IOError.Int())
+	}
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	return nil
+}
+
+// repairFiles returns the files given, or else the wave files of the source
+// directory, limited to a session of Tracks Live recordings if not zero.
+func repairFiles(args []string, srcDir string, session int) ([]string, error) {
+	if len(args) > 0 {
+		if srcDir != "" || session != 0 {
+			return nil, fmt.Errorf("files given with the src_dir or session flag")
+		}
+		return args, nil
+	}
+	if srcDir == "" {
+		return nil, fmt.Errorf("missing file argument or %s flag", "src_dir")
+	}
+	if session != 0 {
+		sessions, err := srcDirSessions(srcDir, session)
+		if err != nil {
+			return nil, err
+		}
+		return sessionTrackFiles(srcDir, sessions[0]), nil
+	}
+	files, err := discoverFilesFn(srcDir, actions.FilterWaves)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		files[i] = filepath.Join(srcDir, file)
+	}
+	return files, nil
+}
+
+// srcDirSessions returns the sessions of the Tracks Live recordings of the
+// source directory, in order, limited to a session if not zero.
+func srcDirSessions(srcDir string, session int) ([]*tracks.Session, error) {
+	files, err := discoverFilesFn(srcDir, actions.FilterWaves)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := tracks.ExtractSessions(files)
+	if err != nil {
+		return nil, err
+	}
+	if session == 0 {
+		return sessions.Slice(), nil
+	}
+	s, ok := sessions[session]
+	if !ok {
+		return nil, fmt.Errorf("session %d not found in %q", session, srcDir)
+	}
+	return []*tracks.Session{s}, nil
+}
+
+// sessionTrackFiles returns the files of the tracks of a session, in order.
+func sessionTrackFiles(srcDir string, s *tracks.Session) []string {
+	files := []string{}
+	for _, t := range s.Tracks().Slice() {
+		files = append(files, filepath.Join(srcDir, t.Src()))
+	}
+	return files
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestRepairFiles(t *testing.T) {
+	discoverFilesFn = func(_ string, _ ...actions.Filter) ([]string, error) {
+		return []string{"Track 01-1.wav", "Track 02-1.wav", "Track 01-2.wav", "Track 02-2.wav"}, nil
+	}
+	defer resetDiscoverFiles()
+
+	for _, tt := range []struct {
+		desc    string
+		args    []string
+		srcDir  string
+		session int
+		want    []string
+		ok      bool
+	}{
+		{"files", []string{"a.wav", "b.wav"}, "", 0, []string{"a.wav", "b.wav"}, true},
+		{"files and src_dir", []string{"a.wav"}, "rec", 0, nil, false},
+		{"src_dir", nil, "rec", 0, []string{
+			filepath.Join("rec", "Track 01-1.wav"), filepath.Join("rec", "Track 02-1.wav"),
+			filepath.Join("rec", "Track 01-2.wav"), filepath.Join("rec", "Track 02-2.wav")}, true},
+		{"session", nil, "rec", 2, []string{
+			filepath.Join("rec", "Track 01-2.wav"), filepath.Join("rec", "Track 02-2.wav")}, true},
+		{"missing session", nil, "rec", 3, nil, false},
+		{"nothing", nil, "", 0, nil, false},
+	} {
+		got, err := repairFiles(tt.args, tt.srcDir, tt.session)
+		if err != nil && tt.ok {
+			t.Errorf("%s: repairFiles() unexpected error %s", tt.desc, err)
+			continue
+		}
+		if err == nil && !tt.ok {
+			t.Errorf("%s: repairFiles() expected error", tt.desc)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: repairFiles() = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
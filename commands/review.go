@@ -0,0 +1,359 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/tracks"
+	"github.com/urfave/cli"
+)
+
+const reviewHelp = `Commands:
+  list [session]      list the tracks, of all or one session
+  name ID NAME        rename a track, e.g. "name 01-23 Guest Vox"
+  exclude ID...       exclude a track, or include it again, with its pair
+  pair ID ID          mark two tracks as the left and right of a stereo pair
+  unpair ID           unmark a stereo pair
+  copy, link, move    act on the included tracks, and quit
+  quit                quit without acting
+  help                show this help
+A track ID is "session-track" (e.g. "01-23"), or just the track number if
+there is only one session.
+`
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:     "review",
+		Usage:    "interactively review and edit the new track names, then act",
+		Category: "venue",
+		Flags:    flagLists(venueFlagList, []cli.Flag{linkModeFlag}),
+		Action:   VenueReviewAction,
+		After:    VenueDryRunAction,
+	})
+}
+
+// VenueReviewAction implements cli.ActionFunc.
+func VenueReviewAction(ctx *cli.Context) error {
+	flags, err := venueFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	names, err := venueNames(flags)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Software.Int())
+	}
+
+	// Keep standard output for the result, unless it is text.
+	out := os.Stdout
+	if flags.output != textOutput {
+		out = os.Stderr
+	}
+	r := newReview(flags, names, os.Stdin, out)
+	op, names, err := r.run()
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	if op == "" {
+		return nil
+	}
+	return venueRun(flags, op, names)
+}
+
+// reviewEntry holds the review state of a single track.
+type reviewEntry struct {
+	VenueNames
+	excluded bool
+	pair     string // "L" or "R" if part of a stereo pair.
+	pairNum  int    // Track number shared by both files of a stereo pair.
+	// The name and source before pairing, restored on unpairing, unless the
+	// pair was renamed since.
+	unpairedName   string
+	unpairedSource tracks.NameSource
+}
+
+// review is an interactive, line oriented, review of track names.
+type review struct {
+	flags   VenueFlags
+	entries []*reviewEntry
+	in      *bufio.Scanner
+	out     io.Writer
+}
+
+func newReview(flags VenueFlags, names []VenueNames, in io.Reader, out io.Writer) *review {
+	r := &review{flags: flags, in: bufio.NewScanner(in), out: out}
+	for _, name := range names {
+		r.entries = append(r.entries, &reviewEntry{VenueNames: name})
+	}
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		a, b := r.entries[i], r.entries[j]
+		return a.snum < b.snum || (a.snum == b.snum && a.tnum < b.tnum)
+	})
+	return r
+}
+
+// run the review until the user chooses an operation or quits. It returns the
+// chosen operation, or "" if none, and the names of the included tracks.
+func (r *review) run() (string, []VenueNames, error) {
+	r.list(0)
+	fmt.Fprint(r.out, "Type \"help\" for a list of commands.\n")
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return "", nil, r.in.Err()
+		}
+		args := strings.Fields(r.in.Text())
+		if len(args) == 0 {
+			continue
+		}
+
+		var err error
+		switch cmd := args[0]; cmd {
+		case "list", "l":
+			err = r.cmdList(args[1:])
+		case "name", "n":
+			err = r.cmdName(args[1:])
+		case "exclude", "x":
+			err = r.cmdExclude(args[1:])
+		case "pair", "p":
+			err = r.cmdPair(args[1:])
+		case "unpair", "u":
+			err = r.cmdUnpair(args[1:])
+		case "copy", "link", "move":
+			return cmd, r.names(), nil
+		case "quit", "q":
+			return "", nil, nil
+		case "help", "h", "?":
+			fmt.Fprint(r.out, reviewHelp)
+		default:
+			err = fmt.Errorf("unknown command %q", cmd)
+		}
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %s\n", err)
+		}
+	}
+}
+
+// names returns the names of the included tracks.
+func (r *review) names() []VenueNames {
+	names := []VenueNames{}
+	for _, e := range r.entries {
+		if !e.excluded {
+			names = append(names, e.VenueNames)
+		}
+	}
+	return names
+}
+
+// list the tracks of a session, or all sessions if snum is 0.
+func (r *review) list(snum int) {
+	w := tabwriter.NewWriter(r.out, 0, 8, 2, ' ', 0)
+	last := 0
+	for _, e := range r.entries {
+		if snum != 0 && e.snum != snum {
+			continue
+		}
+		if e.snum != last {
+			fmt.Fprintf(w, "Session %d\n", e.snum)
+			fmt.Fprintf(w, "  ID\tSOURCE FILE\tCHANNEL\tNAME SOURCE\tDESTINATION\n")
+			last = e.snum
+		}
+		mark := " "
+		if e.excluded {
+			mark = "x"
+		}
+		fmt.Fprintf(w, "%s %02d-%02d\t%s\t%s\t%s\t%s\n",
+			mark, e.snum, e.tnum, e.orig, e.channel, e.source, e.dest)
+	}
+	w.Flush()
+}
+
+func (r *review) cmdList(args []string) error {
+	if len(args) == 0 {
+		r.list(0)
+		return nil
+	}
+	snum, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid session %q", args[0])
+	}
+	r.list(snum)
+	return nil
+}
+
+func (r *review) cmdName(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: name ID NAME")
+	}
+	e, err := r.entry(args[0])
+	if err != nil {
+		return err
+	}
+	e.name = strings.Join(args[1:], " ")
+	e.source = tracks.NameFromOverride
+	r.setDest(e)
+	if e.pair != "" {
+		// Keep both files of a stereo pair in sync.
+		other := r.pairOf(e)
+		other.name, other.source = e.name, e.source
+		r.setDest(other)
+		for _, p := range []*reviewEntry{e, other} {
+			p.unpairedName, p.unpairedSource = p.name, p.source
+		}
+	}
+	fmt.Fprintf(r.out, "%02d-%02d --> %q\n", e.snum, e.tnum, e.dest)
+	return nil
+}
+
+func (r *review) cmdExclude(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: exclude ID...")
+	}
+	es := []*reviewEntry{}
+	seen := map[*reviewEntry]bool{}
+	for _, id := range args {
+		e, err := r.entry(id)
+		if err != nil {
+			return err
+		}
+		// Keep both files of a stereo pair in sync.
+		for _, p := range []*reviewEntry{e, r.pairOf(e)} {
+			if !seen[p] {
+				seen[p] = true
+				es = append(es, p)
+			}
+		}
+	}
+	for _, e := range es {
+		e.excluded = !e.excluded
+		state := "included"
+		if e.excluded {
+			state = "excluded"
+		}
+		fmt.Fprintf(r.out, "%02d-%02d %s\n", e.snum, e.tnum, state)
+	}
+	return nil
+}
+
+func (r *review) cmdPair(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pair ID ID")
+	}
+	l, err := r.entry(args[0])
+	if err != nil {
+		return err
+	}
+	rt, err := r.entry(args[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case l == rt:
+		return fmt.Errorf("cannot pair a track with itself")
+	case l.snum != rt.snum:
+		return fmt.Errorf("cannot pair tracks of different sessions")
+	case l.pair != "" || rt.pair != "":
+		return fmt.Errorf("track already paired")
+	case l.excluded != rt.excluded:
+		return fmt.Errorf("cannot pair an excluded track with an included one")
+	}
+	l.pair, rt.pair = "L", "R"
+	l.pairNum, rt.pairNum = l.tnum, l.tnum
+	for _, p := range []*reviewEntry{l, rt} {
+		p.unpairedName, p.unpairedSource = p.name, p.source
+	}
+	rt.name, rt.source = l.name, l.source
+	r.setDest(l)
+	r.setDest(rt)
+	fmt.Fprintf(r.out, "%02d-%02d --> %q\n%02d-%02d --> %q\n", l.snum, l.tnum, l.dest, rt.snum, rt.tnum, rt.dest)
+	return nil
+}
+
+func (r *review) cmdUnpair(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: unpair ID")
+	}
+	e, err := r.entry(args[0])
+	if err != nil {
+		return err
+	}
+	if e.pair == "" {
+		return fmt.Errorf("track not paired")
+	}
+	for _, p := range []*reviewEntry{r.pairOf(e), e} {
+		p.pair, p.pairNum = "", 0
+		p.name, p.source = p.unpairedName, p.unpairedSource
+		r.setDest(p)
+		fmt.Fprintf(r.out, "%02d-%02d --> %q\n", p.snum, p.tnum, p.dest)
+	}
+	return nil
+}
+
+// pairOf returns the other track of a stereo pair.
+func (r *review) pairOf(e *reviewEntry) *reviewEntry {
+	for _, o := range r.entries {
+		if o != e && o.snum == e.snum && o.pairNum == e.pairNum && o.pair != "" {
+			return o
+		}
+	}
+	return e
+}
+
+// setDest updates the destination filename after a change. Stereo pairs are
+// named so that DAWs such as Pro Tools import them as a single stereo track
+// (e.g. "01-17 Keys.L.wav" and "01-17 Keys.R.wav").
+func (r *review) setDest(e *reviewEntry) {
+	prefix, ext := fmt.Sprintf("%02d-%02d ", e.snum, e.tnum), ".wav"
+	if e.pair != "" {
+		prefix, ext = fmt.Sprintf("%02d-%02d ", e.snum, e.pairNum), "."+e.pair+ext
+	}
+	e.dest = r.flags.policy.Filename(prefix, e.name, ext, r.flags.maxLength)
+}
+
+// entry returns the entry with the given ID.
+func (r *review) entry(id string) (*reviewEntry, error) {
+	snums := map[int]bool{}
+	for _, e := range r.entries {
+		snums[e.snum] = true
+	}
+
+	var snum, tnum int
+	parts := strings.SplitN(id, "-", 2)
+	switch len(parts) {
+	case 1:
+		if len(snums) != 1 {
+			return nil, fmt.Errorf("track %q is ambiguous; use session-track", id)
+		}
+		for s := range snums {
+			snum = s
+		}
+		t, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid track %q", id)
+		}
+		tnum = t
+	case 2:
+		s, err1 := strconv.Atoi(parts[0])
+		t, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid track %q", id)
+		}
+		snum, tnum = s, t
+	}
+
+	i := sort.Search(len(r.entries), func(i int) bool {
+		e := r.entries[i]
+		return e.snum > snum || (e.snum == snum && e.tnum >= tnum)
+	})
+	if i == len(r.entries) || r.entries[i].snum != snum || r.entries[i].tnum != tnum {
+		return nil, fmt.Errorf("track %q not found", id)
+	}
+	return r.entries[i], nil
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kward/tracks/tracks"
+)
+
+func TestReview(t *testing.T) {
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", dest: "01-01 Kick.wav", name: "Kick", snum: 1, tnum: 1, source: tracks.NameFromChannel},
+		{orig: "Track 02-1.wav", dest: "01-02 Ch 2.wav", name: "Ch 2", snum: 1, tnum: 2, source: tracks.NameFromChannel},
+		{orig: "Track 03-1.wav", dest: "01-03 Keys.wav", name: "Keys", snum: 1, tnum: 3, source: tracks.NameFromChannel},
+		{orig: "Track 04-1.wav", dest: "01-04 Keys.wav", name: "Keys", snum: 1, tnum: 4, source: tracks.NameFromChannel},
+		{orig: "Track 05-1.wav", dest: "01-05 Track 05.wav", name: "Track 05", snum: 1, tnum: 5, source: tracks.NameFromNumber},
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		input string
+		op    string
+		dests []string
+	}{
+		{"quit", "quit\n", "", nil},
+		{"eof", "", "", nil},
+		{"unchanged", "copy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-03 Keys.wav", "01-04 Keys.wav", "01-05 Track 05.wav"}},
+		{"edit", "name 01-02 Snare top\nexclude 5\npair 3 4\nname 4 Piano\nmove\n", "move",
+			[]string{"01-01 Kick.wav", "01-02 Snare top.wav", "01-03 Piano.L.wav", "01-03 Piano.R.wav"}},
+		{"unpair", "pair 2 3\nunpair 3\nlink\n", "link",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-03 Keys.wav", "01-04 Keys.wav", "01-05 Track 05.wav"}},
+		{"unpair renamed", "pair 2 3\nname 2 Gtr\nunpair 3\nlink\n", "link",
+			[]string{"01-01 Kick.wav", "01-02 Gtr.wav", "01-03 Gtr.wav", "01-04 Keys.wav", "01-05 Track 05.wav"}},
+		{"exclude pair", "pair 3 4\nexclude 4\ncopy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-05 Track 05.wav"}},
+		{"exclude both of pair", "pair 3 4\nexclude 3 4\ncopy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-05 Track 05.wav"}},
+		{"include pair", "pair 3 4\nx 3\nx 4\ncopy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-03 Keys.L.wav", "01-03 Keys.R.wav", "01-05 Track 05.wav"}},
+		{"pair excluded", "x 4\npair 3 4\ncopy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-03 Keys.wav", "01-05 Track 05.wav"}},
+		{"errors", "bogus\nname 9 X\npair 1 1\nexclude\nlist 1\nhelp\nx 5\nx 5\ncopy\n", "copy",
+			[]string{"01-01 Kick.wav", "01-02 Ch 2.wav", "01-03 Keys.wav", "01-04 Keys.wav", "01-05 Track 05.wav"}},
+	} {
+		var out bytes.Buffer
+		r := newReview(VenueFlags{}, names, strings.NewReader(tt.input), &out)
+		op, got, err := r.run()
+		if err != nil {
+			t.Errorf("%s: run() unexpected error; %s", tt.desc, err)
+			continue
+		}
+		if op != tt.op {
+			t.Errorf("%s: run() op = %q, want %q", tt.desc, op, tt.op)
+		}
+		dests := []string{}
+		for _, name := range got {
+			dests = append(dests, name.dest)
+		}
+		if strings.Join(dests, "|") != strings.Join(tt.dests, "|") {
+			t.Errorf("%s: run() dests = %q, want %q", tt.desc, dests, tt.dests)
+		}
+	}
+}
+
+func TestReviewPairSource(t *testing.T) {
+	r := newReview(VenueFlags{}, []VenueNames{
+		{name: "Keys", snum: 1, tnum: 1, source: tracks.NameFromChannel},
+		{name: "Track 02", snum: 1, tnum: 2, source: tracks.NameFromNumber},
+	}, strings.NewReader(""), &bytes.Buffer{})
+	rt := r.entries[1]
+	if err := r.cmdPair([]string{"1", "2"}); err != nil {
+		t.Fatalf("cmdPair() unexpected error; %s", err)
+	}
+	if rt.name != "Keys" || rt.source != tracks.NameFromChannel {
+		t.Errorf("cmdPair() right = %q (%s), want %q (%s)", rt.name, rt.source, "Keys", tracks.NameFromChannel)
+	}
+	if err := r.cmdUnpair([]string{"2"}); err != nil {
+		t.Fatalf("cmdUnpair() unexpected error; %s", err)
+	}
+	if rt.name != "Track 02" || rt.source != tracks.NameFromNumber {
+		t.Errorf("cmdUnpair() right = %q (%s), want %q (%s)", rt.name, rt.source, "Track 02", tracks.NameFromNumber)
+	}
+}
+
+func TestReviewEntryAmbiguous(t *testing.T) {
+	r := newReview(VenueFlags{}, []VenueNames{
+		{snum: 1, tnum: 1},
+		{snum: 2, tnum: 1},
+	}, strings.NewReader(""), &bytes.Buffer{})
+	if _, err := r.entry("1"); err == nil {
+		t.Errorf("entry(1) expected error")
+	}
+	e, err := r.entry("02-01")
+	if err != nil {
+		t.Fatalf("entry(02-01) unexpected error; %s", err)
+	}
+	if e.snum != 2 || e.tnum != 1 {
+		t.Errorf("entry(02-01) = %02d-%02d", e.snum, e.tnum)
+	}
+}
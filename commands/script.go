@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:     "script",
+		Usage:    "write a rename script, and its undo script, to run where tracks can't",
+		Category: "venue",
+		Flags: flagLists([]cli.Flag{
+			cli.StringFlag{
+				Name:  "action,a",
+				Value: "move",
+				Usage: "action of the script (copy, link, move)",
+			},
+			cli.StringFlag{
+				Name:  "shell",
+				Value: actions.POSIXShell.String(),
+				Usage: "shell of the script (sh, powershell, batch)",
+			},
+			cli.StringFlag{
+				Name:  "script_file,f",
+				Usage: "script file to write (default: rename.sh, rename.ps1 or rename.bat)",
+			},
+			cli.StringFlag{
+				Name:  "undo_file",
+				Usage: "undo script file to write (default: undo.sh, undo.ps1 or undo.bat)",
+			},
+			cli.StringFlag{
+				Name:  "file_list",
+				Usage: "file listing, one file per line, to use instead of reading src_dir (- for standard input)",
+			},
+		}, venueFlagList),
+		Action: VenueScriptAction,
+		After:  VenueDryRunAction,
+	})
+}
+
+// VenueScriptAction implements cli.ActionFunc.
+func VenueScriptAction(ctx *cli.Context) error {
+	flags, err := venueFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	action, err := actions.ParseScriptAction(ctx.String("action"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	shell, err := actions.ParseScriptShell(ctx.String("shell"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	scriptFile, undoFile := ctx.String("script_file"), ctx.String("undo_file")
+	if scriptFile == "" {
+		scriptFile = "rename" + shell.Ext()
+	}
+	if undoFile == "" {
+		undoFile = "undo" + shell.Ext()
+	}
+
+	if list := ctx.String("file_list"); list != "" {
+		if flags.files, err = venueFileList(list); err != nil {
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+	}
+	names, err := venueNames(flags)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Software.Int())
+	}
+
+	s := venueScript(flags, action, shell, names)
+	if err := s.Check(); err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	if flags.dryRun {
+		if err := s.Write(os.Stdout); err != nil {
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+		return nil
+	}
+	for _, f := range []struct {
+		file  string
+		write func(io.Writer) error
+	}{
+		{scriptFile, s.Write},
+		{undoFile, s.WriteUndo},
+	} {
+		if err := writeScript(f.file, f.write); err != nil {
+			return cli.NewExitError(fmt.Errorf("error writing script %q; %s", f.file, err), sysexits.IOError.Int())
+		}
+	}
+
+	err = flags.output.render(os.Stdout, struct {
+		Script string `json:"script" yaml:"script"`
+		Undo   string `json:"undo" yaml:"undo"`
+		Shell  string `json:"shell" yaml:"shell"`
+		Action string `json:"action" yaml:"action"`
+		Files  int    `json:"files" yaml:"files"`
+	}{scriptFile, undoFile, shell.String(), action.String(), len(s.Renames)}, func(w io.Writer) {
+		fmt.Fprintf(w, "Wrote %q to %s %d files, and %q to undo it.\n", scriptFile, action, len(s.Renames), undoFile)
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// venueFileList returns the wave files of a file listing.
+func venueFileList(file string) ([]string, error) {
+	r := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file listing; %s", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	files, err := actions.ReadFileList(r, actions.FilterWaves)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file listing %q; %s", file, err)
+	}
+	return files, nil
+}
+
+// venueScript returns the script performing the action on all names.
+func venueScript(flags VenueFlags, action actions.ScriptAction, shell actions.ScriptShell, names []VenueNames) *actions.Script {
+	s := &actions.Script{
+		Shell:   shell,
+		Action:  action,
+		SrcDir:  flags.srcDir,
+		DestDir: flags.destDir,
+		Renames: []actions.Rename{},
+	}
+	for _, name := range names {
+		s.Renames = append(s.Renames, actions.Rename{Src: name.orig, Dest: name.dest})
+	}
+	return s
+}
+
+// writeScript writes an executable script file.
+func writeScript(file string, write func(io.Writer) error) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestVenueScript(t *testing.T) {
+	setup()
+	dir, err := ioutil.TempDir("", "script_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A listing of the recordings, taken on another computer.
+	list := filepath.Join(dir, "files.txt")
+	data := "D:\\Rec\\Track 02-1.wav\r\nD:\\Rec\\Track 02-1.mp3\r\nD:\\Rec\\Track 03-1.wav\r\n"
+	if err := ioutil.WriteFile(list, []byte(data), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	files, err := venueFileList(list)
+	if err != nil {
+		t.Fatalf("venueFileList() unexpected error %s", err)
+	}
+	flags := VenueFlags{
+		patchFile: "../testdata/20170906 ICF Ladies Night.html",
+		srcDir:    `D:\Rec`,
+		destDir:   `D:\Rec`,
+		files:     files,
+		fallbacks: []string{"original"},
+		policy:    actions.Portable,
+		maxLength: actions.DefaultMaxFilenameLength,
+	}
+	names, err := venueNames(flags)
+	if err != nil {
+		t.Fatalf("venueNames() unexpected error %s", err)
+	}
+
+	s := venueScript(flags, actions.ScriptMove, actions.PowerShell, names)
+	if got, want := s.Renames, []actions.Rename{
+		{Src: "Track 02-1.wav", Dest: names[0].dest},
+		{Src: "Track 03-1.wav", Dest: names[1].dest},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("venueScript() renames = %q, want %q", got, want)
+	}
+	if got, want := names[0].dest, "01-02 Kick 91, iKick.wav"; got != want {
+		t.Errorf("venueNames() dest = %q, want %q", got, want)
+	}
+	if err := s.Check(); err != nil {
+		t.Errorf("Check() unexpected error %s", err)
+	}
+}
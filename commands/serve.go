@@ -0,0 +1,523 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/venue"
+	"github.com/urfave/cli"
+)
+
+// maxPatchSize is the largest patch file accepted for upload.
+const maxPatchSize = 32 << 20
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:     "serve",
+		Usage:    "serve a local web interface to preview and act on the new track names",
+		Category: "venue",
+		Flags: flagLists([]cli.Flag{
+			cli.StringFlag{
+				Name:  "listen",
+				Value: "localhost:8080",
+				Usage: "address to listen on; keep it local, as the interface acts on local files",
+			},
+		}, venueFlagList, copyFlagList, convertFlagList, manifestFlagList, []cli.Flag{linkModeFlag}),
+		Action: ServeAction,
+	})
+}
+
+// ServeAction implements cli.ActionFunc.
+func ServeAction(ctx *cli.Context) error {
+	// The patch may be uploaded instead.
+	if !ctx.IsSet("patch_file") {
+		ctx.Set("patch_file", "")
+	}
+	flags, err := venueFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	s, err := newServer(flags)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Software.Int())
+	}
+	l, err := net.Listen("tcp", ctx.String("listen"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	fmt.Printf("Serving on http://%s/\n", l.Addr())
+	if err := http.Serve(l, s); err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// server serves the web interface. It runs a single job at a time.
+type server struct {
+	flags VenueFlags // The defaults.
+	token string     // Required by API requests, against cross-site requests.
+	mux   *http.ServeMux
+
+	mu  sync.Mutex
+	job *serveJob
+}
+
+// serveJob is the progress of a copy, link or move.
+type serveJob struct {
+	Action     string              `json:"action"`
+	DryRun     bool                `json:"dry_run"`
+	Total      int                 `json:"total"`
+	Operations []actions.Operation `json:"operations"`
+	Manifests  []string            `json:"manifests,omitempty"`
+	Done       bool                `json:"done"`
+	Error      string              `json:"error,omitempty"`
+}
+
+func newServer(flags VenueFlags) (*server, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating token; %s", err)
+	}
+	s := &server{flags: flags, token: hex.EncodeToString(b), mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/api/plan", s.api(s.handlePlan))
+	s.mux.HandleFunc("/api/names", s.api(s.handleNames))
+	s.mux.HandleFunc("/api/run", s.api(s.handleRun))
+	s.mux.HandleFunc("/api/job", s.api(s.handleJob))
+	return s, nil
+}
+
+// ServeHTTP implements the http.Handler interface. Only requests for a local
+// host are served, against DNS rebinding.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	switch host {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		http.Error(w, "forbidden host", http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// api wraps an API handler, checking the token and encoding its result.
+func (s *server) api(fn func(r *http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tracks-Token") != s.token {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		v, code, err := fn(r)
+		if err != nil {
+			v = struct {
+				Error string `json:"error"`
+			}{err.Error()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	serveIndex.Execute(w, struct {
+		Token, SrcDir, DestDir, PatchFile string
+	}{s.token, s.flags.srcDir, s.flags.destDir, s.flags.patchFile})
+}
+
+// handlePlan plans the names of the tracks in the source directory, with the
+// uploaded patch, or else the patch file.
+func (s *server) handlePlan(r *http.Request) (interface{}, int, error) {
+	if r.Method != "POST" {
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	if err := r.ParseMultipartForm(maxPatchSize); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	flags := s.formFlags(r)
+
+	var data []byte
+	f, _, err := r.FormFile("patch")
+	switch {
+	case err == nil:
+		data, err = ioutil.ReadAll(f)
+		f.Close()
+	case flags.patchFile != "":
+		data, err = ioutil.ReadFile(flags.patchFile)
+	default:
+		err = fmt.Errorf("missing patch")
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("error reading Venue patch file; %s", err)
+	}
+	v := venue.NewVenue()
+	if err := v.Parse(data); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("error parsing the Venue data; %s", err)
+	}
+
+	files, err := discoverFilesFn(flags.srcDir, actions.FilterWaves)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("error discovering wave files; %s", err)
+	}
+	p, err := venuePlanner(flags, v.Devices())
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	plan, err := p.Plan(files)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	plan.SrcDir, plan.DestDir = flags.srcDir, flags.destDir
+
+	unused := []string{}
+	for _, o := range plan.Unused {
+		unused = append(unused, o.String())
+	}
+	return struct {
+		Devices []*venue.Device `json:"devices"`
+		Plan    *actions.Plan   `json:"plan"`
+		Unused  []string        `json:"unused_overrides"`
+	}{v.Devices().Sorted(), plan, unused}, http.StatusOK, nil
+}
+
+// formFlags returns the flags, with the files and directories of the form.
+func (s *server) formFlags(r *http.Request) VenueFlags {
+	flags := s.flags
+	if file := strings.TrimSpace(r.FormValue("patch_file")); file != "" {
+		flags.patchFile = file
+	}
+	if dir := strings.TrimSpace(r.FormValue("src_dir")); dir != "" {
+		flags.srcDir = dir
+		flags.destDir = dir
+	}
+	if dir := strings.TrimSpace(r.FormValue("dest_dir")); dir != "" {
+		flags.destDir = dir
+	}
+	return flags
+}
+
+// readPlan reads a plan edited in the web interface. The file names follow
+// the edited track names.
+func (s *server) readPlan(r *http.Request) (*actions.Plan, error) {
+	if r.Method != "POST" {
+		return nil, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	plan := &actions.Plan{}
+	if err := json.NewDecoder(r.Body).Decode(plan); err != nil {
+		return nil, err
+	}
+	p := actions.NewPlanner(nil)
+	p.Policy, p.MaxLength = s.flags.policy, s.flags.maxLength
+	if s.flags.convert != nil {
+		p.Ext = s.flags.convert.Encoding.Ext()
+	}
+	dests := map[string]int{}
+	for _, e := range plan.Entries {
+		if e.Src == "" || strings.TrimSpace(e.Name) == "" {
+			return nil, fmt.Errorf("track %02d-%02d has no name", e.Session, e.Track)
+		}
+		e.Dest = p.Filename(e.Session, e.Track, e.Name)
+		dests[strings.ToLower(e.Dest)]++
+	}
+	for _, e := range plan.Entries {
+		if dests[strings.ToLower(e.Dest)] > 1 {
+			return nil, fmt.Errorf("several tracks are named %q", e.Dest)
+		}
+	}
+	return plan, nil
+}
+
+// handleNames returns the plan, with the file names of the edited names.
+func (s *server) handleNames(r *http.Request) (interface{}, int, error) {
+	plan, err := s.readPlan(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return plan, http.StatusOK, nil
+}
+
+// handleRun starts the copy, link or move of a plan. The tracks are copied,
+// and their manifests written, as by the command line.
+func (s *server) handleRun(r *http.Request) (interface{}, int, error) {
+	plan, err := s.readPlan(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if _, ok := venueOps[plan.Action]; !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown action %q", plan.Action)
+	}
+	flags := s.flags
+	flags.dryRun = flags.dryRun || r.URL.Query().Get("dry_run") == "true"
+	if plan.SrcDir != "" {
+		flags.srcDir = plan.SrcDir
+	}
+	if plan.DestDir != "" {
+		flags.destDir = plan.DestDir
+	}
+	if flags.manifest {
+		if err := manifestDirs(flags.srcDir, flags.destDir); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	fn := venueOps[plan.Action].fn
+	jobs := 1
+	switch plan.Action {
+	case "copy":
+		c, err := venueCopier(flags)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		fn = c.Op
+		if flags.convert != nil {
+			jobs = flags.jobs
+		}
+	case "link":
+		fn = actions.LinkOp(flags.linkMode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.job != nil && !s.job.Done {
+		return nil, http.StatusConflict, fmt.Errorf("a %s is already running", s.job.Action)
+	}
+	job := &serveJob{Action: plan.Action, DryRun: flags.dryRun, Total: len(plan.Entries), Operations: []actions.Operation{}}
+	s.job = job
+
+	x := actions.NewExecutor(fn)
+	x.DryRun, x.Jobs = flags.dryRun, jobs
+	idx := map[*actions.Operation]int{} // Of each operation in the job.
+	x.Progress = func(op *actions.Operation) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if op.Status == "" {
+			idx[op] = len(job.Operations)
+			job.Operations = append(job.Operations, *op)
+			return
+		}
+		job.Operations[idx[op]] = *op
+	}
+	go func() {
+		_, err := x.Execute(plan)
+		var files []string
+		if err == nil && flags.manifest && !flags.dryRun {
+			files, err = venueManifest(flags, venueDestDirs(flags, plan.Action), venuePlanNames(plan))
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.Manifests = files
+		job.Done = true
+		if err != nil {
+			job.Error = err.Error()
+		}
+	}()
+	return job.snapshot(), http.StatusAccepted, nil
+}
+
+// handleJob returns the progress of the last job, if any.
+func (s *server) handleJob(r *http.Request) (interface{}, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.job == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("no job")
+	}
+	return s.job.snapshot(), http.StatusOK, nil
+}
+
+// snapshot returns a copy of the job, for encoding while the job runs.
+func (j *serveJob) snapshot() serveJob {
+	c := *j
+	c.Operations = append([]actions.Operation{}, j.Operations...)
+	return c
+}
+
+var serveIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>tracks</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+#drop { border: 2px dashed #999; padding: 1em; margin: 1em 0; }
+#drop.over { background: #eef; }
+label { display: block; margin: .5em 0; }
+input[type=text] { width: 40em; }
+table { border-collapse: collapse; margin: 1em 0; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+.error { color: #c00; }
+.failed { color: #c00; }
+.done { color: #080; }
+</style>
+</head>
+<body>
+<h1>tracks</h1>
+<form id="form">
+  <div id="drop">Drop a Venue patch export here, or choose one: <input type="file" id="patch" accept=".html,.htm,.txt"></div>
+  <label>Patch file (if not uploaded) <input type="text" id="patch_file" value="{{.PatchFile}}"></label>
+  <label>Recording folder <input type="text" id="src_dir" value="{{.SrcDir}}"></label>
+  <label>Destination folder <input type="text" id="dest_dir" value="{{.DestDir}}"></label>
+  <button type="submit">Preview</button>
+</form>
+<p id="message" class="error"></p>
+<div id="devices"></div>
+<div id="sessions"></div>
+<div id="actions" hidden>
+  <select id="action"><option>copy</option><option>link</option><option>move</option></select>
+  <label style="display: inline"><input type="checkbox" id="dry_run"> dry run</label>
+  <button id="run">Run</button>
+</div>
+<p id="progress"></p>
+<table id="operations"></table>
+<script>
+var token = {{.Token}};
+var plan = null;
+var patch = null;
+
+function $(id) { return document.getElementById(id); }
+function message(text) { $("message").textContent = text || ""; }
+
+function api(path, body) {
+  var opts = {method: body === undefined ? "GET" : "POST", headers: {"X-Tracks-Token": token}};
+  if (body instanceof FormData) {
+    opts.body = body;
+  } else if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  return fetch(path, opts).then(function(r) {
+    return r.json().then(function(v) {
+      if (!r.ok) { throw new Error(v.error); }
+      return v;
+    });
+  });
+}
+
+function row(table, cells, header) {
+  var tr = table.insertRow();
+  cells.forEach(function(c) {
+    var td = document.createElement(header ? "th" : "td");
+    if (c instanceof Node) { td.appendChild(c); } else { td.textContent = c; }
+    tr.appendChild(td);
+  });
+  return tr;
+}
+
+function pad(n) { return ("0" + n).slice(-2); }
+
+function showDevices(devices) {
+  var div = $("devices");
+  div.textContent = "";
+  devices.forEach(function(d) {
+    var h = document.createElement("h3");
+    h.textContent = d.name + " (" + d.hardware + ")";
+    var t = document.createElement("table");
+    row(t, ["Input", "Name"], true);
+    d.inputs.forEach(function(ch) { row(t, [ch.moniker, ch.name]); });
+    div.appendChild(h);
+    div.appendChild(t);
+  });
+}
+
+function showPlan() {
+  var div = $("sessions");
+  div.textContent = "";
+  var tables = {};
+  plan.entries.forEach(function(e, i) {
+    var t = tables[e.session];
+    if (!t) {
+      var h = document.createElement("h3");
+      h.textContent = "Session " + pad(e.session);
+      t = tables[e.session] = document.createElement("table");
+      row(t, ["Track", "File", "Channel", "Source", "Name", "New file"], true);
+      div.appendChild(h);
+      div.appendChild(t);
+    }
+    var name = document.createElement("input");
+    name.type = "text";
+    name.value = e.name;
+    name.onchange = function() {
+      plan.entries[i].name = name.value;
+      rename();
+    };
+    var dest = document.createElement("span");
+    dest.id = "dest" + i;
+    dest.textContent = e.dest;
+    row(t, [pad(e.track), e.src, e.channel || "", e.name_source, name, dest]);
+  });
+  $("actions").hidden = false;
+}
+
+function rename() {
+  api("/api/names", plan).then(function(p) {
+    message();
+    p.entries.forEach(function(e, i) { $("dest" + i).textContent = e.dest; });
+  }).catch(function(err) { message(err.message); });
+}
+
+function preview() {
+  var form = new FormData();
+  if (patch) { form.append("patch", patch); }
+  form.append("patch_file", $("patch_file").value);
+  form.append("src_dir", $("src_dir").value);
+  form.append("dest_dir", $("dest_dir").value);
+  api("/api/plan", form).then(function(v) {
+    message(v.unused_overrides.length ? "Unused overrides: " + v.unused_overrides.join(", ") : "");
+    plan = v.plan;
+    showDevices(v.devices);
+    showPlan();
+  }).catch(function(err) { message(err.message); });
+}
+
+function poll() {
+  api("/api/job").then(function(job) {
+    var done = job.operations.filter(function(op) { return op.status && op.status !== "failed"; }).length;
+    $("progress").textContent = job.action + (job.dry_run ? " (dry run)" : "") + ": " + done + " of " + job.total +
+      (job.done ? (job.error ? ", failed: " + job.error : ", done") : "");
+    var t = $("operations");
+    t.textContent = "";
+    job.operations.forEach(function(op) {
+      row(t, [op.src, op.dest, op.status || "..."]).className = op.status || "";
+    });
+    if (!job.done) { setTimeout(poll, 500); }
+  }).catch(function(err) { message(err.message); });
+}
+
+$("form").onsubmit = function(ev) { ev.preventDefault(); preview(); };
+$("patch").onchange = function() { patch = this.files[0]; preview(); };
+$("drop").ondragover = function(ev) { ev.preventDefault(); this.className = "over"; };
+$("drop").ondragleave = function() { this.className = ""; };
+$("drop").ondrop = function(ev) {
+  ev.preventDefault();
+  this.className = "";
+  patch = ev.dataTransfer.files[0];
+  preview();
+};
+$("run").onclick = function() {
+  plan.action = $("action").value;
+  api("/api/run" + ($("dry_run").checked ? "?dry_run=true" : ""), plan).then(function() {
+    message();
+    poll();
+  }).catch(function(err) { message(err.message); });
+};
+</script>
+</body>
+</html>
+`))
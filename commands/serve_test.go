@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestServe(t *testing.T) {
+	setup()
+	dir, err := ioutil.TempDir("", "serve_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"Track 02-1.wav", "Track 03-1.wav"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	patch, err := ioutil.ReadFile("../testdata/20170906 ICF Ladies Night.html")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	s, err := newServer(VenueFlags{
+		srcDir:    dir,
+		destDir:   dir,
+		fallbacks: []string{"original"},
+		policy:    actions.Portable,
+		maxLength: actions.DefaultMaxFilenameLength,
+		linkMode:  actions.HardLink,
+	})
+	if err != nil {
+		t.Fatalf("newServer() unexpected error %s", err)
+	}
+	request := func(method, path, host string, token bool, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+		if body == nil {
+			body = &bytes.Buffer{}
+		}
+		r := httptest.NewRequest(method, path, body)
+		r.Host = host
+		if token {
+			r.Header.Set("X-Tracks-Token", s.token)
+		}
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	// Requests must be local, and API requests must hold the token.
+	for _, tt := range []struct {
+		desc  string
+		path  string
+		host  string
+		token bool
+		code  int
+	}{
+		{"index", "/", "localhost:8080", false, http.StatusOK},
+		{"rebinding", "/", "evil.example.com", false, http.StatusForbidden},
+		{"no token", "/api/job", "127.0.0.1:8080", false, http.StatusForbidden},
+		{"no job", "/api/job", "127.0.0.1:8080", true, http.StatusNotFound},
+	} {
+		w := request("GET", tt.path, tt.host, tt.token, nil, "")
+		if got, want := w.Code, tt.code; got != want {
+			t.Errorf("%s: GET %s = %d, want %d", tt.desc, tt.path, got, want)
+		}
+	}
+	if w := request("GET", "/", "localhost", false, nil, ""); !strings.Contains(w.Body.String(), s.token) {
+		t.Errorf("GET / is missing the token")
+	}
+
+	// Upload the patch.
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("patch", "patch.html")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	fw.Write(patch)
+	mw.Close()
+	w := request("POST", "/api/plan", "localhost", true, &body, mw.FormDataContentType())
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api/plan = %d, %s", w.Code, w.Body)
+	}
+	var res struct {
+		Devices []interface{} `json:"devices"`
+		Plan    *actions.Plan `json:"plan"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if len(res.Devices) == 0 {
+		t.Errorf("POST /api/plan returned no devices")
+	}
+	if got, want := len(res.Plan.Entries), 2; got != want {
+		t.Fatalf("POST /api/plan returned %d entries, want %d", got, want)
+	}
+	if got, want := res.Plan.Entries[0].Dest, "01-02 Kick 91, iKick.wav"; got != want {
+		t.Errorf("POST /api/plan dest = %q, want %q", got, want)
+	}
+
+	// Rename a track, and link the tracks.
+	res.Plan.Entries[1].Name = "Guest Vox"
+	res.Plan.Action = "link"
+	data, _ := json.Marshal(res.Plan)
+	if w := request("POST", "/api/run", "localhost", true, bytes.NewBuffer(data), "application/json"); w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/run = %d, %s", w.Code, w.Body)
+	}
+	var job serveJob
+	for i := 0; !job.Done; i++ {
+		if i == 100 {
+			t.Fatalf("job not done; %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w := request("GET", "/api/job", "localhost", true, nil, "")
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	if job.Error != "" || len(job.Operations) != 2 {
+		t.Errorf("GET /api/job = %+v", job)
+	}
+	for _, f := range []string{"01-02 Kick 91, iKick.wav", "01-03 Guest Vox.wav"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%s: %s", f, err)
+		}
+	}
+
+	// A track without a name is refused.
+	res.Plan.Entries[1].Name = " "
+	data, _ = json.Marshal(res.Plan)
+	if w := request("POST", "/api/names", "localhost", true, bytes.NewBuffer(data), "application/json"); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /api/names = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServeCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src, dest, backup := filepath.Join(dir, "src"), filepath.Join(dir, "dest"), filepath.Join(dir, "backup")
+	for _, d := range []string{src, dest, backup} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "Track 01-1.wav"), []byte("kick"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	s, err := newServer(VenueFlags{
+		srcDir:         src,
+		destDir:        dest,
+		backupDirs:     []string{backup},
+		stateFile:      filepath.Join(dir, "state.json"),
+		manifest:       true,
+		checksum:       actions.MD5,
+		manifestFormat: actions.SumFormat,
+		policy:         actions.Portable,
+		maxLength:      actions.DefaultMaxFilenameLength,
+	})
+	if err != nil {
+		t.Fatalf("newServer() unexpected error %s", err)
+	}
+	plan := &actions.Plan{
+		Action:  "copy",
+		SrcDir:  src,
+		DestDir: dest,
+		Entries: []*actions.PlanEntry{{Src: "Track 01-1.wav", Session: 1, Track: 1, Name: "Kick"}},
+	}
+	data, _ := json.Marshal(plan)
+	r := httptest.NewRequest("POST", "/api/run", bytes.NewBuffer(data))
+	r.Host = "localhost"
+	r.Header.Set("X-Tracks-Token", s.token)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/run = %d, %s", w.Code, w.Body)
+	}
+	for i := 0; ; i++ {
+		if i == 100 {
+			t.Fatalf("job not done")
+		}
+		s.mu.Lock()
+		done := s.job.Done
+		s.mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.job.Error != "" {
+		t.Fatalf("copy job error; %s", s.job.Error)
+	}
+
+	// The copies go to the backup directory too, recorded and with manifests.
+	state, err := actions.LoadCopyState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("LoadCopyState() unexpected error %s", err)
+	}
+	for _, d := range []string{dest, backup} {
+		file := filepath.Join(d, "01-01 Kick.wav")
+		if !state.Done(file) {
+			t.Errorf("copy to %q not recorded", file)
+		}
+		if _, err := os.Stat(filepath.Join(d, "MD5SUMS")); err != nil {
+			t.Errorf("missing manifest; %s", err)
+		}
+	}
+}
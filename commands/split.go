@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	c := "wave"
+	commands = append(commands, []cli.Command{
+		{
+			Name:      "split",
+			Usage:     "split polyphonic wave files into mono tracks, named from a Venue patch if given",
+			ArgsUsage: "file...",
+			Category:  c,
+			Flags: flagLists([]cli.Flag{
+				cli.IntFlag{
+					Name:  "session",
+					Value: 1,
+					Usage: "session number of the first file; each further file is the next session",
+				},
+			}, venueFlagList),
+			Action: WaveSplitAction,
+		},
+		{
+			Name:      "interleave",
+			Usage:     "interleave mono tracks into a polyphonic wave file",
+			ArgsUsage: "file...",
+			Category:  c,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dest_file", Usage: "polyphonic wave file to write"},
+			},
+			Action: WaveInterleaveAction,
+		},
+		{
+			Name:      "stitch",
+			Usage:     "stitch the continuation files of recordings split by the recorder",
+			ArgsUsage: "file...",
+			Category:  c,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dest_dir,d", Usage: "destination directory"},
+			},
+			Action: WaveStitchAction,
+		},
+	}...)
+}
+
+// WaveSplitAction implements cli.ActionFunc. Each channel of a file is written
+// to a mono track of its session, named as Tracks Live names its recordings,
+// e.g. "Track 01-1.wav", ready to be renamed. With a patch file, the tracks
+// are named as they would be renamed, e.g. "01-01 Kick.wav".
+func WaveSplitAction(ctx *cli.Context) error {
+	if !ctx.Args().Present() {
+		return cli.NewExitError(fmt.Errorf("missing file argument"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	session := ctx.Int("session")
+	if session < 1 {
+		return cli.NewExitError(fmt.Errorf("invalid session number %d", session), sysexits.Usage.Int())
+	}
+	var p *actions.Planner
+	destDir := ctx.String("dest_dir")
+	if ctx.String("patch_file") != "" {
+		flags, err := venueFlags(ctx)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.Usage.Int())
+		}
+		v, err := readVenue(flags.patchFile)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+		if p, err = venuePlanner(flags, v.Devices()); err != nil {
+			return cli.NewExitError(err, sysexits.Usage.Int())
+		}
+		destDir = flags.destDir
+	}
+
+	dryRun := ctx.GlobalBool("dry_run")
+	ops := []*waveConversion{}
+	for i, file := range ctx.Args() {
+		var channels int
+		if channels, err = actions.ChannelCount(file); err != nil {
+			break
+		}
+		dir := destDir
+		if dir == "" {
+			dir = filepath.Dir(file)
+		}
+		var names []string
+		if names, err = splitNames(p, session+i, channels); err != nil {
+			break
+		}
+		dests := []string{}
+		for _, name := range names {
+			dest := filepath.Join(dir, name)
+			dests = append(dests, dest)
+			ops = append(ops, &waveConversion{Src: file, Dest: dest, Status: actions.OperationDryRun})
+			output.printf("  %q --> %q\n", file, dest)
+		}
+		if dryRun {
+			continue
+		}
+		status, msg := actions.OperationDone, ""
+		if err = actions.Split(file, dests); err != nil {
+			status, msg = actions.OperationFailed, err.Error()
+		}
+		for _, op := range ops[len(ops)-len(dests):] {
+			op.Status, op.Error = status, msg
+		}
+		if err != nil {
+			break
+		}
+	}
+	if rerr := output.render(os.Stdout, ops, nil); rerr != nil && err == nil {
+		return cli.NewExitError(rerr, sysexits.IOError.Int())
+	}
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	return nil
+}
+
+// splitNames returns the filenames of the channels of a session, as named by
+// the planner, if set, or else by Tracks Live.
+func splitNames(p *actions.Planner, session, channels int) ([]string, error) {
+	files := []string{}
+	for c := 1; c <= channels; c++ {
+		files = append(files, fmt.Sprintf("Track %02d-%d.wav", c, session))
+	}
+	if p == nil {
+		return files, nil
+	}
+	plan, err := p.Plan(files)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range plan.Entries {
+		names = append(names, e.Dest)
+	}
+	return names, nil
+}
+
+// WaveInterleaveAction implements cli.ActionFunc.
+func WaveInterleaveAction(ctx *cli.Context) error {
+	dest := ctx.String("dest_file")
+	if dest == "" {
+		return cli.NewExitError(fmt.Errorf("missing %s flag", "dest_file"), sysexits.Usage.Int())
+	}
+	if !ctx.Args().Present() {
+		return cli.NewExitError(fmt.Errorf("missing file argument"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	srcs := []string(ctx.Args())
+	for _, src := range srcs {
+		if abs, _ := filepath.Abs(src); abs != "" {
+			if absDest, _ := filepath.Abs(dest); abs == absDest {
+				return cli.NewExitError(fmt.Errorf("%q would be interleaved onto itself", src), sysexits.Usage.Int())
+			}
+		}
+	}
+
+	ops := []*waveConversion{}
+	for _, src := range srcs {
+		ops = append(ops, &waveConversion{Src: src, Dest: dest, Status: actions.OperationDryRun})
+		output.printf("  %q --> %q\n", src, dest)
+	}
+	if !ctx.GlobalBool("dry_run") {
+		status, msg := actions.OperationDone, ""
+		err = actions.Interleave(srcs, dest)
+		if err != nil {
+			status, msg = actions.OperationFailed, err.Error()
+		}
+		for _, op := range ops {
+			op.Status, op.Error = status, msg
+		}
+	}
+	if rerr := output.render(os.Stdout, ops, nil); rerr != nil && err == nil {
+		return cli.NewExitError(rerr, sysexits.IOError.Int())
+	}
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	return nil
+}
+
+// WaveStitchAction implements cli.ActionFunc. The files are grouped into
+// recordings, in the order given, and each recording of more than one file is
+// stitched into a file named after its first.
+func WaveStitchAction(ctx *cli.Context) error {
+	destDir := ctx.String("dest_dir")
+	if destDir == "" {
+		return cli.NewExitError(fmt.Errorf("missing %s flag", "dest_dir"), sysexits.Usage.Int())
+	}
+	if !ctx.Args().Present() {
+		return cli.NewExitError(fmt.Errorf("missing file argument"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	groups, err := actions.Continuations(ctx.Args())
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+
+	ops := []*waveConversion{}
+	for _, srcs := range groups {
+		if len(srcs) < 2 {
+			output.printf("  %q is not continued\n", srcs[0])
+			continue
+		}
+		dest := filepath.Join(destDir, filepath.Base(srcs[0]))
+		for _, src := range srcs {
+			if abs, _ := filepath.Abs(src); abs != "" {
+				if absDest, _ := filepath.Abs(dest); abs == absDest {
+					return cli.NewExitError(fmt.Errorf("%q would be stitched onto itself", src), sysexits.Usage.Int())
+				}
+			}
+		}
+		for _, src := range srcs {
+			ops = append(ops, &waveConversion{Src: src, Dest: dest, Status: actions.OperationDryRun})
+			output.printf("  %q --> %q\n", src, dest)
+		}
+		if ctx.GlobalBool("dry_run") {
+			continue
+		}
+		status, msg := actions.OperationDone, ""
+		if err = actions.Stitch(srcs, dest); err != nil {
+			status, msg = actions.OperationFailed, err.Error()
+		}
+		for _, op := range ops[len(ops)-len(srcs):] {
+			op.Status, op.Error = status, msg
+		}
+		if err != nil {
+			break
+		}
+	}
+	if rerr := output.render(os.Stdout, ops, nil); rerr != nil && err == nil {
+		return cli.NewExitError(rerr, sysexits.IOError.Int())
+	}
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestSplitNames(t *testing.T) {
+	names, err := splitNames(nil, 2, 3)
+	if err != nil {
+		t.Fatalf("splitNames() unexpected error %s", err)
+	}
+	if want := []string{"Track 01-2.wav", "Track 02-2.wav", "Track 03-2.wav"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("splitNames() = %q, want %q", names, want)
+	}
+
+	v, err := readVenue("../testdata/20170906 ICF Ladies Night.html")
+	if err != nil {
+		t.Fatalf("readVenue() unexpected error %s", err)
+	}
+	p := actions.NewPlanner(v.Devices())
+	p.Fallbacks = []actions.Fallback{actions.FallbackNumber}
+	if names, err = splitNames(p, 2, 3); err != nil {
+		t.Fatalf("splitNames() unexpected error %s", err)
+	}
+	if want := []string{"02-01 Track 01.wav", "02-02 Kick 91, iKick.wav", "02-03 iSnare.wav"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("splitNames() = %q, want %q", names, want)
+	}
+}
@@ -2,10 +2,12 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
-	k8os "github.com/kward/golib/os"
 	"github.com/kward/golib/os/sysexits"
 	"github.com/kward/tracks/actions"
 	"github.com/kward/tracks/tracks"
@@ -15,29 +17,62 @@ import (
 
 var discoverFilesFn actions.DiscoverFilesFn
 
+// venueFlagList holds the flags shared by commands that rename tracks.
+var venueFlagList = []cli.Flag{
+	cli.StringFlag{
+		Name:  "patch_file,p",
+		Usage: "Venue patch or info file",
+	},
+	cli.StringFlag{
+		Name:  "src_dir,s",
+		Usage: "source directory",
+	},
+	cli.StringFlag{
+		Name:  "dest_dir,d",
+		Usage: "destination directory (leave empty if renaming in-place)",
+	},
+	cli.StringFlag{
+		Name:  "filename_policy",
+		Value: actions.Portable.String(),
+		Usage: "filename policy (portable, macos, windows, ascii)",
+	},
+	cli.IntFlag{
+		Name:  "max_filename_length",
+		Value: actions.DefaultMaxFilenameLength,
+		Usage: "maximum filename length",
+	},
+	cli.StringFlag{
+		Name:  "fallback",
+		Value: "protools",
+		Usage: "comma separated names to try for tracks without a named channel (protools, file, original, number)",
+	},
+	cli.StringFlag{
+		Name:  "fallback_file",
+		Usage: "CSV file of track numbers and names, used by the file fallback",
+	},
+	cli.StringFlag{
+		Name:  "overrides,o",
+		Usage: "CSV or YAML file of track names overriding the Venue names",
+	},
+}
+
+// linkModeFlag selects how tracks are linked.
+var linkModeFlag = cli.StringFlag{
+	Name:  "link_mode",
+	Value: actions.HardLink.String(),
+	Usage: "how to link tracks (hard, symlink, clone); clone falls back to hard, then symlink",
+}
+
 func init() {
 	c := "venue"
-	f := []cli.Flag{
-		cli.StringFlag{
-			Name:  "patch_file,p",
-			Usage: "Venue patch or info file",
-		},
-		cli.StringFlag{
-			Name:  "src_dir,s",
-			Usage: "source directory",
-		},
-		cli.StringFlag{
-			Name:  "dest_dir,d",
-			Usage: "destination directory (leave empty if renaming in-place)",
-		},
-	}
+	f := venueFlagList
 	commands = append(commands, []cli.Command{
 		{
 			Name:     "copy",
 			Aliases:  []string{"cp"},
 			Usage:    "copy tracks with new names",
 			Category: c,
-			Flags:    f,
+			Flags:    flagLists(f, copyFlagList, convertFlagList, manifestFlagList),
 			Action:   VenueCopyAction,
 			After:    VenueDryRunAction,
 		}, {
@@ -45,7 +80,7 @@ func init() {
 			Aliases:  []string{"ln"},
 			Usage:    "make links with new names, without removing original files",
 			Category: c,
-			Flags:    f,
+			Flags:    flagLists(f, []cli.Flag{linkModeFlag}),
 			Action:   VenueLinkAction,
 			After:    VenueDryRunAction,
 		}, {
@@ -53,9 +88,24 @@ func init() {
 			Aliases:  []string{"mv"},
 			Usage:    "move or rename tracks",
 			Category: c,
-			Flags:    f,
+			Flags:    flagLists(f, manifestFlagList),
 			Action:   VenueMoveAction,
 			After:    VenueDryRunAction,
+		}, {
+			Name:     "lint",
+			Usage:    "check a Venue patch file for problems",
+			Category: c,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "patch_file,p",
+					Usage: "Venue patch or info file",
+				},
+				cli.StringSliceFlag{
+					Name:  "device",
+					Usage: "device expected in the patch file (e.g. \"Stage 1\"); may be repeated",
+				},
+			},
+			Action: VenueLintAction,
 		},
 	}...)
 
@@ -69,6 +119,37 @@ type VenueFlags struct {
 	dryRun          bool
 	patchFile       string
 	srcDir, destDir string
+	files           []string // A file listing, read instead of srcDir.
+	policy          actions.FilenamePolicy
+	maxLength       int
+	fallbacks       []string
+	fallbackFile    string
+	overridesFile   string
+	backupDirs      []string // Additional copy destinations.
+	stateFile       string
+	manifest        bool // Write a checksum manifest.
+	checksum        actions.HashAlgorithm
+	manifestFormat  actions.ManifestFormat
+	linkMode        actions.LinkMode
+	convert         *actions.Converter // Convert the copies, if set.
+	jobs            int                // Conversions at once.
+	output          outputFormat
+	stdout          io.Writer // Of the output; os.Stdout if nil.
+}
+
+// writer returns the writer of the output.
+func (f VenueFlags) writer() io.Writer {
+	if f.stdout == nil {
+		return os.Stdout
+	}
+	return f.stdout
+}
+
+// printf prints text output to the writer of the output.
+func (f VenueFlags) printf(format string, a ...interface{}) {
+	if f.output == textOutput {
+		fmt.Fprintf(f.writer(), format, a...)
+	}
 }
 
 func venueFlags(ctx *cli.Context) (VenueFlags, error) {
@@ -86,16 +167,109 @@ func venueFlags(ctx *cli.Context) (VenueFlags, error) {
 	}
 
 	// Parse flags.
+	policy, err := actions.ParseFilenamePolicy(ctx.String("filename_policy"))
+	if err != nil {
+		return VenueFlags{}, err
+	}
+	fallbacks := strings.Split(ctx.String("fallback"), ",")
+	for _, fb := range fallbacks {
+		switch fb {
+		case "protools", "original", "number":
+		case "file":
+			if !ctx.IsSet("fallback_file") {
+				return VenueFlags{}, fmt.Errorf("missing fallback_file flag")
+			}
+		default:
+			return VenueFlags{}, fmt.Errorf("unknown fallback %q", fb)
+		}
+	}
+	linkMode, err := venueLinkMode(ctx)
+	if err != nil {
+		return VenueFlags{}, err
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return VenueFlags{}, err
+	}
+	manifest := ctx.String("checksum") != ""
+	checksum, manifestFormat := actions.MD5, actions.SumFormat
+	if manifest {
+		if checksum, err = actions.ParseHashAlgorithm(ctx.String("checksum")); err != nil {
+			return VenueFlags{}, err
+		}
+		if manifestFormat, err = actions.ParseManifestFormat(ctx.String("manifest_format")); err != nil {
+			return VenueFlags{}, err
+		}
+		if manifestFormat == actions.MHLFormat && checksum == actions.SHA256 {
+			return VenueFlags{}, fmt.Errorf("the mhl manifest format does not support %s", checksum)
+		}
+		if err := manifestDirs(ctx.String("src_dir"), ctx.String("dest_dir")); err != nil {
+			return VenueFlags{}, err
+		}
+	}
+	convert, err := convertFlags(ctx)
+	if err != nil {
+		return VenueFlags{}, err
+	}
+	ext := ".wav"
+	if convert != nil {
+		ext = convert.Encoding.Ext()
+	}
+	if min := actions.MinFilenameLength(ext); ctx.Int("max_filename_length") < min {
+		return VenueFlags{}, fmt.Errorf("the max_filename_length must be at least %d", min)
+	}
 	return VenueFlags{
-		dryRun:    ctx.GlobalBool("dry_run"),
-		patchFile: ctx.String("patch_file"),
-		srcDir:    ctx.String("src_dir"),
-		destDir:   ctx.String("dest_dir"),
+		dryRun:         ctx.GlobalBool("dry_run"),
+		patchFile:      ctx.String("patch_file"),
+		srcDir:         ctx.String("src_dir"),
+		destDir:        ctx.String("dest_dir"),
+		policy:         policy,
+		maxLength:      ctx.Int("max_filename_length"),
+		fallbacks:      fallbacks,
+		fallbackFile:   ctx.String("fallback_file"),
+		overridesFile:  ctx.String("overrides"),
+		backupDirs:     ctx.StringSlice("backup_dir"),
+		stateFile:      ctx.String("state_file"),
+		manifest:       manifest,
+		checksum:       checksum,
+		manifestFormat: manifestFormat,
+		linkMode:       linkMode,
+		convert:        convert,
+		jobs:           ctx.Int("jobs"),
+		output:         output,
 	}, nil
 }
 
+// venueLinkMode returns the link mode flag, if the command has one.
+func venueLinkMode(ctx *cli.Context) (actions.LinkMode, error) {
+	if ctx.String("link_mode") == "" {
+		return actions.HardLink, nil
+	}
+	return actions.ParseLinkMode(ctx.String("link_mode"))
+}
+
+// venueOp describes the file operation of a venue command.
+type venueOp struct {
+	desc string // Progressive verb, e.g. "copying".
+	fn   actions.FileOp
+}
+
+var venueOps = map[string]venueOp{
+	"copy": {"copying", nil}, // Copies go to the backup directories too; see venueCopy().
+	"link": {"linking", nil}, // Depends on the link mode; see venueLink().
+	"move": {"moving", actions.MoveOp},
+}
+
 // VenueCopyAction implements cli.ActionFunc.
-func VenueCopyAction(ctx *cli.Context) error {
+func VenueCopyAction(ctx *cli.Context) error { return venueAction(ctx, "copy") }
+
+// VenueLinkAction implements cli.ActionFunc.
+func VenueLinkAction(ctx *cli.Context) error { return venueAction(ctx, "link") }
+
+// VenueMoveAction implements cli.ActionFunc.
+func VenueMoveAction(ctx *cli.Context) error { return venueAction(ctx, "move") }
+
+func venueAction(ctx *cli.Context, op string) error {
 	flags, err := venueFlags(ctx)
 	if err != nil {
 		return cli.NewExitError(err, sysexits.Usage.Int())
@@ -104,43 +278,115 @@ func VenueCopyAction(ctx *cli.Context) error {
 	if err != nil {
 		return cli.NewExitError(err, sysexits.Software.Int())
 	}
-	fmt.Println("Copying:")
-	if err := venueBatch(flags, k8os.Copy, names); err != nil {
-		return cli.NewExitError(fmt.Sprintf("error copying file; %s", err), sysexits.Software.Int())
+	return venueRun(flags, op, names)
+}
+
+// venueResult is the structured result of a venue command.
+type venueResult struct {
+	Action     string               `json:"action" yaml:"action"`
+	DryRun     bool                 `json:"dry_run" yaml:"dry_run"`
+	Operations []*actions.Operation `json:"operations" yaml:"operations"`
+	Copies     []*copyStats         `json:"copies,omitempty" yaml:"copies,omitempty"`
+	Manifests  []string             `json:"manifests,omitempty" yaml:"manifests,omitempty"`
+}
+
+// venueRun performs the named file operation on all names.
+func venueRun(flags VenueFlags, op string, names []VenueNames) error {
+	o, ok := venueOps[op]
+	if !ok {
+		return cli.NewExitError(fmt.Sprintf("unknown operation %q", op), sysexits.Usage.Int())
+	}
+	res := &venueResult{Action: op, DryRun: flags.dryRun, Operations: []*actions.Operation{}}
+	flags.printf("%s%s:\n", strings.ToUpper(o.desc[:1]), o.desc[1:])
+	batch := func() error {
+		return venueBatch(flags, names, res, o.fn)
+	}
+	switch op {
+	case "copy":
+		batch = func() error { return venueCopy(flags, names, res) }
+	case "link":
+		batch = func() error { return venueBatch(flags, names, res, venueLink(flags)) }
+	}
+	if err := batch(); err != nil {
+		flags.output.render(flags.writer(), res, nil)
+		return cli.NewExitError(fmt.Sprintf("error %s file; %s", o.desc, err), sysexits.Software.Int())
+	}
+	if flags.manifest && !flags.dryRun {
+		files, err := venueManifest(flags, venueDestDirs(flags, op), names)
+		res.Manifests = files
+		if err != nil {
+			flags.output.render(flags.writer(), res, nil)
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+	}
+	if err := flags.output.render(flags.writer(), res, func(w io.Writer) { venueReport(w, names) }); err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
 	}
 	return nil
 }
 
-// VenueLinkAction implements cli.ActionFunc.
-func VenueLinkAction(ctx *cli.Context) error {
-	flags, err := venueFlags(ctx)
+// manifestDirs returns an error if the manifest of the renamed tracks would be
+// written to the source directory. The verify command would then find the
+// recordings, the patch file and any other files there to be extra.
+func manifestDirs(srcDir, destDir string) error {
+	src, err := filepath.Abs(srcDir)
 	if err != nil {
-		return cli.NewExitError(err, sysexits.Usage.Int())
+		return err
 	}
-	names, err := venueNames(flags)
+	dest, err := filepath.Abs(destDir)
 	if err != nil {
-		return cli.NewExitError(err, sysexits.Software.Int())
+		return err
 	}
-	fmt.Println("Linking:")
-	if err := venueBatch(flags, os.Link, names); err != nil {
-		return cli.NewExitError(fmt.Sprintf("error copying file; %s", err), sysexits.Software.Int())
+	if src == dest {
+		return fmt.Errorf("the checksum flag needs a dest_dir other than the src_dir")
 	}
 	return nil
 }
 
-// VenueMoveAction implements cli.ActionFunc.
-func VenueMoveAction(ctx *cli.Context) error {
-	flags, err := venueFlags(ctx)
+// venueDestDirs returns the directories the tracks are renamed into by op.
+func venueDestDirs(flags VenueFlags, op string) []string {
+	dirs := []string{flags.destDir}
+	if op == "copy" {
+		dirs = append(dirs, flags.backupDirs...)
+	}
+	return dirs
+}
+
+// VenueLintAction implements cli.ActionFunc.
+func VenueLintAction(ctx *cli.Context) error {
+	if !ctx.IsSet("patch_file") {
+		return cli.NewExitError(fmt.Errorf("missing %s flag", "patch_file"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
 	if err != nil {
 		return cli.NewExitError(err, sysexits.Usage.Int())
 	}
-	names, err := venueNames(flags)
+	patchFile := ctx.String("patch_file")
+
+	data, err := ioutil.ReadFile(patchFile)
 	if err != nil {
-		return cli.NewExitError(err, sysexits.Software.Int())
+		return cli.NewExitError(fmt.Errorf("error reading Venue patch file; %s", err), sysexits.IOError.Int())
+	}
+	ps, err := venue.Lint(data, ctx.StringSlice("device")...)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error linting the Venue data; %s", err), sysexits.DataError.Int())
+	}
+	if ps == nil {
+		ps = venue.Problems{}
 	}
-	fmt.Println("Moving:")
-	if err := venueBatch(flags, os.Rename, names); err != nil {
-		return cli.NewExitError(fmt.Sprintf("error copying file; %s", err), sysexits.Software.Int())
+	err = output.render(os.Stdout, struct {
+		File     string         `json:"file" yaml:"file"`
+		Problems venue.Problems `json:"problems" yaml:"problems"`
+	}{patchFile, ps}, func(w io.Writer) {
+		for _, p := range ps {
+			fmt.Fprintf(w, "%s: %s\n", patchFile, p)
+		}
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	if ps.Max() == venue.Error {
+		return cli.NewExitError(fmt.Errorf("%s has errors", patchFile), sysexits.DataError.Int())
 	}
 	return nil
 }
@@ -155,11 +401,35 @@ func VenueDryRunAction(ctx *cli.Context) error {
 
 type VenueNames struct {
 	orig, dest string
+	name       string
+	snum, tnum int
+	channel    string // The stage box input, e.g. "Stage 1 3".
+	source     tracks.NameSource
+	override   *actions.Applied // Non-nil if the name was overridden.
 }
 
-func venueNames(flags VenueFlags) ([]VenueNames, error) {
-	// Read Venue file.
-	data, err := ioutil.ReadFile(flags.patchFile)
+// entry returns the plan entry of the track.
+func (n VenueNames) entry() *actions.PlanEntry {
+	return &actions.PlanEntry{
+		Src:        n.orig,
+		Dest:       n.dest,
+		Session:    n.snum,
+		Track:      n.tnum,
+		Name:       n.name,
+		NameSource: n.source,
+		Channel:    n.channel,
+		Override:   n.override,
+	}
+}
+
+// operation returns the, as yet unperformed, operation on the track.
+func (n VenueNames) operation(srcDir, destDir string) *actions.Operation {
+	return n.entry().Operation(srcDir, destDir)
+}
+
+// readVenue reads and parses a Venue patch file.
+func readVenue(file string) (*venue.Venue, error) {
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("error reading Venue patch file; %s", err)
 	}
@@ -167,58 +437,159 @@ func venueNames(flags VenueFlags) ([]VenueNames, error) {
 	if err := v.Parse(data); err != nil {
 		return nil, fmt.Errorf("error parsing the Venue data; %s", err)
 	}
+	return v, nil
+}
 
-	files, err := discoverFilesFn(flags.srcDir, actions.FilterWaves)
+func venueNames(flags VenueFlags) ([]VenueNames, error) {
+	plan, err := planTracks(flags)
 	if err != nil {
-		return nil, fmt.Errorf("error discovering wave files; %s", err)
+		return nil, err
+	}
+	for _, file := range plan.Ignored {
+		fmt.Fprintf(os.Stderr, "ignoring %q\n", file)
 	}
+	for _, o := range plan.Unused {
+		fmt.Fprintf(os.Stderr, "unused override %s\n", o)
+	}
+	return venuePlanNames(plan), nil
+}
 
-	sessions, err := tracks.ExtractSessions(files)
+// planTracks plans the names of the tracks of the source directory, or of the
+// files given.
+func planTracks(flags VenueFlags) (*actions.Plan, error) {
+	v, err := readVenue(flags.patchFile)
 	if err != nil {
-		return nil, fmt.Errorf("error extracting sessions; %s", err)
+		return nil, err
 	}
 
-	// Map tracks to stage boxes.
-	// TODO(20171225 kward): Move to action package.
-	for _, s := range sessions {
-		ts, err := actions.MapTracksToNames(s.Tracks(), v.Devices())
-		if err != nil {
-			return nil, fmt.Errorf("error mapping tracks; %s", err)
+	files := flags.files
+	if files == nil {
+		if files, err = discoverFilesFn(flags.srcDir, actions.FilterWaves); err != nil {
+			return nil, fmt.Errorf("error discovering wave files; %s", err)
 		}
-		s.SetTracks(ts)
 	}
 
-	// Map tracks to new names.
-	names := []VenueNames{}
-	for _, s := range sessions {
-		for _, t := range s.Tracks().Slice() {
-			name := t.Name()
-			if name == "" {
-				name = fmt.Sprintf("Track %02d", t.TrackNum())
+	p, err := venuePlanner(flags, v.Devices())
+	if err != nil {
+		return nil, err
+	}
+	return p.Plan(files)
+}
+
+// venuePlanner returns a planner for the devices, with the options chosen by
+// the user.
+func venuePlanner(flags VenueFlags, devs venue.Devices) (*actions.Planner, error) {
+	p := actions.NewPlanner(devs)
+	p.Policy, p.MaxLength = flags.policy, flags.maxLength
+	if flags.convert != nil {
+		p.Ext = flags.convert.Encoding.Ext()
+	}
+	var err error
+	if p.Fallbacks, err = venueFallbacks(flags); err != nil {
+		return nil, err
+	}
+	if p.Overrides, err = venueOverrides(flags); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// venueFallbacks returns the fallbacks chosen by the user.
+func venueFallbacks(flags VenueFlags) ([]actions.Fallback, error) {
+	fallbacks := []actions.Fallback{}
+	for _, fb := range flags.fallbacks {
+		switch fb {
+		case "protools":
+			fallbacks = append(fallbacks, actions.FallbackProTools)
+		case "original":
+			fallbacks = append(fallbacks, actions.FallbackOriginal)
+		case "number":
+			fallbacks = append(fallbacks, actions.FallbackNumber)
+		case "file":
+			f, err := os.Open(flags.fallbackFile)
+			if err != nil {
+				return nil, fmt.Errorf("error opening fallback file; %s", err)
+			}
+			names, err := actions.ReadFallbackNames(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("error reading fallback file %q; %s", flags.fallbackFile, err)
 			}
-			dest := fmt.Sprintf("%02d-%02d %s.wav", s.Num(), t.TrackNum(), actions.MapTrackNameToFilename(name))
-			t.SetDest(dest)
-			names = append(names, VenueNames{t.Src(), t.Dest()})
+			fallbacks = append(fallbacks, actions.FallbackNames(names))
 		}
 	}
+	return fallbacks, nil
+}
 
-	if len(names) == 0 {
-		return nil, fmt.Errorf("no tracks found")
+// venueOverrides returns the overrides chosen by the user, if any.
+func venueOverrides(flags VenueFlags) (actions.Overrides, error) {
+	if flags.overridesFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(flags.overridesFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening overrides file; %s", err)
+	}
+	defer f.Close()
+	ovs, err := actions.ReadOverrides(f, strings.TrimPrefix(filepath.Ext(flags.overridesFile), "."))
+	if err != nil {
+		return nil, fmt.Errorf("error reading overrides file %q; %s", flags.overridesFile, err)
 	}
-	return names, nil
+	return ovs, nil
 }
 
-func venueBatch(flags VenueFlags, fn func(src, dest string) error, names []VenueNames) error {
+// venueReport writes which tracks were named from which source.
+func venueReport(w io.Writer, names []VenueNames) {
+	srcs := map[tracks.NameSource][]string{}
 	for _, name := range names {
-		origPath := fmt.Sprintf("%s/%s", flags.srcDir, name.orig)
-		destPath := fmt.Sprintf("%s/%s", flags.destDir, name.dest)
-		fmt.Printf("  %q --> %q\n", origPath, destPath)
-		if flags.dryRun {
+		srcs[name.source] = append(srcs[name.source], fmt.Sprintf("%02d-%02d", name.snum, name.tnum))
+	}
+	fmt.Fprintln(w, "Name sources:")
+	for src := tracks.NameFromUnknown; src <= tracks.NameFromOverride; src++ {
+		if ids, ok := srcs[src]; ok {
+			fmt.Fprintf(w, "  %s (%d): %s\n", src, len(ids), strings.Join(ids, " "))
+		}
+	}
+
+	first := true
+	for _, name := range names {
+		a := name.override
+		if a == nil {
 			continue
 		}
-		if err := fn(origPath, destPath); err != nil {
-			return err
+		if first {
+			fmt.Fprintln(w, "Overrides:")
+			first = false
 		}
+		fmt.Fprintf(w, "  %02d-%02d %q --> %q by %s\n", name.snum, name.tnum, a.Previous, a.Override.Name, a.Override)
 	}
-	return nil
+}
+
+// venueLink returns a function linking tracks with the link mode. A fallback
+// from one mode to another is reported once, if the fallback succeeded.
+func venueLink(flags VenueFlags) actions.FileOp {
+	link := actions.LinkOp(flags.linkMode)
+	reported := false
+	return func(op *actions.Operation) error {
+		err := link(op)
+		if err == nil && op.LinkMode != flags.linkMode.String() && !reported {
+			flags.printf("  (%s links are not supported here; using %s links)\n", flags.linkMode, op.LinkMode)
+			reported = true
+		}
+		return err
+	}
+}
+
+// venueBatch performs fn on all names, recording the operations in res.
+func venueBatch(flags VenueFlags, names []VenueNames, res *venueResult, fn actions.FileOp) error {
+	x := actions.NewExecutor(fn)
+	x.DryRun = flags.dryRun
+	x.Progress = func(op *actions.Operation) {
+		if op.Status == "" {
+			flags.printf("  %q --> %q\n", op.Src, op.Dest)
+		}
+	}
+	ops, err := x.Execute(venuePlan(flags, res.Action, names))
+	res.Operations = append(res.Operations, ops...)
+	return err
 }
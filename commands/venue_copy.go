@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+// copyFlagList holds the flags of commands that copy tracks.
+var copyFlagList = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "backup_dir,b",
+		Usage: "additional destination directory, written at the same time; may be repeated",
+	},
+	cli.StringFlag{
+		Name:  "state_file",
+		Usage: "file recording the verified copies, so that an interrupted copy can be resumed",
+	},
+}
+
+// copyStats accumulates the copies to one destination directory.
+type copyStats struct {
+	Dir            string          `json:"dir" yaml:"dir"`
+	Files          int             `json:"files" yaml:"files"`
+	Skipped        int             `json:"skipped" yaml:"skipped"`
+	Bytes          int64           `json:"bytes" yaml:"bytes"`
+	Duration       actions.Seconds `json:"duration" yaml:"duration"`
+	BytesPerSecond float64         `json:"bytes_per_second" yaml:"bytes_per_second"`
+}
+
+func (s *copyStats) String() string {
+	rate := "-"
+	if s.Duration > 0 {
+		rate = formatBytes(int64(s.BytesPerSecond)) + "/s"
+	}
+	return fmt.Sprintf("%q: %d files (%d skipped), %s in %s, %s",
+		s.Dir, s.Files, s.Skipped, formatBytes(s.Bytes), time.Duration(s.Duration).Round(time.Millisecond), rate)
+}
+
+// venueCopy copies the tracks to the destination and backup directories at
+// once, verifying every copy, with an actions.Copier. Copies recorded in the
+// state file are skipped. If the copies are converted, the tracks are
+// converted flags.jobs at a time.
+func venueCopy(flags VenueFlags, names []VenueNames, res *venueResult) error {
+	c, err := venueCopier(flags)
+	if err != nil {
+		return err
+	}
+	dirs := append([]string{flags.destDir}, flags.backupDirs...)
+	stats := map[string]*copyStats{}
+	for _, dir := range dirs {
+		stats[filepath.Clean(dir)] = &copyStats{Dir: dir}
+	}
+	var mu sync.Mutex // Guards the stats.
+	c.Copied = func(op *actions.Operation, r actions.CopyResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		s := stats[filepath.Dir(r.Dest)]
+		s.Files++
+		s.Bytes += r.Bytes
+		s.Duration += actions.Seconds(r.Duration)
+	}
+
+	x := actions.NewExecutor(c.Op)
+	x.DryRun = flags.dryRun
+	if flags.convert != nil {
+		x.Jobs = flags.jobs
+	}
+	x.Progress = func(op *actions.Operation) {
+		if op.Status == "" {
+			for _, dest := range c.Dests(op) {
+				flags.printf("  %q --> %q\n", op.Src, dest)
+			}
+		}
+	}
+	ops, err := x.Execute(venuePlan(flags, res.Action, names))
+	for _, op := range ops {
+		// The result lists the copy to each destination.
+		for _, cp := range c.Copies(op) {
+			res.Operations = append(res.Operations, cp)
+			if cp.Status == actions.OperationSkipped {
+				stats[filepath.Dir(cp.Dest)].Skipped++
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !flags.dryRun {
+		flags.printf("Verified copies:\n")
+		for _, dir := range dirs {
+			s := stats[filepath.Clean(dir)]
+			if s.Duration > 0 {
+				s.BytesPerSecond = float64(s.Bytes) / time.Duration(s.Duration).Seconds()
+			}
+			flags.printf("  %s\n", s)
+			res.Copies = append(res.Copies, s)
+		}
+	}
+	return nil
+}
+
+// venueCopier returns the copier of the tracks, with the backup directories,
+// state file and conversion chosen by the user.
+func venueCopier(flags VenueFlags) (*actions.Copier, error) {
+	c := &actions.Copier{BackupDirs: flags.backupDirs, Convert: flags.convert}
+	var err error
+	if flags.stateFile != "" {
+		if c.State, err = actions.LoadCopyState(flags.stateFile); err != nil {
+			return nil, err
+		}
+	}
+	// FLAC encoded tracks are tagged with the show of the patch.
+	if flags.convert != nil && flags.convert.Encoding == actions.FLACEncoding && flags.patchFile != "" {
+		if c.Venue, err = readVenue(flags.patchFile); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// formatBytes returns a human readable byte count, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
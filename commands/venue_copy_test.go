@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/actions/wavetest"
+	"github.com/kward/tracks/flac"
+)
+
+func TestVenueCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venue_copy_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	flags := VenueFlags{
+		srcDir:     filepath.Join(dir, "src"),
+		destDir:    filepath.Join(dir, "dest"),
+		backupDirs: []string{filepath.Join(dir, "backup")},
+		stateFile:  filepath.Join(dir, "state.json"),
+	}
+	for _, d := range []string{flags.srcDir, flags.destDir, flags.backupDirs[0]} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", dest: "01-01 Kick.wav", snum: 1, tnum: 1},
+		{orig: "Track 02-1.wav", dest: "01-02 Snare.wav", snum: 1, tnum: 2},
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(flags.srcDir, name.orig), []byte(name.dest), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	if err := venueCopy(flags, names, &venueResult{}); err != nil {
+		t.Fatalf("venueCopy() unexpected error %s", err)
+	}
+	for _, d := range []string{flags.destDir, flags.backupDirs[0]} {
+		for _, name := range names {
+			got, err := ioutil.ReadFile(filepath.Join(d, name.dest))
+			if err != nil || string(got) != name.dest {
+				t.Errorf("%s/%s = %q, %v; want %q", d, name.dest, got, err, name.dest)
+			}
+		}
+	}
+
+	// Resuming skips the recorded copies, even if the source is gone.
+	if err := os.Remove(filepath.Join(flags.srcDir, names[0].orig)); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if err := venueCopy(flags, names, &venueResult{}); err != nil {
+		t.Errorf("venueCopy() unexpected error resuming; %s", err)
+	}
+
+	// A failing backup leaves the verified copy to the destination in place,
+	// and recorded.
+	more := VenueNames{orig: "Track 03-1.wav", dest: "01-03 Bass.wav", snum: 1, tnum: 3}
+	if err := ioutil.WriteFile(filepath.Join(flags.srcDir, more.orig), []byte(more.dest), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	flags.backupDirs = []string{filepath.Join(dir, "missing")}
+	res := &venueResult{}
+	if err := venueCopy(flags, []VenueNames{more}, res); err == nil {
+		t.Errorf("venueCopy() to a missing backup expected error")
+	}
+	want := []actions.OperationStatus{actions.OperationDone, actions.OperationFailed}
+	for i, op := range res.Operations {
+		if op.Status != want[i] {
+			t.Errorf("venueCopy() %q status = %s, want %s", op.Dest, op.Status, want[i])
+		}
+	}
+	state, err := actions.LoadCopyState(flags.stateFile)
+	if err != nil {
+		t.Fatalf("LoadCopyState() unexpected error %s", err)
+	}
+	if !state.Done(filepath.Join(flags.destDir, more.dest)) {
+		t.Errorf("venueCopy() didn't record the verified copy")
+	}
+}
+
+func TestVenueCopyConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venue_copy_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	flags := VenueFlags{
+		srcDir:     filepath.Join(dir, "src"),
+		destDir:    filepath.Join(dir, "dest"),
+		backupDirs: []string{filepath.Join(dir, "backup")},
+		convert:    actions.NewConverter(actions.Int24, 0),
+		jobs:       2,
+	}
+	for _, d := range []string{flags.srcDir, flags.destDir, flags.backupDirs[0]} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", dest: "01-01 Kick.wav", snum: 1, tnum: 1},
+		{orig: "Track 02-1.wav", dest: "01-02 Snare.wav", snum: 1, tnum: 2},
+		{orig: "Track 03-1.wav", dest: "01-03 Hat.wav", snum: 1, tnum: 3},
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(flags.srcDir, name.orig), wavetest.Mono(1, -1, 1000, -32768).Bytes(), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	res := &venueResult{}
+	if err := venueCopy(flags, names, res); err != nil {
+		t.Fatalf("venueCopy() unexpected error %s", err)
+	}
+	for _, op := range res.Operations {
+		if got, want := op.Status, actions.OperationDone; got != want {
+			t.Errorf("%s: status = %s, want %s", op.Dest, got, want)
+		}
+		info, err := actions.WaveInfo(op.Dest)
+		if err != nil {
+			t.Errorf("WaveInfo(%q) unexpected error %s", op.Dest, err)
+			continue
+		}
+		if info.BitsPerSample != 24 || info.Frames != 4 {
+			t.Errorf("WaveInfo(%q) = %s", op.Dest, info)
+		}
+	}
+	for _, name := range names {
+		dest, _ := ioutil.ReadFile(filepath.Join(flags.destDir, name.dest))
+		backup, _ := ioutil.ReadFile(filepath.Join(flags.backupDirs[0], name.dest))
+		if !bytes.Equal(dest, backup) {
+			t.Errorf("%s: the backup differs", name.dest)
+		}
+	}
+
+	// A failed conversion fails the copy.
+	if err := ioutil.WriteFile(filepath.Join(flags.srcDir, names[1].orig), []byte("junk"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	if err := venueCopy(flags, names, &venueResult{}); err == nil {
+		t.Errorf("venueCopy() expected error")
+	}
+}
+
+func TestVenueCopyFLAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venue_copy_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	flags := VenueFlags{
+		patchFile:  "../testdata/20170906 ICF Ladies Night.html",
+		srcDir:     filepath.Join(dir, "src"),
+		destDir:    filepath.Join(dir, "dest"),
+		backupDirs: []string{filepath.Join(dir, "backup")},
+		convert:    &actions.Converter{Encoding: actions.FLACEncoding},
+	}
+	for _, d := range []string{flags.srcDir, flags.destDir, flags.backupDirs[0]} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", dest: "01-01 Kick.flac", name: "Kick", snum: 1, tnum: 1, channel: "Stage 1 1"},
+	}
+	if err := ioutil.WriteFile(filepath.Join(flags.srcDir, names[0].orig), wavetest.Mono(1, -1, 1000, -32768).Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	if err := venueCopy(flags, names, &venueResult{}); err != nil {
+		t.Fatalf("venueCopy() unexpected error %s", err)
+	}
+	for _, d := range []string{flags.destDir, flags.backupDirs[0]} {
+		f, err := os.Open(filepath.Join(d, names[0].dest))
+		if err != nil {
+			t.Errorf("unexpected error; %s", err)
+			continue
+		}
+		dec, err := flac.NewDecoder(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("NewDecoder() unexpected error %s", err)
+			continue
+		}
+		want := []string{"TITLE=Kick", "TRACKNUMBER=1", "SESSION=1", "CHANNEL=Stage 1 1"}
+		if got := dec.Comments; len(got) < len(want) || !reflect.DeepEqual(got[:len(want)], want) {
+			t.Errorf("%s: comments = %q, want %q first", d, got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	} {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	c := "venue"
+	commands = append(commands, []cli.Command{
+		{
+			Name:     "plan",
+			Usage:    "write an editable plan of the copy, link or move, without acting",
+			Category: c,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "action,a",
+					Value: "copy",
+					Usage: "action to plan (copy, link, move)",
+				},
+				cli.StringFlag{
+					Name:  "plan_file,f",
+					Usage: "plan file to write (default: standard output)",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "plan format (json, csv); defaults to the plan file extension, or json",
+				},
+			}, venueFlagList...),
+			Action: VenuePlanAction,
+		}, {
+			Name:      "apply",
+			Usage:     "apply a plan written by the plan command",
+			ArgsUsage: "plan_file",
+			Category:  c,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "action,a",
+					Usage: "action to apply (copy, link, move); overrides the plan",
+				},
+				cli.StringFlag{
+					Name:  "src_dir,s",
+					Usage: "source directory; overrides the plan",
+				},
+				cli.StringFlag{
+					Name:  "dest_dir,d",
+					Usage: "destination directory; overrides the plan",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "plan format (json, csv); defaults to the plan file extension",
+				},
+				linkModeFlag,
+			},
+			Action: VenueApplyAction,
+			After:  VenueDryRunAction,
+		},
+	}...)
+}
+
+// VenuePlanAction implements cli.ActionFunc.
+func VenuePlanAction(ctx *cli.Context) error {
+	flags, err := venueFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	action := ctx.String("action")
+	if _, ok := venueOps[action]; !ok {
+		return cli.NewExitError(fmt.Errorf("unknown action %q", action), sysexits.Usage.Int())
+	}
+	names, err := venueNames(flags)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Software.Int())
+	}
+
+	plan := venuePlan(flags, action, names)
+	file := ctx.String("plan_file")
+	format := planFormat(ctx.String("format"), file, "json")
+	var w io.Writer = os.Stdout
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := plan.Write(w, format); err != nil {
+		return cli.NewExitError(fmt.Errorf("error writing plan; %s", err), sysexits.IOError.Int())
+	}
+	return nil
+}
+
+// VenueApplyAction implements cli.ActionFunc.
+func VenueApplyAction(ctx *cli.Context) error {
+	file := ctx.Args().First()
+	if file == "" {
+		return cli.NewExitError(fmt.Errorf("missing plan_file argument"), sysexits.Usage.Int())
+	}
+	format := planFormat(ctx.String("format"), file, "")
+	f, err := os.Open(file)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	defer f.Close()
+	plan, err := actions.ReadPlan(f, format)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error reading plan %q; %s", file, err), sysexits.DataError.Int())
+	}
+
+	for _, o := range []struct {
+		flag string
+		val  *string
+	}{
+		{"action", &plan.Action},
+		{"src_dir", &plan.SrcDir},
+		{"dest_dir", &plan.DestDir},
+	} {
+		if ctx.IsSet(o.flag) {
+			*o.val = ctx.String(o.flag)
+		}
+	}
+	if plan.Action == "" {
+		return cli.NewExitError(fmt.Errorf("missing action flag"), sysexits.Usage.Int())
+	}
+	if plan.SrcDir == "" {
+		plan.SrcDir = "."
+	}
+	if plan.DestDir == "" {
+		plan.DestDir = plan.SrcDir
+	}
+
+	linkMode, err := venueLinkMode(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	flags := VenueFlags{
+		dryRun:   ctx.GlobalBool("dry_run"),
+		srcDir:   plan.SrcDir,
+		destDir:  plan.DestDir,
+		linkMode: linkMode,
+		output:   output,
+	}
+	return venueRun(flags, plan.Action, venuePlanNames(plan))
+}
+
+// venuePlan returns a plan of the named action.
+func venuePlan(flags VenueFlags, action string, names []VenueNames) *actions.Plan {
+	plan := &actions.Plan{
+		Action:  action,
+		SrcDir:  flags.srcDir,
+		DestDir: flags.destDir,
+		Entries: []*actions.PlanEntry{},
+	}
+	for _, name := range names {
+		plan.Entries = append(plan.Entries, name.entry())
+	}
+	return plan
+}
+
+// venuePlanNames returns the names of a plan.
+func venuePlanNames(plan *actions.Plan) []VenueNames {
+	names := []VenueNames{}
+	for _, e := range plan.Entries {
+		names = append(names, VenueNames{
+			orig:     e.Src,
+			dest:     e.Dest,
+			name:     e.Name,
+			snum:     e.Session,
+			tnum:     e.Track,
+			channel:  e.Channel,
+			source:   e.NameSource,
+			override: e.Override,
+		})
+	}
+	return names
+}
+
+// planFormat returns the format flag if set, or else the file extension, or
+// else the default.
+func planFormat(format, file, def string) string {
+	if format != "" {
+		return format
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(file), "."); ext != "" {
+		return ext
+	}
+	return def
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+)
+
+func TestVenuePlanNames(t *testing.T) {
+	names := []VenueNames{
+		{orig: "Track 01-1.wav", dest: "01-01 Kick.wav", name: "Kick", snum: 1, tnum: 1},
+		{orig: "Track 02-1.wav", dest: "01-02 Snare.wav", name: "Snare", snum: 1, tnum: 2},
+	}
+	plan := venuePlan(VenueFlags{srcDir: "src", destDir: "dest"}, "copy", names)
+	if got, want := plan.Action, "copy"; got != want {
+		t.Errorf("venuePlan() action = %s, want %s", got, want)
+	}
+	if got, want := venuePlanNames(plan), names; !reflect.DeepEqual(got, want) {
+		t.Errorf("venuePlanNames() = %v, want %v", got, want)
+	}
+}
+
+func TestVenueApplyPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracks")
+	if err != nil {
+		t.Fatalf("error creating temp dir; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for i := 1; i <= 2; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("Track %02d-1.wav", i)), nil, 0644); err != nil {
+			t.Fatalf("error creating track; %s", err)
+		}
+	}
+
+	// An edited plan, with the second track renamed by hand.
+	plan := &actions.Plan{
+		Entries: []*actions.PlanEntry{
+			{Src: "Track 01-1.wav", Dest: "01-01 Kick.wav"},
+			{Src: "Track 02-1.wav", Dest: "01-02 Snare top.wav"},
+		},
+	}
+	flags := VenueFlags{srcDir: dir, destDir: dir}
+	if err := venueRun(flags, "link", venuePlanNames(plan)); err != nil {
+		t.Fatalf("venueRun() unexpected error; %s", err)
+	}
+	for _, e := range plan.Entries {
+		if _, err := os.Stat(filepath.Join(dir, e.Dest)); err != nil {
+			t.Errorf("%q: %s", e.Dest, err)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/tracks"
+	"github.com/urfave/cli"
 )
 
 func TestIssue7(t *testing.T) {
@@ -55,6 +61,123 @@ func TestIssue7(t *testing.T) {
 	}
 }
 
+func TestVenueNamesFallback(t *testing.T) {
+	setup()
+
+	// Record more tracks than there are stage box inputs.
+	discoverFilesFn = func(_ string, _ ...actions.Filter) ([]string, error) {
+		files := []string{}
+		for i := 1; i <= 64; i++ {
+			files = append(files, fmt.Sprintf("Track %02d-1.wav", i))
+		}
+		return files, nil
+	}
+
+	names, err := venueNames(VenueFlags{
+		dryRun:    true,
+		patchFile: "../testdata/20170906 ICF Ladies Night.html",
+		fallbacks: []string{"original"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got, want := len(names), 64; got != want {
+		t.Fatalf("venueNames() returned %d names, want %d", got, want)
+	}
+
+	for _, name := range names {
+		if name.tnum <= 48 {
+			continue
+		}
+		if got, want := name.source, tracks.NameFromOriginal; got != want {
+			t.Errorf("%q: name source = %s, want %s", name.orig, got, want)
+		}
+		if got, want := name.dest, fmt.Sprintf("01-%02d Track %02d-1.wav", name.tnum, name.tnum); got != want {
+			t.Errorf("%q: incorrect file name %q, want %q", name.orig, got, want)
+		}
+	}
+}
+
 func setup() {
 	resetDiscoverFiles()
 }
+
+func TestVenueReport(t *testing.T) {
+	var b bytes.Buffer
+	venueReport(&b, []VenueNames{
+		{snum: 1, tnum: 1, source: tracks.NameFromChannel},
+		{snum: 1, tnum: 2, source: tracks.NameFromChannel},
+		{snum: 1, tnum: 3, source: tracks.NameFromOverride, override: &actions.Applied{
+			Override: &actions.Override{Track: 3, Name: "Bass"},
+			Previous: "Ch 3",
+		}},
+	})
+	want := `Name sources:
+  channel (2): 01-01 01-02
+  override (1): 01-03
+Overrides:
+  01-03 "Ch 3" --> "Bass" by track 3 ()
+`
+	if got := b.String(); got != want {
+		t.Errorf("venueReport() = %q, want %q", got, want)
+	}
+}
+
+func TestVenueFlagsMaxLength(t *testing.T) {
+	defer func(exiter func(int)) { cli.OsExiter = exiter }(cli.OsExiter)
+	cli.OsExiter = func(int) {}
+	for _, tt := range []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"--max_filename_length", "9"}, true},
+		{[]string{"--max_filename_length", "8"}, false},
+		{[]string{"--max_filename_length", "9", "--encoding", "flac"}, false},
+		{[]string{"--max_filename_length", "10", "--encoding", "flac"}, true},
+	} {
+		app := cli.NewApp()
+		app.Commands = []cli.Command{{
+			Name:  "copy",
+			Flags: commandNamed("copy").Flags,
+			Action: func(ctx *cli.Context) error {
+				_, err := venueFlags(ctx)
+				return err
+			},
+		}}
+		app.Writer, app.ErrWriter = ioutil.Discard, ioutil.Discard
+		args := append([]string{"tracks", "copy", "--patch_file", "patch.html"}, tt.args...)
+		if err := app.Run(args); (err == nil) != tt.ok {
+			t.Errorf("venueFlags(%v) = %v, want ok %v", tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestVenueLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venue_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "Track 01-1.wav")
+	if err := ioutil.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	var buf bytes.Buffer
+	link := venueLink(VenueFlags{linkMode: actions.CloneLink, stdout: &buf})
+	op := &actions.Operation{Src: src, Dest: filepath.Join(dir, "missing", "01-01 Kick.wav")}
+	if err := link(op); err == nil {
+		t.Fatalf("venueLink() expected error")
+	}
+	if got := buf.String(); got != "" {
+		t.Errorf("venueLink() of a failed link reported %q", got)
+	}
+
+	op = &actions.Operation{Src: src, Dest: filepath.Join(dir, "01-01 Kick.wav")}
+	if err := link(op); err != nil {
+		t.Fatalf("venueLink() unexpected error; %s", err)
+	}
+	if got, want := buf.String() != "", op.LinkMode != actions.CloneLink.String(); got != want {
+		t.Errorf("venueLink() reported %q with %s links", buf.String(), op.LinkMode)
+	}
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+// manifestFlagList holds the flags of commands that write a manifest.
+var manifestFlagList = []cli.Flag{
+	cli.StringFlag{
+		Name:  "checksum",
+		Usage: "write a checksum manifest to the destination (md5, sha256, xxh64)",
+	},
+	cli.StringFlag{
+		Name:  "manifest_format",
+		Value: actions.SumFormat.String(),
+		Usage: "checksum manifest format (sum, mhl)",
+	},
+}
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:      "verify",
+		Usage:     "verify a directory against its checksum manifest",
+		ArgsUsage: "[dir]",
+		Category:  "venue",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "manifest_file,m",
+				Usage: "manifest file (default: MD5SUMS, SHA256SUMS, XXH64SUMS or tracks.mhl in dir)",
+			},
+			cli.StringFlag{
+				Name:  "manifest_format",
+				Usage: "manifest format (sum, mhl); defaults to mhl for a .mhl file, or else sum",
+			},
+		},
+		Action: VerifyAction,
+	})
+}
+
+// VerifyAction implements cli.ActionFunc.
+func VerifyAction(ctx *cli.Context) error {
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	dir := ctx.Args().First()
+	if dir == "" {
+		dir = "."
+	}
+	file := ctx.String("manifest_file")
+	if file == "" {
+		file = findManifest(dir)
+		if file == "" {
+			return cli.NewExitError(fmt.Errorf("no manifest found in %q", dir), sysexits.Usage.Int())
+		}
+	}
+	mf, err := manifestFileFormat(file, ctx.String("manifest_format"))
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	m, err := actions.ReadManifest(f, mf)
+	f.Close()
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error reading manifest %q; %s", file, err), sysexits.DataError.Int())
+	}
+
+	abs, _ := filepath.Abs(file)
+	absDir, _ := filepath.Abs(dir)
+	ignore := []string{}
+	if filepath.Dir(abs) == absDir {
+		ignore = append(ignore, filepath.Base(file))
+	}
+	v, err := actions.VerifyManifest(dir, m, ignore...)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error verifying %q; %s", dir, err), sysexits.IOError.Int())
+	}
+
+	res := &manifestVerification{dir, file, m.Algorithm.String(), v.Passed(), *v}
+	err = output.render(os.Stdout, res, func(w io.Writer) {
+		writeVerification(w, res, false)
+	})
+	if err != nil {
+		return cli.NewExitError(err, sysexits.IOError.Int())
+	}
+	if !v.Passed() {
+		return cli.NewExitError(fmt.Errorf("verification of %q failed", dir), sysexits.DataError.Int())
+	}
+	return nil
+}
+
+// manifestVerification is the verification of a directory against a manifest.
+type manifestVerification struct {
+	Dir                  string `json:"dir" yaml:"dir"`
+	Manifest             string `json:"manifest" yaml:"manifest"`
+	Algorithm            string `json:"algorithm" yaml:"algorithm"`
+	Passed               bool   `json:"passed" yaml:"passed"`
+	actions.Verification `yaml:",inline"`
+}
+
+// writeVerification writes the missing, extra and corrupt files of v, and a
+// summary. If withDir is true, the files and summary include the directory.
+func writeVerification(w io.Writer, v *manifestVerification, withDir bool) {
+	for _, r := range []struct {
+		desc  string
+		files []string
+	}{
+		{"missing", v.Missing},
+		{"extra", v.Extra},
+		{"corrupt", v.Corrupt},
+	} {
+		for _, file := range r.files {
+			if withDir {
+				file = filepath.Join(v.Dir, file)
+			}
+			fmt.Fprintf(w, "%s: %s\n", r.desc, file)
+		}
+	}
+	if withDir {
+		fmt.Fprintf(w, "%s: ", v.Dir)
+	}
+	fmt.Fprintf(w, "%d ok, %d missing, %d extra, %d corrupt (%s)\n",
+		len(v.OK), len(v.Missing), len(v.Extra), len(v.Corrupt), v.Algorithm)
+}
+
+// manifestFileFormat returns the named manifest format, or if "", the format
+// of the manifest file: mhl for a .mhl file, or else sum.
+func manifestFileFormat(file, format string) (actions.ManifestFormat, error) {
+	if format == "" {
+		if filepath.Ext(file) == ".mhl" {
+			return actions.MHLFormat, nil
+		}
+		return actions.SumFormat, nil
+	}
+	return actions.ParseManifestFormat(format)
+}
+
+// findManifest returns the first manifest found in dir, or "" if none.
+func findManifest(dir string) string {
+	for _, a := range []actions.HashAlgorithm{actions.MD5, actions.SHA256, actions.XXH64} {
+		for _, f := range []actions.ManifestFormat{actions.SumFormat, actions.MHLFormat} {
+			file := filepath.Join(dir, actions.ManifestName(a, f))
+			if _, err := os.Stat(file); err == nil {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// venueManifest writes, or updates, the checksum manifest of each destination
+// directory with the checksums of the named files.
+func venueManifest(flags VenueFlags, dirs []string, names []VenueNames) ([]string, error) {
+	files := []string{}
+	for _, dir := range dirs {
+		file := filepath.Join(dir, actions.ManifestName(flags.checksum, flags.manifestFormat))
+		m := actions.NewManifest(flags.checksum)
+		// Keep the entries of earlier runs, e.g. of other sessions.
+		if f, err := os.Open(file); err == nil {
+			old, err := actions.ReadManifest(f, flags.manifestFormat)
+			f.Close()
+			if err == nil && old.Algorithm == flags.checksum {
+				m.Entries = old.Entries
+			}
+		}
+		for _, name := range names {
+			e, err := m.Checksum(dir, name.dest)
+			if err != nil {
+				return files, err
+			}
+			m.Set(e)
+		}
+
+		tmp := filepath.Join(dir, "."+filepath.Base(file)+".tmp")
+		f, err := os.Create(tmp)
+		if err != nil {
+			return files, err
+		}
+		err = m.Write(f, flags.manifestFormat)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp, file)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return files, fmt.Errorf("error writing manifest %q; %s", file, err)
+		}
+		flags.printf("Manifest: %q (%d files)\n", file, len(m.Entries))
+		files = append(files, file)
+	}
+	return files, nil
+}
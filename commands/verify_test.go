@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func TestVenueManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"01-01 Kick.wav", "02-01 Kick.wav"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	if got := findManifest(dir); got != "" {
+		t.Errorf("findManifest() = %q before writing, want \"\"", got)
+	}
+
+	// Write the manifest one session at a time, as the watch command does.
+	flags := VenueFlags{manifest: true, checksum: actions.XXH64, manifestFormat: actions.MHLFormat}
+	for _, name := range []VenueNames{{dest: "01-01 Kick.wav"}, {dest: "02-01 Kick.wav"}} {
+		if _, err := venueManifest(flags, []string{dir}, []VenueNames{name}); err != nil {
+			t.Fatalf("venueManifest() unexpected error %s", err)
+		}
+	}
+
+	file := findManifest(dir)
+	if got, want := file, filepath.Join(dir, "tracks.mhl"); got != want {
+		t.Fatalf("findManifest() = %q, want %q", got, want)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer f.Close()
+	m, err := actions.ReadManifest(f, actions.MHLFormat)
+	if err != nil {
+		t.Fatalf("ReadManifest() unexpected error %s", err)
+	}
+	v, err := actions.VerifyManifest(dir, m, "tracks.mhl")
+	if err != nil {
+		t.Fatalf("VerifyManifest() unexpected error %s", err)
+	}
+	if got, want := len(v.OK), 2; !v.Passed() || got != want {
+		t.Errorf("VerifyManifest() = %+v, want %d ok", v, want)
+	}
+}
+
+func TestVenueFlagsChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(exiter func(int)) { cli.OsExiter = exiter }(cli.OsExiter)
+	cli.OsExiter = func(int) {}
+	for _, tt := range []struct {
+		desc string
+		args []string
+		ok   bool
+	}{
+		{"src_dir", []string{"--src_dir", dir}, false},
+		{"same dest_dir", []string{"--src_dir", dir, "--dest_dir", dir + "/."}, false},
+		{"dest_dir", []string{"--src_dir", dir, "--dest_dir", filepath.Join(dir, "stems")}, true},
+	} {
+		app := cli.NewApp()
+		app.Commands = []cli.Command{{
+			Name:  "move",
+			Flags: commandNamed("move").Flags,
+			Action: func(ctx *cli.Context) error {
+				_, err := venueFlags(ctx)
+				return err
+			},
+		}}
+		app.Writer, app.ErrWriter = ioutil.Discard, ioutil.Discard
+		args := append([]string{"tracks", "move", "--patch_file", "patch.html", "--checksum", "md5"}, tt.args...)
+		if err := app.Run(args); (err == nil) != tt.ok {
+			t.Errorf("%s: venueFlags() = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+	}
+}
+
+func TestWriteVerification(t *testing.T) {
+	v := &manifestVerification{Dir: "show", Algorithm: "md5", Verification: actions.Verification{
+		OK:      []string{"01-01 Kick.wav"},
+		Missing: []string{"01-02 Snare.wav"},
+		Extra:   []string{"notes.txt"},
+	}}
+	for _, tt := range []struct {
+		withDir bool
+		text    string
+	}{
+		{false, "missing: 01-02 Snare.wav\nextra: notes.txt\n1 ok, 1 missing, 1 extra, 0 corrupt (md5)\n"},
+		{true, "missing: show/01-02 Snare.wav\nextra: show/notes.txt\nshow: 1 ok, 1 missing, 1 extra, 0 corrupt (md5)\n"},
+	} {
+		var buf bytes.Buffer
+		writeVerification(&buf, v, tt.withDir)
+		if got, want := buf.String(), tt.text; got != want {
+			t.Errorf("writeVerification(%v) = %q, want %q", tt.withDir, got, want)
+		}
+	}
+}
+
+func TestManifestFileFormat(t *testing.T) {
+	for _, tt := range []struct {
+		file, format string
+		want         actions.ManifestFormat
+		ok           bool
+	}{
+		{"MD5SUMS", "", actions.SumFormat, true},
+		{"tracks.mhl", "", actions.MHLFormat, true},
+		{"tracks.mhl", "sum", actions.SumFormat, true},
+		{"MD5SUMS", "bogus", actions.SumFormat, false},
+	} {
+		got, err := manifestFileFormat(tt.file, tt.format)
+		if (err == nil) != tt.ok {
+			t.Errorf("manifestFileFormat(%q, %q) error = %v, want ok %v", tt.file, tt.format, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("manifestFileFormat(%q, %q) = %s, want %s", tt.file, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kward/golib/os/sysexits"
+	"github.com/kward/tracks/actions"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	commands = append(commands, cli.Command{
+		Name:     "watch",
+		Usage:    "watch the recording folder, and act on each session once it is finished",
+		Category: "venue",
+		Flags: flagLists([]cli.Flag{
+			cli.StringFlag{
+				Name:  "action,a",
+				Value: "copy",
+				Usage: "action to take on a finished session (copy, link, move)",
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Value: 5 * time.Second,
+				Usage: "how often to check the recording folder",
+			},
+			cli.DurationFlag{
+				Name:  "settle",
+				Value: 30 * time.Second,
+				Usage: "how long the files of a session must stop growing before it is finished",
+			},
+			cli.StringFlag{
+				Name:  "log_file,l",
+				Usage: "file to append the log to (default: standard error)",
+			},
+		}, venueFlagList, copyFlagList, convertFlagList, manifestFlagList, []cli.Flag{linkModeFlag}),
+		Action: VenueWatchAction,
+		After:  VenueDryRunAction,
+	})
+}
+
+// VenueWatchAction implements cli.ActionFunc.
+func VenueWatchAction(ctx *cli.Context) error {
+	flags, err := venueFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	op := ctx.String("action")
+	if _, ok := venueOps[op]; !ok {
+		return cli.NewExitError(fmt.Errorf("unknown action %q", op), sysexits.Usage.Int())
+	}
+
+	var lw io.Writer = os.Stderr
+	if file := ctx.String("log_file"); file != "" {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return cli.NewExitError(err, sysexits.IOError.Int())
+		}
+		defer f.Close()
+		lw = f
+	}
+
+	w := newWatcher(flags, op, ctx.Duration("settle"), log.New(lw, "", log.LstdFlags))
+	w.log.Printf("watching %q every %s", flags.srcDir, ctx.Duration("interval"))
+	for {
+		if err := w.poll(time.Now()); err != nil {
+			return err
+		}
+		time.Sleep(ctx.Duration("interval"))
+	}
+}
+
+// fileState records when a file was last seen to change size.
+type fileState struct {
+	size  int64
+	since time.Time
+}
+
+// watcher acts on the sessions of a recording folder once they are finished,
+// i.e. once all their files have complete wave headers, and their sizes have
+// been stable for the settle duration.
+type watcher struct {
+	flags  VenueFlags
+	op     string
+	settle time.Duration
+	log    *log.Logger
+	files  map[string]fileState // Keyed by source filename.
+	done   map[int]bool         // Keyed by session number.
+	status map[int]string       // Last logged status, keyed by session number.
+	// planned is set once the tracks have been planned, and any unused
+	// overrides logged.
+	planned bool
+	ignored map[string]bool // The ignored files logged.
+}
+
+// newWatcher returns a watcher, logging to l. The output of the action is
+// logged too.
+func newWatcher(flags VenueFlags, op string, settle time.Duration, l *log.Logger) *watcher {
+	flags.stdout = &logWriter{log: l}
+	return &watcher{
+		flags:   flags,
+		op:      op,
+		settle:  settle,
+		log:     l,
+		files:   map[string]fileState{},
+		done:    map[int]bool{},
+		status:  map[int]string{},
+		ignored: map[string]bool{},
+	}
+}
+
+// poll checks the recording folder once, and acts on any finished sessions.
+// Only a failure of the action itself is returned as an error; anything else
+// is logged, and retried on the next poll.
+func (w *watcher) poll(now time.Time) error {
+	plan, err := planTracks(w.flags)
+	if err != nil {
+		w.logStatus(0, fmt.Sprintf("waiting; %s", err))
+		return nil
+	}
+	if !w.planned {
+		// The overrides are the same on every poll, so are only reported once.
+		for _, o := range plan.Unused {
+			w.log.Printf("unused override %s", o)
+		}
+		w.planned = true
+	}
+	// Renamed files are ignored, so each is only reported once.
+	for _, file := range plan.Ignored {
+		if !w.ignored[file] {
+			w.log.Printf("ignoring %q", file)
+			w.ignored[file] = true
+		}
+	}
+	names := venuePlanNames(plan)
+
+	sessions := map[int][]VenueNames{}
+	for _, name := range names {
+		sessions[name.snum] = append(sessions[name.snum], name)
+	}
+	snums := []int{}
+	for snum := range sessions {
+		snums = append(snums, snum)
+	}
+	sort.Ints(snums)
+
+	for _, snum := range snums {
+		if w.done[snum] {
+			continue
+		}
+		ns := sessions[snum]
+		if w.copied(ns) {
+			w.logStatus(snum, fmt.Sprintf("session %d: already done", snum))
+			w.done[snum] = true
+			continue
+		}
+		if pending := w.pending(now, ns); pending > 0 {
+			w.logStatus(snum, fmt.Sprintf("session %d: waiting for %d of %d tracks", snum, pending, len(ns)))
+			continue
+		}
+
+		w.log.Printf("session %d: finished; %s %d tracks", snum, venueOps[w.op].desc, len(ns))
+		if err := venueRun(w.flags, w.op, ns); err != nil {
+			w.log.Printf("session %d: %s", snum, err)
+			return err
+		}
+		w.log.Printf("session %d: done", snum)
+		w.done[snum] = true
+	}
+	return nil
+}
+
+// pending returns the number of tracks that are still being recorded.
+func (w *watcher) pending(now time.Time, names []VenueNames) int {
+	pending := 0
+	for _, name := range names {
+		path := filepath.Join(w.flags.srcDir, name.orig)
+		fi, err := os.Stat(path)
+		if err != nil {
+			pending++
+			continue
+		}
+		st, ok := w.files[name.orig]
+		if !ok || st.size != fi.Size() {
+			w.files[name.orig] = fileState{fi.Size(), now}
+			pending++
+			continue
+		}
+		if now.Sub(st.since) < w.settle {
+			pending++
+			continue
+		}
+		if ok, err := actions.WaveComplete(path); err != nil || !ok {
+			pending++
+		}
+	}
+	return pending
+}
+
+// copied returns true if the destination files of a session already exist,
+// and have the same size as the source files, e.g. from an earlier watch.
+func (w *watcher) copied(names []VenueNames) bool {
+	if w.op == "move" {
+		return false // The source files are gone once moved.
+	}
+	for _, name := range names {
+		src, err := os.Stat(filepath.Join(w.flags.srcDir, name.orig))
+		if err != nil {
+			return false
+		}
+		dest, err := os.Stat(filepath.Join(w.flags.destDir, name.dest))
+		if err != nil || dest.Size() != src.Size() {
+			return false
+		}
+	}
+	return true
+}
+
+// logStatus logs the status of a session, but only when it changes.
+func (w *watcher) logStatus(snum int, status string) {
+	if w.status[snum] == status {
+		return
+	}
+	w.status[snum] = status
+	w.log.Print(status)
+}
+
+// logWriter writes each line written to it to a logger.
+type logWriter struct {
+	log *log.Logger
+	buf []byte // A partial line.
+}
+
+// Write implements the io.Writer interface.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log.Print(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kward/tracks/actions"
+	"github.com/kward/tracks/actions/wavetest"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	setup()
+
+	dir, err := ioutil.TempDir("", "watch_test")
+	if err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+	defer os.RemoveAll(dir)
+	srcDir, destDir := filepath.Join(dir, "src"), filepath.Join(dir, "dest")
+	for _, d := range []string{srcDir, destDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+	write := func(file string, data []byte) {
+		if err := ioutil.WriteFile(filepath.Join(srcDir, file), data, 0644); err != nil {
+			t.Fatalf("unexpected error; %s", err)
+		}
+	}
+
+	// Session 1 is finished, and session 2 is still recording.
+	write("Track 02-1.wav", wavetest.Silence(1, 100).Bytes())
+	write("Track 03-1.wav", wavetest.Silence(1, 100).Bytes())
+	write("Track 02-2.wav", wavetest.Wave{Channels: 1, Samples: make([]float64, 50), Recording: true}.Bytes())
+	write("notes.wav", wavetest.Silence(1, 10).Bytes())
+
+	// An override of a track that wasn't recorded.
+	overrides := filepath.Join(dir, "overrides.csv")
+	if err := ioutil.WriteFile(overrides, []byte("track,name\n40,Spare\n"), 0644); err != nil {
+		t.Fatalf("unexpected error; %s", err)
+	}
+
+	logs := &bytes.Buffer{}
+	w := newWatcher(VenueFlags{
+		dryRun:        false,
+		patchFile:     "../testdata/20170906 ICF Ladies Night.html",
+		srcDir:        srcDir,
+		destDir:       destDir,
+		policy:        actions.Portable,
+		maxLength:     actions.DefaultMaxFilenameLength,
+		fallbacks:     []string{"number"},
+		overridesFile: overrides,
+	}, "copy", 10*time.Second, log.New(logs, "", 0))
+
+	exists := func(file string) bool {
+		_, err := os.Stat(filepath.Join(destDir, file))
+		return err == nil
+	}
+
+	start := time.Now()
+	for _, tt := range []struct {
+		desc    string
+		elapsed time.Duration
+		update  func()
+		s1, s2  bool // Whether the sessions should have been copied.
+	}{
+		{"first poll", 0, nil, false, false},
+		{"settling", 5 * time.Second, nil, false, false},
+		{"session 1 settled", 11 * time.Second, nil, true, false},
+		{"session 2 stopped", 12 * time.Second, func() { write("Track 02-2.wav", wavetest.Silence(1, 100).Bytes()) }, true, false},
+		{"session 2 settled", 23 * time.Second, nil, true, true},
+	} {
+		if tt.update != nil {
+			tt.update()
+		}
+		if err := w.poll(start.Add(tt.elapsed)); err != nil {
+			t.Fatalf("%s: poll() unexpected error; %s", tt.desc, err)
+		}
+		if got, want := exists("01-02 Kick 91, iKick.wav"), tt.s1; got != want {
+			t.Errorf("%s: session 1 copied = %v, want %v", tt.desc, got, want)
+		}
+		if got, want := exists("02-02 Kick 91, iKick.wav"), tt.s2; got != want {
+			t.Errorf("%s: session 2 copied = %v, want %v", tt.desc, got, want)
+		}
+	}
+
+	for _, want := range []string{
+		"session 1: waiting for 2 of 2 tracks",
+		"session 1: done",
+		"session 2: waiting for 1 of 1 tracks",
+		"session 2: done",
+		// The output of the copy.
+		"Copying:",
+		"Verified copies:",
+		"Name sources:",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, logs)
+		}
+	}
+	for _, msg := range []string{"unused override", `ignoring "notes.wav"`} {
+		if got, want := strings.Count(logs.String(), msg), 1; got != want {
+			t.Errorf("logged %q %d times, want %d:\n%s", msg, got, want, logs)
+		}
+	}
+
+	// A new watcher skips sessions that are already done.
+	logs.Reset()
+	w = newWatcher(w.flags, w.op, w.settle, log.New(logs, "", 0))
+	if err := w.poll(start); err != nil {
+		t.Fatalf("poll() unexpected error; %s", err)
+	}
+	if got, want := logs.String(), "unused override track 40 (line 2)\nignoring \"notes.wav\"\nsession 1: already done\nsession 2: already done\n"; got != want {
+		t.Errorf("poll() logged %q, want %q", got, want)
+	}
+}
@@ -2,13 +2,43 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/kward/golib/os/sysexits"
 	"github.com/kward/tracks/actions"
 	"github.com/urfave/cli"
 )
 
+// formatFlagList holds the flags choosing the format of converted files.
+var formatFlagList = []cli.Flag{
+	cli.StringFlag{
+		Name:  "sample_format",
+		Usage: "convert to the sample format (16, 24, 32, 32f); reduced resolutions are dithered",
+	},
+	cli.IntFlag{
+		Name:  "sample_rate",
+		Usage: "resample to the sample rate, in Hz (e.g. 48000)",
+	},
+	cli.StringFlag{
+		Name:  "encoding",
+		Usage: "encoding of the converted files (wav, flac); flac is tagged with the track names, and lossless for integer samples only, so float samples need a sample_format, and are dithered to it",
+	},
+}
+
+// convertFlagList holds the flags of commands that convert wave files.
+var convertFlagList = flagLists(formatFlagList, []cli.Flag{
+	cli.IntFlag{
+		Name:  "jobs,j",
+		Value: runtime.NumCPU(),
+		Usage: "number of files to convert at once",
+	},
+})
+
 func init() {
 	c := "wave"
 	commands = append(commands, []cli.Command{
@@ -21,6 +51,16 @@ func init() {
 			},
 			Action: WaveCheckAction,
 		},
+		{
+			Name:      "convert",
+			Usage:     "convert the sample format, rate or encoding of wave and FLAC files",
+			ArgsUsage: "file...",
+			Category:  c,
+			Flags: flagLists([]cli.Flag{
+				cli.StringFlag{Name: "dest_dir,d", Usage: "destination directory"},
+			}, convertFlagList),
+			Action: WaveConvertAction,
+		},
 		{
 			Name:     "dump",
 			Usage:    "dump raw wave sample data",
@@ -49,6 +89,10 @@ func WaveCheckAction(ctx *cli.Context) error {
 	if !ctx.IsSet("file") {
 		return cli.NewExitError(fmt.Errorf("--file flag missing"), sysexits.Usage.Int())
 	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
 
 	filename := ctx.String("file")
 	f, err := os.Open(filename)
@@ -57,29 +101,133 @@ func WaveCheckAction(ctx *cli.Context) error {
 	}
 	defer f.Close()
 
-	if err := actions.WaveCheck(filename); err != nil {
+	silences, err := actions.WaveCheck(filename)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.DataError.Int())
+	}
+	return output.render(os.Stdout, struct {
+		File     string            `json:"file" yaml:"file"`
+		Silences []actions.Silence `json:"silences" yaml:"silences"`
+	}{filename, silences}, func(w io.Writer) {
+		for _, s := range silences {
+			fmt.Fprintln(w, s)
+		}
+	})
+}
+
+// WaveConvertAction implements cli.ActionFunc.
+func WaveConvertAction(ctx *cli.Context) error {
+	if !ctx.IsSet("dest_dir") {
+		return cli.NewExitError(fmt.Errorf("missing %s flag", "dest_dir"), sysexits.Usage.Int())
+	}
+	if !ctx.Args().Present() {
+		return cli.NewExitError(fmt.Errorf("missing file argument"), sysexits.Usage.Int())
+	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	c, err := convertFlags(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
+	if c == nil {
+		return cli.NewExitError(fmt.Errorf("missing sample_format, sample_rate or encoding flag"), sysexits.Usage.Int())
+	}
+
+	dryRun := ctx.GlobalBool("dry_run")
+	ops := []*waveConversion{}
+	for _, file := range ctx.Args() {
+		base := filepath.Base(file)
+		dest := filepath.Join(ctx.String("dest_dir"), strings.TrimSuffix(base, filepath.Ext(base))+c.Encoding.Ext())
+		if abs, _ := filepath.Abs(file); abs != "" {
+			if absDest, _ := filepath.Abs(dest); abs == absDest {
+				return cli.NewExitError(fmt.Errorf("%q would be converted onto itself", file), sysexits.Usage.Int())
+			}
+		}
+		ops = append(ops, &waveConversion{Src: file, Dest: dest, Status: actions.OperationDryRun})
+	}
+	var mu sync.Mutex
+	err = parallel(ctx.Int("jobs"), len(ops), func(i int) error {
+		op := ops[i]
+		if !dryRun {
+			if _, _, err := c.Convert(op.Src, op.Dest); err != nil {
+				op.Status, op.Error = actions.OperationFailed, err.Error()
+				return err
+			}
+			op.Status = actions.OperationDone
+		}
+		mu.Lock()
+		output.printf("  %q --> %q\n", op.Src, op.Dest)
+		mu.Unlock()
+		return nil
+	})
+	if rerr := output.render(os.Stdout, ops, nil); rerr != nil && err == nil {
+		return cli.NewExitError(rerr, sysexits.IOError.Int())
+	}
+	if err != nil {
 		return cli.NewExitError(err, sysexits.DataError.Int())
 	}
 	return nil
 }
 
+// waveConversion describes the conversion of a single file.
+type waveConversion struct {
+	Src    string                  `json:"src" yaml:"src"`
+	Dest   string                  `json:"dest" yaml:"dest"`
+	Status actions.OperationStatus `json:"status" yaml:"status"`
+	Error  string                  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// convertFlags returns the converter chosen by the user, or nil if no
+// conversion was asked for.
+func convertFlags(ctx *cli.Context) (*actions.Converter, error) {
+	if ctx.String("sample_format") == "" && ctx.Int("sample_rate") == 0 && ctx.String("encoding") == "" {
+		return nil, nil
+	}
+	c := &actions.Converter{SampleRate: ctx.Int("sample_rate")}
+	if c.SampleRate < 0 {
+		return nil, fmt.Errorf("invalid sample rate %d", c.SampleRate)
+	}
+	if f := ctx.String("sample_format"); f != "" {
+		var err error
+		if c.Format, err = actions.ParseSampleFormat(f); err != nil {
+			return nil, err
+		}
+	}
+	if e := ctx.String("encoding"); e != "" {
+		var err error
+		if c.Encoding, err = actions.ParseAudioEncoding(e); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 // WaveDumpAction implements cli.ActionFunc.
 func WaveDumpAction(ctx *cli.Context) error {
 	if !ctx.IsSet("file") {
 		return cli.NewExitError(fmt.Errorf("missing %s flag", "file"), sysexits.Usage.Int())
 	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
 
 	block, frames, err := actions.WaveDump(ctx.String("file"), ctx.Duration("offset"), ctx.Duration("length"))
 	if err != nil {
 		return err
 	}
-	fmt.Printf("frames: %d\n", frames)
-
-	for o := 0; o < frames; o += 4 {
-		d := block[o : o+4]
-		fmt.Printf("%08x  %g %g %g %g\n", o, d[0], d[1], d[2], d[3])
-	}
-	return nil
+	return output.render(os.Stdout, struct {
+		Frames  int       `json:"frames" yaml:"frames"`
+		Samples []float32 `json:"samples" yaml:"samples"`
+	}{frames, block[:frames]}, func(w io.Writer) {
+		fmt.Fprintf(w, "frames: %d\n", frames)
+		for o := 0; o+4 <= frames; o += 4 {
+			d := block[o : o+4]
+			fmt.Fprintf(w, "%08x  %g %g %g %g\n", o, d[0], d[1], d[2], d[3])
+		}
+	})
 }
 
 // WaveInfoAction implements cli.ActionFunc.
@@ -87,10 +235,13 @@ func WaveInfoAction(ctx *cli.Context) error {
 	if !ctx.IsSet("file") {
 		return cli.NewExitError(fmt.Errorf("missing %s flag", "file"), sysexits.Usage.Int())
 	}
+	output, err := outputFlag(ctx)
+	if err != nil {
+		return cli.NewExitError(err, sysexits.Usage.Int())
+	}
 	info, err := actions.WaveInfo(ctx.String("file"))
 	if err != nil {
 		return err
 	}
-	fmt.Println(info)
-	return nil
+	return output.render(os.Stdout, info, func(w io.Writer) { fmt.Fprintln(w, info) })
 }
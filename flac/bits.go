@@ -0,0 +1,247 @@
+package flac
+
+import "io"
+
+//-----------------------------------------------------------------------------
+// Writing
+
+// bitWriter writes bits, most significant first, to a byte slice.
+type bitWriter struct {
+	buf []byte
+	acc uint64 // Bits not yet in buf.
+	n   uint   // Number of bits in acc, less than 8 between calls.
+}
+
+// writeBits writes the n low bits of v, with n at most 56.
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	if n == 0 {
+		return
+	}
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		w.n -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.n))
+	}
+	w.acc &= 1<<w.n - 1
+}
+
+// writeSigned writes v as an n bit two's complement number.
+func (w *bitWriter) writeSigned(v int64, n uint) { w.writeBits(uint64(v), n) }
+
+// writeUnary writes q zeros, followed by a one.
+func (w *bitWriter) writeUnary(q uint64) {
+	for ; q >= 32; q -= 32 {
+		w.writeBits(0, 32)
+	}
+	w.writeBits(1, uint(q)+1)
+}
+
+// writeRice writes the Rice code of v with parameter k.
+func (w *bitWriter) writeRice(v int64, k uint) {
+	u := zigzag(v)
+	w.writeUnary(u >> k)
+	w.writeBits(u, k)
+}
+
+// align pads with zeros to a byte boundary.
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.writeBits(0, 8-w.n)
+	}
+}
+
+// writeUTF8 writes v with the extended UTF-8 coding of frame numbers.
+func (w *bitWriter) writeUTF8(v uint64) {
+	if v < 0x80 {
+		w.writeBits(v, 8)
+		return
+	}
+	// The number of continuation bytes, each holding 6 bits.
+	n := uint(1)
+	for ; n < 6 && v >= 1<<(5*n+6); n++ {
+	}
+	lead := uint64(0xff00>>(n+1)) & 0xff
+	w.writeBits(lead|v>>(6*n), 8)
+	for i := n; i > 0; i-- {
+		w.writeBits(0x80|v>>(6*(i-1))&0x3f, 8)
+	}
+}
+
+// zigzag maps signed to unsigned numbers, i.e. 0, -1, 1, -2 to 0, 1, 2, 3.
+func zigzag(v int64) uint64 { return uint64(v<<1 ^ v>>63) }
+
+//-----------------------------------------------------------------------------
+// Reading
+
+// bitReader reads bits, most significant first, and keeps the CRCs of the
+// bytes read.
+type bitReader struct {
+	r     io.ByteReader
+	acc   uint64
+	n     uint // Number of bits in acc.
+	crc8  byte
+	crc16 uint16
+}
+
+// resetCRC restarts the CRCs, at the start of a frame.
+func (r *bitReader) resetCRC() { r.crc8, r.crc16 = 0, 0 }
+
+func (r *bitReader) readByte() error {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	r.crc8 = crc8Table[r.crc8^b]
+	r.crc16 = r.crc16<<8 ^ crc16Table[byte(r.crc16>>8)^b]
+	r.acc = r.acc<<8 | uint64(b)
+	r.n += 8
+	return nil
+}
+
+// readBits reads n bits, with n at most 56.
+func (r *bitReader) readBits(n uint) (uint64, error) {
+	for r.n < n {
+		if err := r.readByte(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+	r.n -= n
+	v := r.acc >> r.n & (1<<n - 1)
+	r.acc &= 1<<r.n - 1
+	return v, nil
+}
+
+// readSigned reads an n bit two's complement number.
+func (r *bitReader) readSigned(n uint) (int64, error) {
+	v, err := r.readBits(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// readUnary reads zeros up to a one, and returns the number of zeros.
+func (r *bitReader) readUnary() (uint64, error) {
+	var q uint64
+	for {
+		if r.n == 0 {
+			if err := r.readByte(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+		}
+		for r.n > 0 {
+			r.n--
+			if r.acc>>r.n&1 == 1 {
+				r.acc &= 1<<r.n - 1
+				return q, nil
+			}
+			q++
+		}
+	}
+}
+
+// readRice reads a Rice code with parameter k.
+func (r *bitReader) readRice(k uint) (int64, error) {
+	q, err := r.readUnary()
+	if err != nil {
+		return 0, err
+	}
+	low, err := r.readBits(k)
+	if err != nil {
+		return 0, err
+	}
+	u := q<<k | low
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+// align discards the bits up to a byte boundary.
+func (r *bitReader) align() {
+	r.n -= r.n % 8
+	r.acc &= 1<<r.n - 1
+}
+
+// readUTF8 reads a number with the extended UTF-8 coding of frame numbers.
+func (r *bitReader) readUTF8() (uint64, error) {
+	b, err := r.readBits(8)
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return b, nil
+	}
+	n := uint(0) // Continuation bytes.
+	for mask := uint64(0x40); b&mask != 0 && n < 7; mask >>= 1 {
+		n++
+	}
+	if n == 0 || n > 6 {
+		return 0, errInvalidUTF8
+	}
+	v := b & (0x3f >> n)
+	for ; n > 0; n-- {
+		c, err := r.readBits(8)
+		if err != nil {
+			return 0, err
+		}
+		if c&0xc0 != 0x80 {
+			return 0, errInvalidUTF8
+		}
+		v = v<<6 | c&0x3f
+	}
+	return v, nil
+}
+
+//-----------------------------------------------------------------------------
+// CRCs
+
+var (
+	crc8Table  [256]byte   // Polynomial x^8 + x^2 + x + 1.
+	crc16Table [256]uint16 // Polynomial x^16 + x^15 + x^2 + 1.
+)
+
+func init() {
+	for i := range crc8Table {
+		c := byte(i)
+		for j := 0; j < 8; j++ {
+			if c&0x80 != 0 {
+				c = c<<1 ^ 0x07
+			} else {
+				c <<= 1
+			}
+		}
+		crc8Table[i] = c
+	}
+	for i := range crc16Table {
+		c := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x8005
+			} else {
+				c <<= 1
+			}
+		}
+		crc16Table[i] = c
+	}
+}
+
+func crc8(data []byte) byte {
+	var c byte
+	for _, b := range data {
+		c = crc8Table[c^b]
+	}
+	return c
+}
+
+func crc16(data []byte) uint16 {
+	var c uint16
+	for _, b := range data {
+		c = c<<8 ^ crc16Table[byte(c>>8)^b]
+	}
+	return c
+}
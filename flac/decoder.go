@@ -0,0 +1,419 @@
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Decoder reads a FLAC stream.
+type Decoder struct {
+	Info     StreamInfo
+	Comments []string // Vorbis comments, e.g. "TITLE=Kick".
+
+	br      bitReader
+	md5     hash.Hash
+	md5Buf  []byte
+	samples int64 // Decoded so far.
+	frame   [][]int32
+}
+
+// NewDecoder reads the stream header from r.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return nil, fmt.Errorf("error reading FLAC header; %s", err)
+	}
+	if !bytes.Equal(b, magic) {
+		return nil, fmt.Errorf("not a FLAC stream")
+	}
+
+	d := &Decoder{br: bitReader{r: br}, md5: md5.New(), Comments: []string{}}
+	info := false
+	for last := false; !last; {
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, fmt.Errorf("error reading FLAC metadata; %s", err)
+		}
+		last = b[0]&0x80 != 0
+		typ := b[0] & 0x7f
+		payload := make([]byte, int(b[1])<<16|int(b[2])<<8|int(b[3]))
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return nil, fmt.Errorf("error reading FLAC metadata; %s", err)
+		}
+		switch typ {
+		case streamInfoType:
+			si, err := parseStreamInfo(payload)
+			if err != nil {
+				return nil, err
+			}
+			d.Info, info = *si, true
+		case vorbisCommentType:
+			cs, err := parseVorbisComments(payload)
+			if err != nil {
+				return nil, err
+			}
+			d.Comments = cs
+		}
+	}
+	if !info {
+		return nil, fmt.Errorf("missing STREAMINFO block")
+	}
+	return d, nil
+}
+
+// Read returns the samples of the next frame, by channel. The samples are
+// only valid until the next call. At the end of the stream, it returns io.EOF
+// once the checksum of the samples was verified.
+func (d *Decoder) Read() ([][]int32, error) {
+	br := &d.br
+	br.resetCRC()
+	// Frames start byte aligned, so a clean end of the stream is at a byte.
+	if err := br.readByte(); err != nil {
+		if err != io.EOF {
+			return nil, err
+		}
+		return nil, d.verify()
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if cap(d.frame) < len(h.bps) {
+		d.frame = make([][]int32, len(h.bps))
+	}
+	d.frame = d.frame[:len(h.bps)]
+	raw := make([][]int64, len(h.bps))
+	for c := range raw {
+		if raw[c], err = d.readSubframe(h.size, h.bps[c]); err != nil {
+			return nil, fmt.Errorf("error reading subframe; %s", err)
+		}
+	}
+	br.align()
+	crc := br.crc16
+	got, err := br.readBits(16)
+	if err != nil {
+		return nil, err
+	}
+	if uint16(got) != crc {
+		return nil, fmt.Errorf("frame CRC mismatch")
+	}
+
+	// Undo the channel decorrelation.
+	switch h.assign {
+	case 8: // Left, side.
+		for i := range raw[1] {
+			raw[1][i] = raw[0][i] - raw[1][i]
+		}
+	case 9: // Side, right.
+		for i := range raw[0] {
+			raw[0][i] += raw[1][i]
+		}
+	case 10: // Mid, side.
+		for i := range raw[0] {
+			mid, side := raw[0][i]<<1|raw[1][i]&1, raw[1][i]
+			raw[0][i], raw[1][i] = (mid+side)>>1, (mid-side)>>1
+		}
+	}
+	d.md5Buf = d.md5Buf[:0]
+	for c := range d.frame {
+		if cap(d.frame[c]) < h.size {
+			d.frame[c] = make([]int32, h.size)
+		}
+		d.frame[c] = d.frame[c][:h.size]
+	}
+	for i := 0; i < h.size; i++ {
+		for c := range raw {
+			d.frame[c][i] = int32(raw[c][i])
+			d.md5Buf = sampleBytes(d.md5Buf, raw[c][i], d.Info.BitsPerSample)
+		}
+	}
+	d.md5.Write(d.md5Buf)
+	d.samples += int64(h.size)
+	return d.frame, nil
+}
+
+// verify verifies the samples decoded against the stream header. It returns
+// io.EOF if they match.
+func (d *Decoder) verify() error {
+	if d.Info.Samples != 0 && d.samples != d.Info.Samples {
+		return fmt.Errorf("decoded %d samples, want %d", d.samples, d.Info.Samples)
+	}
+	if d.Info.MD5 != [16]byte{} && !bytes.Equal(d.md5.Sum(nil), d.Info.MD5[:]) {
+		return fmt.Errorf("MD5 mismatch of the decoded samples")
+	}
+	return io.EOF
+}
+
+// frameHeader describes a frame.
+type frameHeader struct {
+	size   int    // Samples per channel.
+	assign int    // Channel assignment.
+	bps    []uint // Bits per sample of each subframe.
+}
+
+// readHeader reads a frame header, the first byte of which was read.
+func (d *Decoder) readHeader() (*frameHeader, error) {
+	br := &d.br
+	v, err := br.readBits(16)
+	if err != nil {
+		return nil, err
+	}
+	if v>>2 != 0x3ffe || v&0x2 != 0 {
+		return nil, fmt.Errorf("frame sync code not found")
+	}
+	v, err = br.readBits(16)
+	if err != nil {
+		return nil, err
+	}
+	bsCode, srCode := int(v>>12), int(v>>8&0xf)
+	assign, ssCode := int(v>>4&0xf), int(v>>1&0x7)
+	if _, err := br.readUTF8(); err != nil { // Frame or sample number.
+		return nil, err
+	}
+
+	h := &frameHeader{assign: assign}
+	switch {
+	case bsCode == 0:
+		return nil, fmt.Errorf("reserved block size")
+	case bsCode == 1:
+		h.size = 192
+	case bsCode <= 5:
+		h.size = 576 << uint(bsCode-2)
+	case bsCode == 6, bsCode == 7:
+		n, err := br.readBits(uint(8 * (bsCode - 5)))
+		if err != nil {
+			return nil, err
+		}
+		h.size = int(n) + 1
+	default:
+		h.size = 256 << uint(bsCode-8)
+	}
+	switch srCode {
+	case 12, 13, 14: // The rate itself, which is not needed.
+		n := uint(16)
+		if srCode == 12 {
+			n = 8
+		}
+		if _, err := br.readBits(n); err != nil {
+			return nil, err
+		}
+	case 15:
+		return nil, fmt.Errorf("invalid sample rate")
+	}
+	crc := br.crc8
+	got, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if byte(got) != crc {
+		return nil, fmt.Errorf("frame header CRC mismatch")
+	}
+
+	bps := uint(d.Info.BitsPerSample)
+	if ssCode != 0 {
+		sizes := []uint{0, 8, 12, 0, 16, 20, 24, 32}
+		if bps = sizes[ssCode]; bps == 0 {
+			return nil, fmt.Errorf("reserved sample size")
+		}
+	}
+	switch {
+	case assign < 8:
+		if assign+1 != d.Info.Channels {
+			return nil, fmt.Errorf("frame of %d channels, want %d", assign+1, d.Info.Channels)
+		}
+		for c := 0; c <= assign; c++ {
+			h.bps = append(h.bps, bps)
+		}
+	case assign == 8, assign == 10:
+		h.bps = []uint{bps, bps + 1}
+	case assign == 9:
+		h.bps = []uint{bps + 1, bps}
+	default:
+		return nil, fmt.Errorf("reserved channel assignment")
+	}
+	if assign >= 8 && d.Info.Channels != 2 {
+		return nil, fmt.Errorf("stereo frame, want %d channels", d.Info.Channels)
+	}
+	return h, nil
+}
+
+// readSubframe reads the n samples of a subframe.
+func (d *Decoder) readSubframe(n int, bps uint) ([]int64, error) {
+	br := &d.br
+	v, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if v&0x80 != 0 {
+		return nil, fmt.Errorf("invalid subframe header")
+	}
+	typ := int(v >> 1 & 0x3f)
+	wasted := uint(0)
+	if v&1 != 0 {
+		k, err := br.readUnary()
+		if err != nil {
+			return nil, err
+		}
+		wasted = uint(k) + 1
+		if wasted >= bps {
+			return nil, fmt.Errorf("invalid wasted bits")
+		}
+		bps -= wasted
+	}
+
+	x := make([]int64, n)
+	switch {
+	case typ == 0: // Constant.
+		s, err := br.readSigned(bps)
+		if err != nil {
+			return nil, err
+		}
+		for i := range x {
+			x[i] = s
+		}
+	case typ == 1: // Verbatim.
+		for i := range x {
+			if x[i], err = br.readSigned(bps); err != nil {
+				return nil, err
+			}
+		}
+	case typ >= 8 && typ <= 12: // Fixed.
+		order := typ - 8
+		if err := d.readWarmup(x, order, bps); err != nil {
+			return nil, err
+		}
+		if err := d.readResidual(x, order); err != nil {
+			return nil, err
+		}
+		for i := order; i < n; i++ {
+			switch order {
+			case 1:
+				x[i] += x[i-1]
+			case 2:
+				x[i] += 2*x[i-1] - x[i-2]
+			case 3:
+				x[i] += 3*x[i-1] - 3*x[i-2] + x[i-3]
+			case 4:
+				x[i] += 4*x[i-1] - 6*x[i-2] + 4*x[i-3] - x[i-4]
+			}
+		}
+	case typ >= 32: // LPC.
+		order := typ - 31
+		if err := d.readWarmup(x, order, bps); err != nil {
+			return nil, err
+		}
+		p, err := br.readBits(4)
+		if err != nil {
+			return nil, err
+		}
+		if p == 15 {
+			return nil, fmt.Errorf("invalid LPC precision")
+		}
+		shift, err := br.readSigned(5)
+		if err != nil {
+			return nil, err
+		}
+		if shift < 0 {
+			return nil, fmt.Errorf("negative LPC shift")
+		}
+		coefs := make([]int64, order)
+		for i := range coefs {
+			if coefs[i], err = br.readSigned(uint(p) + 1); err != nil {
+				return nil, err
+			}
+		}
+		if err := d.readResidual(x, order); err != nil {
+			return nil, err
+		}
+		for i := order; i < n; i++ {
+			var sum int64
+			for j, c := range coefs {
+				sum += c * x[i-1-j]
+			}
+			x[i] += sum >> uint(shift)
+		}
+	default:
+		return nil, fmt.Errorf("reserved subframe type %d", typ)
+	}
+	if wasted > 0 {
+		for i := range x {
+			x[i] <<= wasted
+		}
+	}
+	return x, nil
+}
+
+// readWarmup reads the first order samples of a predicted subframe.
+func (d *Decoder) readWarmup(x []int64, order int, bps uint) error {
+	if order > len(x) {
+		return fmt.Errorf("predictor order %d exceeds the block size", order)
+	}
+	for i := 0; i < order; i++ {
+		var err error
+		if x[i], err = d.br.readSigned(bps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readResidual reads the residual of a predicted subframe into x, following
+// the warm up samples.
+func (d *Decoder) readResidual(x []int64, order int) error {
+	br := &d.br
+	method, err := br.readBits(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return fmt.Errorf("reserved residual coding method")
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	po, err := br.readBits(4)
+	if err != nil {
+		return err
+	}
+	parts := 1 << uint(po)
+	if len(x)%parts != 0 || len(x)/parts < order {
+		return fmt.Errorf("invalid partition order %d", po)
+	}
+	i := order
+	for p := 0; p < parts; p++ {
+		count := len(x) / parts
+		if p == 0 {
+			count -= order
+		}
+		k, err := br.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+		if k == escape {
+			raw, err := br.readBits(5)
+			if err != nil {
+				return err
+			}
+			for j := 0; j < count; j++ {
+				if x[i], err = br.readSigned(uint(raw)); err != nil {
+					return err
+				}
+				i++
+			}
+			continue
+		}
+		for j := 0; j < count; j++ {
+			if x[i], err = br.readRice(uint(k)); err != nil {
+				return err
+			}
+			i++
+		}
+	}
+	return nil
+}
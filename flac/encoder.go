@@ -0,0 +1,434 @@
+package flac
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+)
+
+const (
+	maxFixedOrder     = 4
+	maxPartitionOrder = 8
+	riceParamLimit    = 14 // Of the 4-bit Rice parameters; 15 escapes.
+	rice2ParamLimit   = 30 // Of the 5-bit Rice parameters; 31 escapes.
+)
+
+// Encoder writes a FLAC stream. The subframes are encoded with the fixed
+// predictors, and stereo frames with the best channel decorrelation.
+type Encoder struct {
+	w        io.Writer
+	bw       *bufio.Writer
+	info     StreamInfo
+	md5      hash.Hash
+	md5Buf   []byte
+	pending  [][]int64 // Samples by channel, less than a block.
+	frame    uint64
+	minFrame int
+	maxFrame int
+	fw       bitWriter
+}
+
+// NewEncoder writes the stream header of info and the Vorbis comments, e.g.
+// "TITLE=Kick", to w. The samples and checksum of info are ignored; they are
+// set on close if w is an io.WriterAt, e.g. an *os.File.
+func NewEncoder(w io.Writer, info StreamInfo, comments ...string) (*Encoder, error) {
+	if err := info.check(); err != nil {
+		return nil, err
+	}
+	info.Samples, info.MD5 = 0, [16]byte{}
+	e := &Encoder{
+		w:        w,
+		bw:       bufio.NewWriterSize(w, 1<<20),
+		info:     info,
+		md5:      md5.New(),
+		pending:  make([][]int64, info.Channels),
+		minFrame: math.MaxInt32,
+	}
+	if _, err := e.bw.Write(magic); err != nil {
+		return nil, err
+	}
+	if err := writeBlock(e.bw, streamInfoType, false, info.bytes(0, 0)); err != nil {
+		return nil, err
+	}
+	if err := writeBlock(e.bw, vorbisCommentType, true, vorbisComments(comments)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Write encodes samples, given by channel. Each channel must hold the same
+// number of samples, within the range of the bits per sample.
+func (e *Encoder) Write(samples [][]int32) error {
+	if len(samples) != e.info.Channels {
+		return fmt.Errorf("got %d channels, want %d", len(samples), e.info.Channels)
+	}
+	n := len(samples[0])
+	for _, s := range samples {
+		if len(s) != n {
+			return fmt.Errorf("channels differ in length")
+		}
+	}
+	e.md5Buf = e.md5Buf[:0]
+	for i := 0; i < n; i++ {
+		for c, s := range samples {
+			e.md5Buf = sampleBytes(e.md5Buf, int64(s[i]), e.info.BitsPerSample)
+			e.pending[c] = append(e.pending[c], int64(s[i]))
+		}
+	}
+	e.md5.Write(e.md5Buf)
+	e.info.Samples += int64(n)
+
+	for len(e.pending[0]) >= BlockSize {
+		if err := e.writeFrame(BlockSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the last frame, and completes the stream header if possible.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if len(e.pending[0]) > 0 {
+		if err := e.writeFrame(len(e.pending[0])); err != nil {
+			return err
+		}
+	}
+	if err := e.bw.Flush(); err != nil {
+		return err
+	}
+	wa, ok := e.w.(io.WriterAt)
+	if !ok {
+		return nil
+	}
+	copy(e.info.MD5[:], e.md5.Sum(nil))
+	if e.frame == 0 {
+		e.minFrame = 0
+	}
+	_, err := wa.WriteAt(e.info.bytes(e.minFrame, e.maxFrame), int64(len(magic)+4))
+	return err
+}
+
+// writeFrame encodes the first n pending samples of each channel.
+func (e *Encoder) writeFrame(n int) error {
+	chs := make([][]int64, e.info.Channels)
+	for c := range chs {
+		chs[c] = e.pending[c][:n]
+	}
+	bps := uint(e.info.BitsPerSample)
+	assign := e.info.Channels - 1
+	sfs := make([]*subframe, len(chs))
+	if e.info.Channels == 2 && bps < 32 {
+		assign, chs, sfs = stereo(chs[0], chs[1], bps)
+	} else {
+		for c, x := range chs {
+			sfs[c] = estimate(x, bps)
+		}
+	}
+
+	w := &e.fw
+	w.buf, w.acc, w.n = w.buf[:0], 0, 0
+	w.writeBits(0x3ffe, 14) // Sync code.
+	w.writeBits(0, 2)       // Reserved, and fixed block size.
+	switch {
+	case n == BlockSize:
+		w.writeBits(12, 4) // 256 * 2^(12-8).
+	case n <= 256:
+		w.writeBits(6, 4)
+	default:
+		w.writeBits(7, 4)
+	}
+	w.writeBits(0, 4) // Sample rate of the STREAMINFO.
+	w.writeBits(uint64(assign), 4)
+	w.writeBits(uint64(sampleSizeCodes[int(bps)]), 3)
+	w.writeBits(0, 1) // Reserved.
+	w.writeUTF8(e.frame)
+	switch {
+	case n == BlockSize:
+	case n <= 256:
+		w.writeBits(uint64(n-1), 8)
+	default:
+		w.writeBits(uint64(n-1), 16)
+	}
+	w.writeBits(uint64(crc8(w.buf)), 8)
+
+	for c, x := range chs {
+		writeSubframe(w, x, sfs[c])
+	}
+	w.align()
+	crc := crc16(w.buf)
+	w.writeBits(uint64(crc), 16)
+
+	if _, err := e.bw.Write(w.buf); err != nil {
+		return err
+	}
+	if len(w.buf) < e.minFrame {
+		e.minFrame = len(w.buf)
+	}
+	if len(w.buf) > e.maxFrame {
+		e.maxFrame = len(w.buf)
+	}
+	e.frame++
+	for c := range e.pending {
+		e.pending[c] = e.pending[c][:copy(e.pending[c], e.pending[c][n:])]
+	}
+	return nil
+}
+
+// sampleSizeCodes are the frame header codes of the bits per sample. Other
+// sizes are given by the STREAMINFO.
+var sampleSizeCodes = map[int]int{8: 1, 12: 2, 16: 4, 20: 5, 24: 6, 32: 7}
+
+// stereo returns the channel assignment of a stereo frame that encodes
+// smallest, with its channels and their encodings.
+func stereo(left, right []int64, bps uint) (int, [][]int64, []*subframe) {
+	side, mid := make([]int64, len(left)), make([]int64, len(left))
+	for i := range left {
+		side[i] = left[i] - right[i]
+		mid[i] = (left[i] + right[i]) >> 1
+	}
+	l, r := estimate(left, bps), estimate(right, bps)
+	s, m := estimate(side, bps+1), estimate(mid, bps)
+	assign, chs, sfs, best := 1, [][]int64{left, right}, []*subframe{l, r}, l.bits+r.bits
+	for _, a := range []struct {
+		assign int
+		chs    [][]int64
+		sfs    []*subframe
+	}{
+		{8, [][]int64{left, side}, []*subframe{l, s}},  // Left, side.
+		{9, [][]int64{side, right}, []*subframe{s, r}}, // Side, right.
+		{10, [][]int64{mid, side}, []*subframe{m, s}},  // Mid, side.
+	} {
+		if bits := a.sfs[0].bits + a.sfs[1].bits; bits < best {
+			assign, chs, sfs, best = a.assign, a.chs, a.sfs, bits
+		}
+	}
+	return assign, chs, sfs
+}
+
+// subframe describes how a subframe is encoded.
+type subframe struct {
+	kind      int // 0 constant, 1 verbatim, 2 fixed.
+	bps       uint
+	order     int
+	residual  []int64
+	partition int    // Partition order.
+	params    []uint // Rice parameter of each partition, or escapeParam.
+	rawBits   []uint // Bits of each escaped partition.
+	rice2     bool
+	bits      int // Size of the encoded subframe.
+}
+
+const escapeParam = math.MaxUint32
+
+// estimate returns the smallest encoding of the samples.
+func estimate(x []int64, bps uint) *subframe {
+	n := len(x)
+	constant := true
+	for _, v := range x[1:] {
+		if v != x[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return &subframe{kind: 0, bps: bps, bits: 8 + int(bps)}
+	}
+	best := &subframe{kind: 1, bps: bps, bits: 8 + n*int(bps)}
+
+	// Choose the fixed predictor order with the smallest residual.
+	order, least := -1, uint64(math.MaxUint64)
+	var residual []int64
+	for o := 0; o <= maxFixedOrder && o < n; o++ {
+		r, ok := fixedResidual(x, o)
+		if !ok {
+			continue
+		}
+		sum := uint64(0)
+		for _, v := range r {
+			sum += zigzag(v)
+		}
+		if sum < least {
+			order, least, residual = o, sum, r
+		}
+	}
+	if order < 0 {
+		return best
+	}
+	sf := riceEncoding(residual, n, order)
+	sf.kind, sf.bps, sf.order = 2, bps, order
+	sf.bits += 8 + order*int(bps)
+	if sf.bits < best.bits {
+		return sf
+	}
+	return best
+}
+
+// fixedResidual returns the residual of the fixed predictor of the order. It
+// is not ok if a residual exceeds 32 bits.
+func fixedResidual(x []int64, order int) ([]int64, bool) {
+	r := make([]int64, len(x)-order)
+	for i := order; i < len(x); i++ {
+		var v int64
+		switch order {
+		case 0:
+			v = x[i]
+		case 1:
+			v = x[i] - x[i-1]
+		case 2:
+			v = x[i] - 2*x[i-1] + x[i-2]
+		case 3:
+			v = x[i] - 3*x[i-1] + 3*x[i-2] - x[i-3]
+		case 4:
+			v = x[i] - 4*x[i-1] + 6*x[i-2] - 4*x[i-3] + x[i-4]
+		}
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			return nil, false
+		}
+		r[i-order] = v
+	}
+	return r, true
+}
+
+// riceEncoding returns the partitioned Rice coding of the residual of a
+// block of n samples, that encodes smallest.
+func riceEncoding(residual []int64, n, order int) *subframe {
+	var best *subframe
+	for po := 0; po <= maxPartitionOrder; po++ {
+		if n%(1<<uint(po)) != 0 || n>>uint(po) <= order {
+			break
+		}
+		sf := &subframe{residual: residual, partition: po, bits: 6}
+		start := 0
+		for p := 0; p < 1<<uint(po); p++ {
+			count := n >> uint(po)
+			if p == 0 {
+				count -= order
+			}
+			part := residual[start : start+count]
+			start += count
+			k, bits := riceParam(part)
+			raw := rawBits(part)
+			if rb := 5 + count*int(raw); raw <= 31 && rb < bits {
+				k, bits = escapeParam, rb
+			}
+			if k != escapeParam && k > riceParamLimit {
+				sf.rice2 = true
+			}
+			sf.params = append(sf.params, k)
+			sf.rawBits = append(sf.rawBits, raw)
+			sf.bits += bits
+		}
+		paramBits := 4
+		if sf.rice2 {
+			paramBits = 5
+		}
+		sf.bits += len(sf.params) * paramBits
+		if best == nil || sf.bits < best.bits {
+			best = sf
+		}
+	}
+	return best
+}
+
+// riceParam returns the Rice parameter that encodes the residual smallest,
+// and the resulting size.
+func riceParam(r []int64) (uint, int) {
+	if len(r) == 0 {
+		return 0, 0
+	}
+	sum := uint64(0)
+	for _, v := range r {
+		sum += zigzag(v)
+	}
+	// The best parameter is near the log2 of the mean.
+	k := uint(0)
+	for k < rice2ParamLimit && uint64(len(r))<<(k+1) <= sum {
+		k++
+	}
+	best, bestBits := k, math.MaxInt64
+	for _, c := range []uint{k - 1, k, k + 1} {
+		if c > rice2ParamLimit { // Includes k-1 wrapping below 0.
+			continue
+		}
+		bits := len(r) * int(c+1)
+		for _, v := range r {
+			bits += int(zigzag(v) >> c)
+		}
+		if bits < bestBits {
+			best, bestBits = c, bits
+		}
+	}
+	return best, bestBits
+}
+
+// rawBits returns the bits needed to store each residual as is.
+func rawBits(r []int64) uint {
+	bits := uint(0)
+	for _, v := range r {
+		if v < 0 {
+			v = ^v
+		}
+		for bits < 64 && v>>bits != 0 {
+			bits++
+		}
+	}
+	if bits == 0 {
+		return 0
+	}
+	return bits + 1 // The sign.
+}
+
+// writeSubframe encodes the samples, as estimated.
+func writeSubframe(w *bitWriter, x []int64, sf *subframe) {
+	bps := sf.bps
+	switch sf.kind {
+	case 0:
+		w.writeBits(0, 8)
+		w.writeSigned(x[0], bps)
+		return
+	case 1:
+		w.writeBits(1<<1, 8)
+		for _, v := range x {
+			w.writeSigned(v, bps)
+		}
+		return
+	}
+	w.writeBits(uint64(8|sf.order)<<1, 8)
+	for _, v := range x[:sf.order] {
+		w.writeSigned(v, bps)
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if sf.rice2 {
+		paramBits, escape = 5, 31
+		w.writeBits(1, 2)
+	} else {
+		w.writeBits(0, 2)
+	}
+	w.writeBits(uint64(sf.partition), 4)
+	n := len(x)
+	start := 0
+	for p, k := range sf.params {
+		count := n >> uint(sf.partition)
+		if p == 0 {
+			count -= sf.order
+		}
+		part := sf.residual[start : start+count]
+		start += count
+		if k == escapeParam {
+			w.writeBits(escape, paramBits)
+			w.writeBits(uint64(sf.rawBits[p]), 5)
+			for _, v := range part {
+				w.writeSigned(v, sf.rawBits[p])
+			}
+			continue
+		}
+		w.writeBits(uint64(k), paramBits)
+		for _, v := range part {
+			w.writeRice(v, k)
+		}
+	}
+}
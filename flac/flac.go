@@ -0,0 +1,152 @@
+package flac
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BlockSize is the number of samples per channel in the frames written.
+const BlockSize = 4096
+
+const (
+	streamInfoType    = 0
+	vorbisCommentType = 4
+	streamInfoSize    = 34
+)
+
+var (
+	magic          = []byte("fLaC")
+	errInvalidUTF8 = errors.New("invalid frame number")
+)
+
+// Vendor is the vendor string of the Vorbis comments written.
+var Vendor = "tracks"
+
+// StreamInfo describes a FLAC stream.
+type StreamInfo struct {
+	SampleRate    int // In Hz.
+	Channels      int
+	BitsPerSample int
+	Samples       int64    // Per channel, or 0 if unknown.
+	MD5           [16]byte // Of the unencoded samples, or zeros if unknown.
+}
+
+// check returns an error if the stream can't be written.
+func (si *StreamInfo) check() error {
+	switch {
+	case si.SampleRate <= 0 || si.SampleRate >= 1<<20:
+		return fmt.Errorf("unsupported sample rate %d", si.SampleRate)
+	case si.Channels < 1 || si.Channels > 8:
+		return fmt.Errorf("unsupported number of channels %d", si.Channels)
+	case si.BitsPerSample < 4 || si.BitsPerSample > 32:
+		return fmt.Errorf("unsupported bits per sample %d", si.BitsPerSample)
+	}
+	return nil
+}
+
+// bytes returns the STREAMINFO block payload.
+func (si *StreamInfo) bytes(minFrame, maxFrame int) []byte {
+	w := &bitWriter{}
+	w.writeBits(BlockSize, 16) // Minimum block size, less the last.
+	w.writeBits(BlockSize, 16)
+	w.writeBits(uint64(minFrame), 24)
+	w.writeBits(uint64(maxFrame), 24)
+	w.writeBits(uint64(si.SampleRate), 20)
+	w.writeBits(uint64(si.Channels-1), 3)
+	w.writeBits(uint64(si.BitsPerSample-1), 5)
+	w.writeBits(uint64(si.Samples), 36)
+	return append(w.buf, si.MD5[:]...)
+}
+
+// parseStreamInfo parses a STREAMINFO block payload.
+func parseStreamInfo(b []byte) (*StreamInfo, error) {
+	if len(b) < streamInfoSize {
+		return nil, fmt.Errorf("short STREAMINFO block")
+	}
+	v := binary.BigEndian.Uint64(b[10:18])
+	si := &StreamInfo{
+		SampleRate:    int(v >> 44),
+		Channels:      int(v>>41&0x7) + 1,
+		BitsPerSample: int(v>>36&0x1f) + 1,
+		Samples:       int64(v & (1<<36 - 1)),
+	}
+	copy(si.MD5[:], b[18:34])
+	return si, nil
+}
+
+// writeBlock writes a metadata block.
+func writeBlock(w io.Writer, typ byte, last bool, payload []byte) error {
+	h := make([]byte, 4)
+	binary.BigEndian.PutUint32(h, uint32(len(payload)))
+	h[0] = typ
+	if last {
+		h[0] |= 0x80
+	}
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// vorbisComments returns a VORBIS_COMMENT block payload. Unlike the rest of
+// FLAC, its numbers are little endian.
+func vorbisComments(comments []string) []byte {
+	b := []byte{}
+	le := func(s string) {
+		b = append(b, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[len(b)-4:], uint32(len(s)))
+		b = append(b, s...)
+	}
+	le(Vendor)
+	b = append(b, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[len(b)-4:], uint32(len(comments)))
+	for _, c := range comments {
+		le(c)
+	}
+	return b
+}
+
+// parseVorbisComments parses a VORBIS_COMMENT block payload.
+func parseVorbisComments(b []byte) ([]string, error) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+	if _, ok := next(); !ok { // Vendor.
+		return nil, fmt.Errorf("invalid VORBIS_COMMENT block")
+	}
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid VORBIS_COMMENT block")
+	}
+	n := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	comments := []string{}
+	for i := uint32(0); i < n; i++ {
+		c, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("invalid VORBIS_COMMENT block")
+		}
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// sampleBytes appends the little endian bytes of a sample, as hashed by the
+// MD5 of the stream.
+func sampleBytes(b []byte, v int64, bps int) []byte {
+	for i := 0; i < (bps+7)/8; i++ {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
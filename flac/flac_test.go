@@ -0,0 +1,233 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// buffer is an in memory io.WriterAt, so that the encoder completes the
+// stream header.
+type buffer struct {
+	b   []byte
+	off int
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	n, err := b.WriteAt(p, int64(b.off))
+	b.off += n
+	return n, err
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(b.b) {
+		b.b = append(b.b, make([]byte, end-len(b.b))...)
+	}
+	return copy(b.b[off:], p), nil
+}
+
+// signal returns n samples of each channel of a test signal.
+func signal(kind string, channels, n, bps int) [][]int32 {
+	rng := rand.New(rand.NewSource(1))
+	max := float64(int64(1)<<uint(bps-1) - 1)
+	min := -max - 1
+	chs := make([][]int32, channels)
+	for c := range chs {
+		chs[c] = make([]int32, n)
+		for i := range chs[c] {
+			var v float64
+			switch kind {
+			case "noise":
+				v = min + rng.Float64()*(max-min+1)
+			case "sine":
+				v = max * 0.8 * math.Sin(2*math.Pi*440*float64(i+c*10)/48000)
+			case "constant":
+				v = min // The extreme sample.
+			case "extremes":
+				v = min
+				if i%2 == 0 {
+					v = max
+				}
+			case "silence":
+			}
+			chs[c][i] = int32(math.Max(min, math.Min(max, math.Floor(v))))
+		}
+	}
+	return chs
+}
+
+// encode returns the FLAC stream of the samples, written in chunks of size.
+func encode(t *testing.T, info StreamInfo, samples [][]int32, size int, comments ...string) []byte {
+	b := &buffer{}
+	e, err := NewEncoder(b, info, comments...)
+	if err != nil {
+		t.Fatalf("NewEncoder() unexpected error %s", err)
+	}
+	for i := 0; i < len(samples[0]); i += size {
+		end := i + size
+		if end > len(samples[0]) {
+			end = len(samples[0])
+		}
+		chunk := make([][]int32, len(samples))
+		for c := range chunk {
+			chunk[c] = samples[c][i:end]
+		}
+		if err := e.Write(chunk); err != nil {
+			t.Fatalf("Write() unexpected error %s", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() unexpected error %s", err)
+	}
+	return b.b
+}
+
+// decode returns the samples and comments of a FLAC stream.
+func decode(b []byte) ([][]int32, *Decoder, error) {
+	d, err := NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	samples := make([][]int32, d.Info.Channels)
+	for {
+		frame, err := d.Read()
+		if err == io.EOF {
+			return samples, d, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for c := range samples {
+			samples[c] = append(samples[c], frame[c]...)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		kind     string
+		bps      int
+		channels int
+		n        int
+		size     int // Of the writes.
+	}{
+		{"noise", 16, 1, 10000, 1000},
+		{"noise", 16, 2, 10000, 4096},
+		{"noise", 24, 2, 9000, 333},
+		{"noise", 32, 2, 5000, 5000},
+		{"noise", 8, 1, 100, 7},
+		{"sine", 16, 2, 20000, 1024},
+		{"sine", 24, 6, 8192, 8192},
+		{"sine", 32, 1, 4097, 100},
+		{"silence", 24, 2, 5000, 5000},
+		{"constant", 16, 1, 4096, 4096},
+		{"constant", 32, 2, 100, 100},
+		{"extremes", 16, 2, 5000, 5000},
+		{"extremes", 24, 1, 5000, 5000},
+		{"extremes", 32, 2, 5000, 5000},
+		{"sine", 16, 1, 1, 1},
+		{"sine", 16, 2, 3, 3},
+	} {
+		samples := signal(tt.kind, tt.channels, tt.n, tt.bps)
+		info := StreamInfo{SampleRate: 48000, Channels: tt.channels, BitsPerSample: tt.bps}
+		b := encode(t, info, samples, tt.size)
+		got, d, err := decode(b)
+		if err != nil {
+			t.Errorf("%s %d-bit %d channels: decode() unexpected error %s", tt.kind, tt.bps, tt.channels, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, samples) {
+			t.Errorf("%s %d-bit %d channels: the samples differ", tt.kind, tt.bps, tt.channels)
+		}
+		if d.Info.Samples != int64(tt.n) || d.Info.MD5 == [16]byte{} {
+			t.Errorf("%s %d-bit %d channels: info = %+v", tt.kind, tt.bps, tt.channels, d.Info)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	for _, tt := range []struct {
+		kind string
+		max  int // Percent of the raw size.
+	}{
+		{"sine", 60},
+		{"silence", 1},
+		{"noise", 101},
+	} {
+		samples := signal(tt.kind, 2, 48000, 24)
+		b := encode(t, StreamInfo{SampleRate: 48000, Channels: 2, BitsPerSample: 24}, samples, 48000)
+		if raw := 2 * 48000 * 3; len(b)*100 > raw*tt.max {
+			t.Errorf("%s: %d bytes, want at most %d%% of %d", tt.kind, len(b), tt.max, raw)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	comments := []string{"TITLE=Kick", "TRACKNUMBER=1", "ALBUM=Café"}
+	b := encode(t, StreamInfo{SampleRate: 44100, Channels: 1, BitsPerSample: 16}, signal("sine", 1, 10, 16), 10, comments...)
+	_, d, err := decode(b)
+	if err != nil {
+		t.Fatalf("decode() unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(d.Comments, comments) {
+		t.Errorf("Comments = %q, want %q", d.Comments, comments)
+	}
+	if got, want := d.Info.SampleRate, 44100; got != want {
+		t.Errorf("SampleRate = %d, want %d", got, want)
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	b := encode(t, StreamInfo{SampleRate: 48000, Channels: 2, BitsPerSample: 16}, signal("noise", 2, 10000, 16), 10000)
+	// Skip the stream header.
+	start := len(magic) + 4 + streamInfoSize + 4 + len(vorbisComments(nil))
+	for _, off := range []int{start + 2, start + 100, len(b) / 2, len(b) - 3} {
+		c := append([]byte{}, b...)
+		c[off] ^= 0x10
+		if _, _, err := decode(c); err == nil {
+			t.Errorf("decode() of a byte %d corrupted expected error", off)
+		}
+	}
+	if _, _, err := decode(b[:len(b)-10]); err == nil {
+		t.Errorf("decode() of a truncated stream expected error")
+	}
+	if _, err := NewDecoder(bytes.NewReader([]byte("RIFF...."))); err == nil {
+		t.Errorf("NewDecoder() of a wave file expected error")
+	}
+}
+
+func TestNewEncoderErrors(t *testing.T) {
+	for _, info := range []StreamInfo{
+		{SampleRate: 0, Channels: 2, BitsPerSample: 16},
+		{SampleRate: 48000, Channels: 9, BitsPerSample: 16},
+		{SampleRate: 48000, Channels: 2, BitsPerSample: 33},
+	} {
+		if _, err := NewEncoder(&buffer{}, info); err == nil {
+			t.Errorf("NewEncoder(%+v) expected error", info)
+		}
+	}
+	e, err := NewEncoder(&buffer{}, StreamInfo{SampleRate: 48000, Channels: 2, BitsPerSample: 16})
+	if err != nil {
+		t.Fatalf("NewEncoder() unexpected error %s", err)
+	}
+	if err := e.Write([][]int32{{1, 2}}); err == nil {
+		t.Errorf("Write() of one channel expected error")
+	}
+	if err := e.Write([][]int32{{1, 2}, {1}}); err == nil {
+		t.Errorf("Write() of uneven channels expected error")
+	}
+}
+
+func TestUTF8(t *testing.T) {
+	for _, v := range []uint64{0, 0x7f, 0x80, 0x7ff, 0x800, 0xffff, 0x10000, 1<<31 - 1, 1<<36 - 1} {
+		w := &bitWriter{}
+		w.writeUTF8(v)
+		r := &bitReader{r: bytes.NewReader(w.buf)}
+		if got, err := r.readUTF8(); err != nil || got != v {
+			t.Errorf("readUTF8() = %d, %v; want %d", got, err, v)
+		}
+	}
+}
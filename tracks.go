@@ -16,6 +16,19 @@ func main() {
 			Name:  "dry_run,n",
 			Usage: "do a dry run",
 		},
+		cli.StringFlag{
+			Name:  "config",
+			Usage: "configuration file (default: " + commands.ConfigFile + " in the working directory or a parent)",
+		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "configuration file profile",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: "text",
+			Usage: "output format (text, json, yaml)",
+		},
 	}
 	app.Name = "tracks - A tool for integrating Waves Tracks and Avid Venue"
 	app.Usage = ""
@@ -2,7 +2,7 @@ package tracks
 
 import (
 	"fmt"
-	"os"
+	"sort"
 )
 
 // Sessions holds a map of session numbers to Session data.
@@ -19,6 +19,16 @@ func (ss Sessions) Session(num int) *Session {
 	return s
 }
 
+// Slice returns the Sessions as a slice, sorted by session number.
+func (ss Sessions) Slice() []*Session {
+	slice := []*Session{}
+	for _, s := range ss {
+		slice = append(slice, s)
+	}
+	sort.Slice(slice, func(i, j int) bool { return slice[i].num < slice[j].num })
+	return slice
+}
+
 // Equal returns true if the two Sessions are equivalent.
 func (ss Sessions) Equal(ss2 Sessions) bool {
 	if len(ss) != len(ss2) {
@@ -36,7 +46,8 @@ func (ss Sessions) Equal(ss2 Sessions) bool {
 	return true
 }
 
-// ExtractSessions from a slice of track names.
+// ExtractSessions from a slice of track names. Other files are ignored; see
+// IgnoredFiles().
 func ExtractSessions(files []string) (Sessions, error) {
 	if len(files) == 0 {
 		return nil, fmt.Errorf("no files provided")
@@ -46,7 +57,6 @@ func ExtractSessions(files []string) (Sessions, error) {
 	for _, file := range files {
 		re := matchTrack(file)
 		if re == nil {
-			fmt.Fprintf(os.Stderr, "ignoring %q\n", file)
 			continue
 		}
 		t, err := extractTrack(re, file)
@@ -59,6 +69,18 @@ func ExtractSessions(files []string) (Sessions, error) {
 	return sessions, nil
 }
 
+// IgnoredFiles returns the files that are not track recordings, and so are
+// ignored by ExtractSessions(), e.g. tracks already renamed.
+func IgnoredFiles(files []string) []string {
+	ignored := []string{}
+	for _, file := range files {
+		if matchTrack(file) == nil {
+			ignored = append(ignored, file)
+		}
+	}
+	return ignored
+}
+
 // Session maps channel numbers to track info.
 type Session struct {
 	num    int
@@ -1,6 +1,8 @@
 package tracks
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -16,8 +18,8 @@ func TestExtractSessions(t *testing.T) {
 			[]string{"Track 01-1.wav", "Track 02-1.wav"},
 			Sessions{1: NewSession(1).SetTracks(
 				Tracks{
-					1: NewTrack("Track", 1, 1).SetSrc("Track 01-1.wav"),
-					2: NewTrack("Track", 2, 1).SetSrc("Track 02-1.wav"),
+					1: NewTrack("Track", 1, 1).SetSrc("Track 01-1.wav").SetNameSource(NameFromOriginal),
+					2: NewTrack("Track", 2, 1).SetSrc("Track 02-1.wav").SetNameSource(NameFromOriginal),
 				}),
 			},
 			true,
@@ -38,3 +40,24 @@ func TestExtractSessions(t *testing.T) {
 		}
 	}
 }
+
+func TestIgnoredFiles(t *testing.T) {
+	files := []string{"Track 01-1.wav", "01-01 Kick.wav", "Track 02-1.wav", "notes.wav"}
+	if got, want := IgnoredFiles(files), []string{"01-01 Kick.wav", "notes.wav"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IgnoredFiles() = %q, want %q", got, want)
+	}
+}
+
+func TestSessionsSlice(t *testing.T) {
+	ss := Sessions{}
+	for _, num := range []int{3, 1, 2} {
+		ss.Session(num)
+	}
+	nums := []int{}
+	for _, s := range ss.Slice() {
+		nums = append(nums, s.Num())
+	}
+	if got, want := fmt.Sprint(nums), "[1 2 3]"; got != want {
+		t.Errorf("Slice() = %s, want %s", got, want)
+	}
+}
@@ -59,12 +59,63 @@ func (ts Tracks) Equal(ts2 Tracks) bool {
 	return true
 }
 
+// NameSource describes where a track name came from.
+type NameSource int
+
+const (
+	NameFromUnknown      NameSource = iota // Not known, e.g. not yet named.
+	NameFromOriginal                       // Extracted from the original file name.
+	NameFromChannel                        // A stage box input channel.
+	NameFromProTools                       // A Pro Tools output.
+	NameFromNumber                         // Generated from the track number.
+	NameFromFallbackFile                   // A user-provided fallback file.
+	NameFromOverride                       // A user-provided override.
+)
+
+var nameSources = map[NameSource]string{
+	NameFromUnknown:      "unknown",
+	NameFromOriginal:     "original",
+	NameFromChannel:      "channel",
+	NameFromProTools:     "pro tools",
+	NameFromNumber:       "number",
+	NameFromFallbackFile: "fallback file",
+	NameFromOverride:     "override",
+}
+
+// String implements the fmt.Stringer interface.
+func (s NameSource) String() string {
+	if name, ok := nameSources[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("NameSource(%d)", int(s))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s NameSource) MarshalText() ([]byte, error) {
+	if _, ok := nameSources[s]; !ok {
+		return nil, fmt.Errorf("unknown name source %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *NameSource) UnmarshalText(text []byte) error {
+	for src, name := range nameSources {
+		if name == string(text) {
+			*s = src
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown name source %q", text)
+}
+
 // Track holds metadata about a track.
 type Track struct {
-	src, dest string // Source and destination files.
-	name      string // Extracted name.
-	tnum      int    // Track number.
-	snum      int    // Session number.
+	src, dest string     // Source and destination files.
+	name      string     // Extracted name.
+	nameSrc   NameSource // Where the name came from.
+	tnum      int        // Track number.
+	snum      int        // Session number.
 }
 
 // NewTrack returns an instantiated Track object.
@@ -83,13 +134,16 @@ func (t *Track) Equal(t2 *Track) bool {
 
 // String implements the fmt.Stringer interface.
 func (t *Track) String() string {
-	return fmt.Sprintf("{src: %q dest: %q name: %q tnum: %d snum: %d}",
-		t.src, t.dest, t.name, t.tnum, t.snum)
+	return fmt.Sprintf("{src: %q dest: %q name: %q name_src: %s tnum: %d snum: %d}",
+		t.src, t.dest, t.name, t.nameSrc, t.tnum, t.snum)
 }
 
 func (t *Track) Name() string               { return t.name }
 func (t *Track) SetName(name string) *Track { t.name = name; return t }
 
+func (t *Track) NameSource() NameSource              { return t.nameSrc }
+func (t *Track) SetNameSource(src NameSource) *Track { t.nameSrc = src; return t }
+
 func (t *Track) Src() string              { return t.src }
 func (t *Track) SetSrc(src string) *Track { t.src = src; return t }
 
@@ -123,5 +177,5 @@ func extractTrack(re *regexp.Regexp, file string) (*Track, error) {
 		return nil, fmt.Errorf("error converting %q session, %s", file, err)
 	}
 
-	return &Track{src: file, name: name, tnum: tnum, snum: snum}, nil
+	return &Track{src: file, name: name, nameSrc: NameFromOriginal, tnum: tnum, snum: snum}, nil
 }
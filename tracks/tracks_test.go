@@ -31,14 +31,14 @@ func TestExtractTrack(t *testing.T) {
 	}{
 		// Avid Pro Tools
 		{"pro tools s2 t1", "Audio 1_02.wav", proToolsRE,
-			&Track{src: "Audio 1_02.wav", name: "Audio", snum: 2, tnum: 1}},
+			&Track{src: "Audio 1_02.wav", name: "Audio", nameSrc: NameFromOriginal, snum: 2, tnum: 1}},
 		{"pro tools s32 t29", "Audio 29_32.wav", proToolsRE,
-			&Track{src: "Audio 29_32.wav", name: "Audio", snum: 32, tnum: 29}},
+			&Track{src: "Audio 29_32.wav", name: "Audio", nameSrc: NameFromOriginal, snum: 32, tnum: 29}},
 		// Waves Tracks
 		{"tracks s1 t3", "Track 03-1.wav", tracksRE,
-			&Track{src: "Track 03-1.wav", name: "Track", tnum: 3, snum: 1}},
+			&Track{src: "Track 03-1.wav", name: "Track", nameSrc: NameFromOriginal, tnum: 3, snum: 1}},
 		{"tracks s2 t9", "Track 09-2.wav", tracksRE,
-			&Track{src: "Track 09-2.wav", name: "Track", tnum: 9, snum: 2}},
+			&Track{src: "Track 09-2.wav", name: "Track", nameSrc: NameFromOriginal, tnum: 9, snum: 2}},
 	} {
 		got, err := extractTrack(tt.re, tt.file)
 		if err != nil {
@@ -50,3 +50,25 @@ func TestExtractTrack(t *testing.T) {
 		}
 	}
 }
+
+func TestNameSourceText(t *testing.T) {
+	for src := NameFromUnknown; src <= NameFromOverride; src++ {
+		text, err := src.MarshalText()
+		if err != nil {
+			t.Errorf("%d: MarshalText() unexpected error; %s", src, err)
+			continue
+		}
+		var got NameSource
+		if err := got.UnmarshalText(text); err != nil {
+			t.Errorf("%d: UnmarshalText(%q) unexpected error; %s", src, text, err)
+			continue
+		}
+		if got != src {
+			t.Errorf("UnmarshalText(%q) = %d, want %d", text, got, src)
+		}
+	}
+	var src NameSource
+	if err := src.UnmarshalText([]byte("bogus")); err == nil {
+		t.Errorf("UnmarshalText(bogus) expected error")
+	}
+}
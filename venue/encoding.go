@@ -0,0 +1,56 @@
+package venue
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// charsetRE matches the charset declared in an HTML meta tag.
+var charsetRE = regexp.MustCompile(`(?i)<meta[^>]+charset=["']?([a-z0-9_:.-]+)`)
+
+// defaultCharset is used when the data is not valid UTF-8, and no usable
+// charset is declared. Windows-1252 is a superset of the printable
+// ISO-8859-1 characters that Venue declares.
+const defaultCharset = "windows-1252"
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// toUTF8 returns the HTML data converted to UTF-8, and the name of the
+// detected charset.
+//
+// Venue declares its exports as ISO-8859-1, but consoles set to other
+// languages, or files edited by hand, may actually hold Windows-1252 or UTF-8.
+// Data that is valid UTF-8 is taken as-is, as it is very unlikely for
+// non-ASCII Windows-1252 text to also be valid UTF-8.
+func toUTF8(data []byte) ([]byte, string, error) {
+	if bytes.HasPrefix(data, utf8BOM) {
+		return data[len(utf8BOM):], "utf-8", nil
+	}
+	if utf8.Valid(data) {
+		return data, "utf-8", nil
+	}
+
+	charset := defaultCharset
+	if m := charsetRE.FindSubmatch(data); m != nil {
+		// The declared charset is ignored if it claims UTF-8, as the data was
+		// already found to be invalid UTF-8.
+		if enc, err := htmlindex.Get(string(m[1])); err == nil {
+			if name, err := htmlindex.Name(enc); err == nil && name != "utf-8" {
+				charset = name
+			}
+		}
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported charset %q; %s", charset, err)
+	}
+	utf, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding %s data; %s", charset, err)
+	}
+	return utf, charset, nil
+}
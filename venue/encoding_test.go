@@ -0,0 +1,47 @@
+package venue
+
+import "testing"
+
+func TestToUTF8(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		data    string
+		utf     string
+		charset string
+	}{
+		{"ascii",
+			`<meta content="text/html; charset=ISO-8859-1">Kick`,
+			`<meta content="text/html; charset=ISO-8859-1">Kick`,
+			"utf-8"},
+		{"utf-8 bom", "\xef\xbb\xbfJosé", "José", "utf-8"},
+		{"utf-8 declared latin-1",
+			`<meta content="text/html; charset=ISO-8859-1">Jos` + "é",
+			`<meta content="text/html; charset=ISO-8859-1">Jos` + "é",
+			"utf-8"},
+		{"latin-1",
+			`<meta content="text/html; charset=ISO-8859-1">Jos` + "\xe9",
+			`<meta content="text/html; charset=ISO-8859-1">Jos` + "é",
+			"windows-1252"},
+		{"windows-1252 undeclared", "\x93Vox\x94 \x96 Jos\xe9", "“Vox” – José", "windows-1252"},
+		{"utf-8 declared invalid",
+			`<meta charset="utf-8">Jos` + "\xe9",
+			`<meta charset="utf-8">Jos` + "é",
+			"windows-1252"},
+		{"iso-8859-2",
+			`<meta charset="iso-8859-2">` + "\xb3",
+			`<meta charset="iso-8859-2">` + "ł",
+			"iso-8859-2"},
+	} {
+		utf, charset, err := toUTF8([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: toUTF8() unexpected error; %s", tt.desc, err)
+			continue
+		}
+		if got, want := string(utf), tt.utf; got != want {
+			t.Errorf("%s: toUTF8() = %q, want %q", tt.desc, got, want)
+		}
+		if got, want := charset, tt.charset; got != want {
+			t.Errorf("%s: toUTF8() charset = %s, want %s", tt.desc, got, want)
+		}
+	}
+}
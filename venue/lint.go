@@ -0,0 +1,243 @@
+package venue
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	xmlpath "gopkg.in/xmlpath.v2"
+)
+
+var versionRE = regexp.MustCompile(`^(VENUE|D-Show) ([0-9]+)\.([0-9]+)`)
+
+//-----------------------------------------------------------------------------
+// Problem
+
+// Severity describes how serious a Problem is.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+// String implements the fmt.Stringer interface.
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Location describes where in the HTML a problem was found.
+type Location struct {
+	Element string `json:"element" yaml:"element"`             // The xpath or device table (e.g. "Stage 1 Inputs").
+	Row     int    `json:"row,omitempty" yaml:"row,omitempty"` // The 1-based channel row within Element, or 0.
+}
+
+// String implements the fmt.Stringer interface.
+func (l Location) String() string {
+	if l.Row == 0 {
+		return l.Element
+	}
+	return fmt.Sprintf("%s row %d", l.Element, l.Row)
+}
+
+// Problem describes a single issue found while linting a Venue patch file.
+type Problem struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	Location Location `json:"location" yaml:"location"`
+	Message  string   `json:"message" yaml:"message"`
+}
+
+// String implements the fmt.Stringer interface.
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Location, p.Message)
+}
+
+// Problems is a slice of problems.
+type Problems []*Problem
+
+// Max returns the highest severity found, or Info if there are no problems.
+func (ps Problems) Max() Severity {
+	max := Info
+	for _, p := range ps {
+		if p.Severity > max {
+			max = p.Severity
+		}
+	}
+	return max
+}
+
+func (ps *Problems) add(sev Severity, loc Location, format string, a ...interface{}) {
+	*ps = append(*ps, &Problem{sev, loc, fmt.Sprintf(format, a...)})
+}
+
+//-----------------------------------------------------------------------------
+// Lint
+
+// Lint checks a Venue patch file for problems that Parse either fails on
+// without context, or silently accepts (e.g. a truncated export). The named
+// devices must be present; if none are named, at least one stage box is
+// expected. An error is only returned if the data is not parseable HTML.
+func Lint(data []byte, devices ...string) (Problems, error) {
+	data, charset, err := toUTF8(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := xmlpath.ParseHTML(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	ps := Problems{}
+	if charset != "utf-8" {
+		ps.add(Info, Location{Element: "html"}, "decoded as %s", charset)
+	}
+	if !bytes.Contains(bytes.ToLower(data), []byte("</html>")) {
+		ps.add(Warning, Location{Element: "html"}, "closing </html> tag missing; export may be truncated")
+	}
+	lintMetadata(root, &ps)
+
+	found := map[string]bool{}
+	for _, name := range []string{
+		Console, Engine, Local, ProTools, Stage1, Stage2, Stage3, Stage4,
+	} {
+		found[name] = lintDevice(root, name, &ps)
+	}
+
+	if len(devices) == 0 {
+		stage := false
+		for _, name := range []string{Stage1, Stage2, Stage3, Stage4} {
+			stage = stage || found[name]
+		}
+		if !stage {
+			ps.add(Error, Location{Element: "body"}, "no stage box devices found; tracks cannot be mapped")
+		}
+	}
+	for _, name := range devices {
+		if !found[name] {
+			ps.add(Error, Location{Element: "body"}, "expected device %q not found", name)
+		}
+	}
+
+	return ps, nil
+}
+
+// lintMetadata checks the console, version and show metadata.
+func lintMetadata(root *xmlpath.Node, ps *Problems) {
+	for _, name := range []string{"console", "version", "show"} {
+		loc := Location{Element: xpaths[name].xpath}
+		str, ok := xpaths[name].path.String(root)
+		if !ok {
+			ps.add(Error, loc, "%s not found", name)
+			continue
+		}
+		str = trim(str)
+		if str == "" {
+			ps.add(Warning, loc, "%s is empty", name)
+			continue
+		}
+		if name == "version" {
+			lintVersion(str, loc, ps)
+		}
+	}
+}
+
+// lintVersion checks that the console software version is one known to
+// produce a compatible patch list (VENUE 4.5.x or earlier, or D-Show).
+func lintVersion(version string, loc Location, ps *Problems) {
+	m := versionRE.FindStringSubmatch(version)
+	if m == nil {
+		ps.add(Warning, loc, "unrecognized console version %q", version)
+		return
+	}
+	if m[1] != "VENUE" {
+		return
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	if major > 4 || (major == 4 && minor > 5) {
+		ps.add(Warning, loc, "unsupported console version %q; VENUE 4.5.x or earlier is supported", version)
+	}
+}
+
+// lintDevice checks the input and output tables of a device. It returns true
+// if the device would be discovered by Parse.
+func lintDevice(root *xmlpath.Node, name string, ps *Problems) bool {
+	inputs, iok := deviceTable(root, name, "Inputs")
+	outputs, ook := deviceTable(root, name, "Outputs")
+	switch {
+	case !iok && !ook:
+		return false
+	case !iok:
+		ps.add(Error, Location{Element: name + " Outputs"}, "%s Inputs table missing; device is ignored", name)
+		return false
+	case !ook:
+		ps.add(Error, Location{Element: name + " Inputs"}, "%s Outputs table missing; device is ignored", name)
+		return false
+	}
+
+	stageBox := strings.HasPrefix(name, "Stage ")
+	lintChannels(inputs, name+" Inputs", stageBox, ps)
+	lintChannels(outputs, name+" Outputs", false, ps)
+	return true
+}
+
+// lintChannels checks the channel rows of a single device table. Empty names
+// are only reported for stage box inputs, as only those are mapped to tracks.
+func lintChannels(node *xmlpath.Node, element string, stageBox bool, ps *Problems) {
+	seen := map[string]int{}
+	rows := 0
+	walkChannels(node, func(ch *Channel) {
+		rows++
+		loc := Location{Element: element, Row: ch.row}
+		if ch.moniker == "" {
+			ps.add(Error, loc, "empty moniker")
+			return
+		}
+		if row, ok := seen[ch.moniker]; ok {
+			ps.add(Error, loc, "duplicate moniker %q; first seen in row %d", ch.moniker, row)
+		} else {
+			seen[ch.moniker] = ch.row
+		}
+		if stageBox && ch.name == "" {
+			ps.add(Warning, loc, "input %s has no name", ch.moniker)
+		}
+		if r, ok := suspicious(ch.name); ok {
+			ps.add(Warning, loc, "name %q contains suspicious character %U", ch.name, r)
+		}
+		if stageBox {
+			if n, err := strconv.Atoi(ch.moniker); err != nil || n != rows {
+				ps.add(Warning, loc, "moniker %q out of sequence; expected %d", ch.moniker, rows)
+			}
+		}
+	})
+	if rows == 0 {
+		ps.add(Error, Location{Element: element}, "no channels found")
+	}
+}
+
+// suspicious returns the first rune of s that is unlikely to be part of an
+// intended channel name, e.g. a control character or a character replaced
+// during a bad encoding conversion.
+func suspicious(s string) (rune, bool) {
+	for _, r := range s {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return r, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package venue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLintTestdata(t *testing.T) {
+	for _, td := range testdata {
+		data, err := ioutil.ReadFile("../testdata/" + td.name)
+		if err != nil {
+			t.Fatalf("error reading testdata file; %s", err)
+		}
+		ps, err := Lint(data, td.devNames...)
+		if err != nil {
+			t.Errorf("%s: Lint() unexpected error; %s", td.name, err)
+			continue
+		}
+		if got, want := ps.Max(), Warning; got > want {
+			t.Errorf("%s: Lint() max severity = %s, want <= %s; %v", td.name, got, want, ps)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		version  string
+		tables   []string
+		devices  []string
+		problems []string
+	}{
+		{"clean", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick", "2", "Snare"),
+				lintTable("Stage 1 Outputs", "1", "Left"),
+			},
+			nil,
+			nil},
+		{"unsupported version", "VENUE 5.2.0",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick"),
+				lintTable("Stage 1 Outputs", "1", "Left"),
+			},
+			nil,
+			[]string{`warning: //meta[@name='author']/@content: unsupported console version "VENUE 5.2.0"; VENUE 4.5.x or earlier is supported`}},
+		{"duplicate moniker", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick", "2", "Snare", "2", "Hat"),
+				lintTable("Stage 1 Outputs", "1", "Left"),
+			},
+			nil,
+			[]string{
+				`error: Stage 1 Inputs row 3: duplicate moniker "2"; first seen in row 2`,
+				`warning: Stage 1 Inputs row 3: moniker "2" out of sequence; expected 3`,
+			}},
+		{"empty name", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick", "2", ""),
+				lintTable("Stage 1 Outputs", "1", ""),
+			},
+			nil,
+			[]string{`warning: Stage 1 Inputs row 2: input 2 has no name`}},
+		{"suspicious character", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick�"),
+				lintTable("Stage 1 Outputs", "1", "Left"),
+			},
+			nil,
+			[]string{`warning: Stage 1 Inputs row 1: name "Kick�" contains suspicious character U+FFFD`}},
+		{"missing outputs", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick"),
+			},
+			nil,
+			[]string{
+				`error: Stage 1 Inputs: Stage 1 Outputs table missing; device is ignored`,
+				`error: body: no stage box devices found; tracks cannot be mapped`,
+			}},
+		{"missing expected device", "VENUE 4.5.3",
+			[]string{
+				lintTable("Stage 1 Inputs", "1", "Kick"),
+				lintTable("Stage 1 Outputs", "1", "Left"),
+			},
+			[]string{Stage1, ProTools},
+			[]string{`error: body: expected device "Pro Tools" not found`}},
+	} {
+		ps, err := Lint([]byte(lintHTML(tt.version, tt.tables...)), tt.devices...)
+		if err != nil {
+			t.Errorf("%s: Lint() unexpected error; %s", tt.desc, err)
+			continue
+		}
+		got := []string{}
+		for _, p := range ps {
+			got = append(got, p.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.problems, "\n") {
+			t.Errorf("%s: Lint() =\n%s\nwant\n%s", tt.desc, strings.Join(got, "\n"), strings.Join(tt.problems, "\n"))
+		}
+	}
+}
+
+func TestLintTruncated(t *testing.T) {
+	html := lintHTML("VENUE 4.5.3",
+		lintTable("Stage 1 Inputs", "1", "Kick"),
+		lintTable("Stage 1 Outputs", "1", "Left"))
+	html = html[:strings.Index(html, "</body>")]
+
+	ps, err := Lint([]byte(html))
+	if err != nil {
+		t.Fatalf("Lint() unexpected error; %s", err)
+	}
+	if got, want := len(ps), 1; got != want {
+		t.Fatalf("Lint() returned %d problems, want %d; %v", got, want, ps)
+	}
+	if got, want := ps[0].Location.Element, "html"; got != want {
+		t.Errorf("Lint() location = %s, want %s", got, want)
+	}
+}
+
+// lintHTML returns a minimal Venue patch list with the given device tables.
+func lintHTML(version string, tables ...string) string {
+	return fmt.Sprintf(`<html>
+<head>
+<meta name="author" content="%s">
+<meta name="description" content="Avid VENUE">
+</head>
+<body>
+<table><tbody>
+<tr><td><span>Show:</span></td><td>Test\Show</td></tr>
+</tbody></table>
+%s
+</body>
+</html>
+`, version, strings.Join(tables, "\n"))
+}
+
+// lintTable returns a device table with the given moniker and name pairs.
+func lintTable(title string, chs ...string) string {
+	s := fmt.Sprintf("<table><tbody>\n<tr><td colspan=\"4\"><span>%s</span></td></tr>\n", title)
+	for i := 0; i+1 < len(chs); i += 2 {
+		name := chs[i+1]
+		if name == "" {
+			name = "&nbsp;"
+		}
+		s += fmt.Sprintf("<tr><td><span>%s</span></td><td>%s</td><td><span>%s</span></td></tr>\n", chs[i], name, chs[i])
+	}
+	return s + "</tbody></table>"
+}
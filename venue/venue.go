@@ -7,12 +7,15 @@ package venue
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/kward/golib/errors"
 	"github.com/kward/tracks/venue/hardware"
+	"golang.org/x/text/unicode/norm"
 	"google.golang.org/grpc/codes"
 	xmlpath "gopkg.in/xmlpath.v2"
 )
@@ -46,6 +49,7 @@ type Venue struct {
 	console string
 	version string
 	show    string
+	charset string // The charset the patch file was decoded from.
 
 	devices         Devices
 	inputs, outputs Channels
@@ -69,6 +73,50 @@ func (v *Venue) String() string {
 	return s
 }
 
+// MarshalJSON implements the json.Marshaler interface. Devices are sorted by
+// name.
+func (v *Venue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Console string    `json:"console"`
+		Version string    `json:"version"`
+		Show    string    `json:"show"`
+		Charset string    `json:"charset"`
+		Devices []*Device `json:"devices"`
+	}{v.console, v.version, v.show, v.charset, v.devices.Sorted()})
+}
+
+// Console returns the console name.
+func (v *Venue) Console() string {
+	if v == nil {
+		return ""
+	}
+	return v.console
+}
+
+// Version returns the console software version.
+func (v *Venue) Version() string {
+	if v == nil {
+		return ""
+	}
+	return v.version
+}
+
+// Show returns the show name.
+func (v *Venue) Show() string {
+	if v == nil {
+		return ""
+	}
+	return v.show
+}
+
+// Charset returns the charset the patch file was decoded from.
+func (v *Venue) Charset() string {
+	if v == nil {
+		return ""
+	}
+	return v.charset
+}
+
 // Devices returns the known devices.
 func (v *Venue) Devices() Devices {
 	if v == nil {
@@ -77,8 +125,14 @@ func (v *Venue) Devices() Devices {
 	return v.devices
 }
 
-// Parse a Venue patch file.
+// Parse a Venue patch file. The data is converted to UTF-8 first.
 func (v *Venue) Parse(data []byte) error {
+	data, charset, err := toUTF8(data)
+	if err != nil {
+		return err
+	}
+	v.charset = charset
+
 	root, err := xmlpath.ParseHTML(bytes.NewReader(data))
 	if err != nil {
 		return err
@@ -107,7 +161,7 @@ func (v *Venue) parseMetadata(root *xmlpath.Node) error {
 	} {
 		str, ok := xpaths[row.name].path.String(root)
 		if !ok {
-			return fmt.Errorf("%s xpath returned no values", row.name)
+			return fmt.Errorf("%s xpath %q returned no values", row.name, xpaths[row.name].xpath)
 		}
 
 		val := row.val
@@ -144,6 +198,57 @@ func (ds Devices) Inputs() map[int]*Channel {
 	return chs
 }
 
+// InputNumber returns the number of the named stage box input, as numbered by
+// Inputs(), or false if the input is not found.
+func (ds Devices) InputNumber(device, moniker string) (int, bool) {
+	num := 0
+	for _, name := range []string{Stage1, Stage2, Stage3, Stage4} {
+		dev, ok := ds[name]
+		if !ok {
+			continue
+		}
+		if name != device {
+			num += dev.NumInputs()
+			continue
+		}
+		i, err := strconv.Atoi(moniker)
+		if err != nil || i < 1 || i > dev.NumInputs() {
+			return 0, false
+		}
+		return num + i, true
+	}
+	return 0, false
+}
+
+// InputLocation returns the device name and moniker of an input, as numbered
+// by Inputs(), or false if the input is not found.
+func (ds Devices) InputLocation(num int) (string, string, bool) {
+	for _, name := range []string{Stage1, Stage2, Stage3, Stage4} {
+		dev, ok := ds[name]
+		if !ok {
+			continue
+		}
+		if num <= dev.NumInputs() {
+			if num < 1 {
+				break
+			}
+			return name, Moniker(num), true
+		}
+		num -= dev.NumInputs()
+	}
+	return "", "", false
+}
+
+// Sorted returns the devices, sorted by name.
+func (ds Devices) Sorted() []*Device {
+	devs := []*Device{}
+	for _, d := range ds {
+		devs = append(devs, d)
+	}
+	sort.Slice(devs, func(i, j int) bool { return devs[i].name < devs[j].name })
+	return devs
+}
+
 // Device describes a Venue IO device.
 type Device struct {
 	hardware        hardware.Hardware
@@ -161,6 +266,17 @@ func NewDevice(hw hardware.Hardware, name string, inputs, outputs Channels) *Dev
 	}
 }
 
+// MarshalJSON implements the json.Marshaler interface. Channels are sorted by
+// moniker.
+func (d *Device) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name     string            `json:"name"`
+		Hardware string            `json:"hardware"`
+		Inputs   ChannelsByMoniker `json:"inputs"`
+		Outputs  ChannelsByMoniker `json:"outputs"`
+	}{d.name, d.hardware.String(), d.inputs.Sorted(), d.outputs.Sorted()})
+}
+
 // Type returns the device hardware type.
 func (d *Device) Hardware() hardware.Hardware {
 	if d == nil {
@@ -289,21 +405,21 @@ func discoverDevice(root *xmlpath.Node, name string) (*Device, error) {
 		dev.hardware = hardware.Unknown
 	}
 
-	iter := xmlpath.MustCompile(fmt.Sprintf(xpaths["devices"].xpath, name, "Inputs")).Iter(root)
-	if !iter.Next() {
+	node, ok := deviceTable(root, name, "Inputs")
+	if !ok {
 		return nil, errors.Errorf(codes.NotFound, "%s inputs not found", name)
 	}
-	_, chs, err := probeDevice(iter.Node(), "Inputs")
+	_, chs, err := probeDevice(node, "Inputs")
 	if err != nil {
 		return nil, err
 	}
 	dev.inputs = chs
 
-	iter = xmlpath.MustCompile(fmt.Sprintf(xpaths["devices"].xpath, name, "Outputs")).Iter(root)
-	if !iter.Next() {
+	node, ok = deviceTable(root, name, "Outputs")
+	if !ok {
 		return nil, errors.Errorf(codes.NotFound, "%s outputs not found", name)
 	}
-	_, chs, err = probeDevice(iter.Node(), "Outputs")
+	_, chs, err = probeDevice(node, "Outputs")
 	if err != nil {
 		return nil, err
 	}
@@ -312,6 +428,16 @@ func discoverDevice(root *xmlpath.Node, name string) (*Device, error) {
 	return dev, nil
 }
 
+// deviceTable returns the title row of the named device table (e.g. "Stage 1"
+// "Inputs"), or false if the table was not found.
+func deviceTable(root *xmlpath.Node, name, title string) (*xmlpath.Node, bool) {
+	iter := xmlpath.MustCompile(fmt.Sprintf(xpaths["devices"].xpath, name, title)).Iter(root)
+	if !iter.Next() {
+		return nil, false
+	}
+	return iter.Node(), true
+}
+
 // probeDevice walks the XML, probing a device for info.
 func probeDevice(node *xmlpath.Node, title string) (string, Channels, error) {
 	name := trim(node.String())
@@ -353,6 +479,7 @@ func (d ChannelsByMoniker) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 type Channel struct {
 	moniker string // The channel number (e.g. "1") or IO name (e.g. "FWx 1").
 	name    string
+	row     int // The table row the channel was found in, if parsed.
 }
 
 // NewChannel returns an instantiated Channel.
@@ -377,6 +504,15 @@ func (c *Channel) Equal(c2 *Channel) bool {
 	return c.name == c2.name
 }
 
+// MarshalJSON implements the json.Marshaler interface.
+func (c *Channel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Moniker string `json:"moniker"`
+		Name    string `json:"name"`
+		Row     int    `json:"row,omitempty"`
+	}{c.moniker, c.name, c.row})
+}
+
 // Moniker returns the channel moniker.
 func (c *Channel) Moniker() string {
 	if c == nil {
@@ -393,6 +529,15 @@ func (c *Channel) Name() string {
 	return c.name
 }
 
+// Row returns the 1-based table row the channel was parsed from, not counting
+// the device title row. It is 0 if the channel was not parsed from HTML.
+func (c *Channel) Row() int {
+	if c == nil {
+		return 0
+	}
+	return c.row
+}
+
 // CleanName returns a clean track name.
 func (c *Channel) CleanName() string {
 	if c == nil || c.name == "" {
@@ -431,22 +576,32 @@ func (c *Channel) String() string {
 // probeChannels walks XML, looking for channel info.
 func probeChannels(root *xmlpath.Node) (Channels, error) {
 	chs := Channels{}
+	walkChannels(root, func(ch *Channel) {
+		chs[ch.moniker] = ch
+	})
+	return chs, nil
+}
 
+// walkChannels calls fn for each channel row of the table holding root, in
+// document order.
+func walkChannels(root *xmlpath.Node, fn func(ch *Channel)) {
 	chIter := xpaths["channel"].path.Iter(root)
-	first := true
+	row := -1
 	for chIter.Next() {
-		if first { // Skip the stage box description.
-			first = false
+		row++
+		if row == 0 { // Skip the stage box description.
 			continue
 		}
 
-		ch := &Channel{}
+		ch := &Channel{row: row}
 		state := "number"
+		found := false
 
 		dIter := xpaths["channelDetail"].path.Iter(chIter.Node())
 		for dIter.Next() {
 			node := dIter.Node()
 			moniker := trim(node.String())
+			found = true
 
 			switch state {
 			case "number":
@@ -458,12 +613,11 @@ func probeChannels(root *xmlpath.Node) (Channels, error) {
 			case "number2":
 				// Do nothing.
 			}
-
-			chs[ch.moniker] = ch
+		}
+		if found {
+			fn(ch)
 		}
 	}
-
-	return chs, nil
 }
 
 //-----------------------------------------------------------------------------
@@ -504,7 +658,9 @@ func Moniker(num int) string {
 
 func sanitize(text string) string {
 	// Remove &nbsp; equivalent chars.
-	return strings.Replace(text, "\u00a0", "", -1)
+	text = strings.Replace(text, "\u00a0", "", -1)
+	// Compose accented characters so that equal names compare equal.
+	return norm.NFC.String(text)
 }
 
 func trim(text string) string {
@@ -2,6 +2,7 @@ package venue
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"os"
@@ -156,6 +157,24 @@ func TestDiscoverDevices(t *testing.T) {
 // Device
 //
 
+func TestDeviceMarshalJSON(t *testing.T) {
+	devs := Devices{
+		"Stage 2": NewDevice(hardware.StageBox, "Stage 2", Channels{}, Channels{}),
+		"Stage 1": NewDevice(hardware.StageBox, "Stage 1",
+			Channels{"2": NewChannel("2", "Snare"), "1": NewChannel("1", "Kick")},
+			Channels{}),
+	}
+	got, err := json.Marshal(devs.Sorted())
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error %s", err)
+	}
+	want := `[{"name":"Stage 1","hardware":"StageBox","inputs":[{"moniker":"1","name":"Kick"},{"moniker":"2","name":"Snare"}],"outputs":[]},` +
+		`{"name":"Stage 2","hardware":"StageBox","inputs":[],"outputs":[]}]`
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+}
+
 func TestDevicesInputs(t *testing.T) {
 	data, err := ioutil.ReadFile("../testdata/20180128 Avid S3L-X Patch List.html")
 	if err != nil {
@@ -187,6 +206,45 @@ func TestDevicesInputs(t *testing.T) {
 	}
 }
 
+func TestDevicesInputNumber(t *testing.T) {
+	devs := Devices{
+		Stage1: NewDevice(hardware.StageBox, Stage1,
+			Channels{"1": NewChannel("1", ""), "2": NewChannel("2", "")}, Channels{}),
+		Stage3: NewDevice(hardware.StageBox, Stage3,
+			Channels{"1": NewChannel("1", ""), "2": NewChannel("2", "")}, Channels{}),
+	}
+	for _, tt := range []struct {
+		device, moniker string
+		num             int
+		ok              bool
+	}{
+		{Stage1, "1", 1, true},
+		{Stage1, "2", 2, true},
+		{Stage3, "1", 3, true},
+		{Stage1, "3", 0, false},
+		{Stage2, "1", 0, false},
+		{ProTools, "1", 0, false},
+		{Stage1, "x", 0, false},
+	} {
+		num, ok := devs.InputNumber(tt.device, tt.moniker)
+		if num != tt.num || ok != tt.ok {
+			t.Errorf("InputNumber(%q, %q) = %d, %v, want %d, %v", tt.device, tt.moniker, num, ok, tt.num, tt.ok)
+		}
+		if !tt.ok {
+			continue
+		}
+		dev, moniker, ok := devs.InputLocation(tt.num)
+		if dev != tt.device || moniker != tt.moniker || !ok {
+			t.Errorf("InputLocation(%d) = %q, %q, %v, want %q, %q, true", tt.num, dev, moniker, ok, tt.device, tt.moniker)
+		}
+	}
+	for _, num := range []int{0, 5} {
+		if _, _, ok := devs.InputLocation(num); ok {
+			t.Errorf("InputLocation(%d) expected not found", num)
+		}
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Channel
 //